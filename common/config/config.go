@@ -98,6 +98,27 @@ var QuotaRemindThreshold int64 = 1000
 var PreConsumedQuota int64 = 500
 var ApproximateTokenEnabled = false
 var RetryTimes = 0
+var BatchDiscountRatio = 1.0 // applied to the quota of batch requests
+
+var ResponseCacheEnabled = false
+var ResponseCacheModels = ""        // comma separated, empty means all models
+var ResponseCacheTTL = 60 * 60      // unit is second
+var ResponseCacheBillingRatio = 0.1 // applied to the quota of cache hits
+
+var ResponseCacheSize = env.Int("RESPONSE_CACHE_SIZE", 1000) // max entries of the in-memory cache, used when redis is disabled
+
+var AuditLogEnabled = false
+var AuditLogGroups = ""             // comma separated, the groups audited besides the tokens which opt in
+var AuditLogMaxBodySize = 64 * 1024 // unit is byte, the bodies are truncated beyond it
+var AuditLogRetentionDays = 30      // 0 means the audit logs are kept forever
+
+var TopUpPrice = 1.0   // the money charged for a unit of quota, i.e. QuotaPerUnit
+var TopUpMinAmount = 1 // the minimum units of quota of an online top-up
+var PaymentCurrency = "USD"
+var StripeApiSecret = ""
+var StripeWebhookSecret = ""
+var PaymentGatewayAddress = "" // the pay page of the generic provider, {trade_no}, {money}, {currency} and {notify_url} are replaced
+var PaymentWebhookSecret = ""
 
 var RootUserEmail = ""
 
@@ -113,6 +134,13 @@ var BatchUpdateInterval = env.Int("BATCH_UPDATE_INTERVAL", 5)
 
 var RelayTimeout = env.Int("RELAY_TIMEOUT", 0) // unit is second
 
+var BatchPollingFrequency = env.Int("BATCH_POLLING_FREQUENCY", 5) // unit is minute
+
+var BudgetSyncFrequency = env.Int("BUDGET_SYNC_FREQUENCY", 10) // unit is second
+
+// WebhookAllowPrivateAddress allows the webhook endpoints on the private networks, which are refused by default
+var WebhookAllowPrivateAddress = env.Bool("WEBHOOK_ALLOW_PRIVATE_ADDRESS", false)
+
 var GeminiSafetySetting = env.String("GEMINI_SAFETY_SETTING", "BLOCK_NONE")
 
 var Theme = env.String("THEME", "default")
@@ -149,6 +177,27 @@ var MetricSuccessRateThreshold = env.Float64("METRIC_SUCCESS_RATE_THRESHOLD", 0.
 var MetricSuccessChanSize = env.Int("METRIC_SUCCESS_CHAN_SIZE", 1024)
 var MetricFailChanSize = env.Int("METRIC_FAIL_CHAN_SIZE", 128)
 
+// prometheus metrics of the relay traffic are exposed at /metrics
+var MetricsEnabled = env.Bool("METRICS_ENABLED", false)
+var MetricsToken = os.Getenv("METRICS_TOKEN")                          // bearer token required to scrape the metrics, the endpoint is disabled without it
+var MetricsRefreshFrequency = env.Int("METRICS_REFRESH_FREQUENCY", 15) // unit is second
+
+// the otlp exporter of the traces is configured by the standard OTEL_EXPORTER_OTLP_* environment variables
+var TracingEnabled = env.Bool("TRACING_ENABLED", false)
+var TracingSampleRatio = env.Float64("TRACING_SAMPLE_RATIO", 1)
+
+// the circuit breaker replaces the metric based channel disabling when it is enabled
+var CircuitBreakerEnabled = env.Bool("CIRCUIT_BREAKER_ENABLED", false)
+var CircuitBreakerFailureThreshold = env.Int("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5) // consecutive failures which open the breaker
+var CircuitBreakerCooldown = env.Int("CIRCUIT_BREAKER_COOLDOWN", 30)                 // unit is second, doubled every time the breaker opens again
+var CircuitBreakerMaxCooldown = env.Int("CIRCUIT_BREAKER_MAX_COOLDOWN", 10*60)       // unit is second
+var CircuitBreakerProbeRatio = env.Float64("CIRCUIT_BREAKER_PROBE_RATIO", 0.1)       // share of the requests let through when half-open
+var CircuitBreakerProbeSuccesses = env.Int("CIRCUIT_BREAKER_PROBE_SUCCESSES", 3)     // successful probes which close the breaker
+
+// requests beyond the max concurrency of a channel wait in its queue
+var ChannelQueueSize = env.Int("CHANNEL_QUEUE_SIZE", 64)
+var ChannelQueueTimeout = env.Int("CHANNEL_QUEUE_TIMEOUT", 30) // unit is second
+
 var InitialRootToken = os.Getenv("INITIAL_ROOT_TOKEN")
 
 var InitialRootAccessToken = os.Getenv("INITIAL_ROOT_ACCESS_TOKEN")
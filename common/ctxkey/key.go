@@ -15,9 +15,20 @@ const (
 	Group             = "group"
 	ModelMapping      = "model_mapping"
 	ChannelName       = "channel_name"
+	MaxConcurrency    = "max_concurrency"
 	TokenId           = "token_id"
 	TokenName         = "token_name"
 	BaseURL           = "base_url"
 	AvailableModels   = "available_models"
 	KeyRequestBody    = "key_request_body"
+	ResponseCache     = "response_cache"
+	ResponseCacheHit  = "response_cache_hit"
+	TokenRpmLimit     = "token_rpm_limit"
+	TokenTpmLimit     = "token_tpm_limit"
+	RateLimit         = "rate_limit"
+	RateLimitExceeded = "rate_limit_exceeded"
+	AuditLog          = "audit_log"
+	AuditLogStarted   = "audit_log_started"
+	ModelFallback     = "model_fallback"
+	OrganizationId    = "organization_id"
 )
@@ -2,4 +2,9 @@ package helper
 
 const (
 	RequestIdKey = "X-Oneapi-Request-Id"
+	TraceIdKey   = "X-Oneapi-Trace-Id"
+	// RequestModelKey carries the model requested by the client, which may be an alias or fall back to another model
+	RequestModelKey = "X-Oneapi-Request-Model"
+	// OrganizationIdKey carries the organization whose quota the request draws
+	OrganizationIdKey = "X-Oneapi-Organization-Id"
 )
@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// the currencies are charged in whole units or in cents, except for the special cases below
+// https://docs.stripe.com/currencies#special-cases
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// the three-decimal currencies are still charged in cents, i.e. their minor amounts end in zero
+var threeDecimalCurrencies = map[string]bool{"BHD": true, "JOD": true, "KWD": true, "OMR": true, "TND": true}
+
+// RoundMoney rounds the money to what can be charged in the currency
+func RoundMoney(money float64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return math.Round(money)
+	}
+	return math.Round(money*100) / 100
+}
+
+// minorUnits returns the minor units of the currency in a unit, the amounts of the providers are in minor units
+func minorUnits(currency string) float64 {
+	currency = strings.ToUpper(currency)
+	if zeroDecimalCurrencies[currency] {
+		return 1
+	}
+	if threeDecimalCurrencies[currency] {
+		return 1000
+	}
+	return 100
+}
+
+// Order is what a provider needs to know to charge for a top-up
+type Order struct {
+	TradeNo     string
+	Money       float64
+	Currency    string
+	Description string
+	ReturnURL   string // where the user is sent back to after paying
+	NotifyURL   string // where the provider notifies the result
+}
+
+// Event is a verified notification of a provider
+type Event struct {
+	TradeNo         string
+	ProviderTradeNo string
+	Paid            bool    // false for the notifications which are not about a completed payment
+	Failed          bool    // the payment is failed or expired, the order can no longer be paid
+	Money           float64 // the money actually paid
+}
+
+// Provider charges for the orders and notifies the results by webhooks
+type Provider interface {
+	Name() string
+	// Enabled reports whether the provider is configured
+	Enabled() bool
+	// CreatePayment starts the payment of the order and returns the url the user pays at
+	CreatePayment(ctx context.Context, order *Order) (string, error)
+	// VerifyWebhook checks the signature of the notification and parses it
+	VerifyWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+var providers = make(map[string]Provider)
+
+func register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+// GetProvider returns the provider of the name if it is enabled
+func GetProvider(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok || !provider.Enabled() {
+		return nil, fmt.Errorf("payment provider %s is not available", name)
+	}
+	return provider, nil
+}
+
+// EnabledProviders returns the names of the providers which are configured in order
+func EnabledProviders() []string {
+	names := make([]string, 0, len(providers))
+	for name, provider := range providers {
+		if provider.Enabled() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyStripeSignature(t *testing.T) {
+	body := []byte(`{"type":"checkout.session.completed"}`)
+	now := time.Unix(1700000000, 0)
+	signature := Sign([]byte(fmt.Sprintf("%d.%s", now.Unix(), body)), "whsec_test")
+	header := fmt.Sprintf("t=%d,v1=deadbeef,v1=%s", now.Unix(), signature)
+
+	assert.NoError(t, verifyStripeSignature(header, body, "whsec_test", now))
+	assert.Error(t, verifyStripeSignature(header, body, "whsec_other", now))
+	assert.Error(t, verifyStripeSignature(header, []byte(`{}`), "whsec_test", now))
+	assert.Error(t, verifyStripeSignature(header, body, "whsec_test", now.Add(time.Hour)))
+	assert.Error(t, verifyStripeSignature("v1="+signature, body, "whsec_test", now))
+}
+
+func TestWebhookProvider(t *testing.T) {
+	config.PaymentGatewayAddress = "https://pay.example.com/?no={trade_no}&money={money}&notify={notify_url}"
+	config.PaymentWebhookSecret = "secret"
+	defer func() {
+		config.PaymentGatewayAddress = ""
+		config.PaymentWebhookSecret = ""
+	}()
+	assert.Contains(t, EnabledProviders(), "webhook")
+	provider, err := GetProvider("webhook")
+	assert.NoError(t, err)
+
+	payURL, err := provider.CreatePayment(context.Background(), &Order{TradeNo: "T1", Money: 10, NotifyURL: "http://localhost:3000/api/payment/webhook/webhook"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://pay.example.com/?no=T1&money=10.00&notify=http%3A%2F%2Flocalhost%3A3000%2Fapi%2Fpayment%2Fwebhook%2Fwebhook", payURL)
+
+	body := []byte(`{"trade_no":"T1","provider_trade_no":"P1","status":"paid","money":10}`)
+	header := http.Header{}
+	header.Set(SignatureHeader, Sign(body, "secret"))
+	event, err := provider.VerifyWebhook(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, &Event{TradeNo: "T1", ProviderTradeNo: "P1", Paid: true, Money: 10}, event)
+
+	header.Set(SignatureHeader, Sign(body, "other"))
+	_, err = provider.VerifyWebhook(header, body)
+	assert.Error(t, err)
+
+	_, err = GetProvider("stripe")
+	assert.Error(t, err)
+}
+
+func TestRoundMoney(t *testing.T) {
+	assert.Equal(t, 10.57, RoundMoney(10.5678, "USD"))
+	assert.Equal(t, 1501.0, RoundMoney(1500.5, "JPY"))
+	assert.Equal(t, 1500.0, RoundMoney(1500.37, "krw"))
+	assert.Equal(t, 3.46, RoundMoney(3.456, "KWD"))
+
+	assert.Equal(t, 100.0, minorUnits("usd"))
+	assert.Equal(t, 1.0, minorUnits("JPY"))
+	assert.Equal(t, 1000.0, minorUnits("kwd"))
+}
+
+func TestStripeWebhookCurrency(t *testing.T) {
+	config.StripeWebhookSecret = "whsec_test"
+	defer func() { config.StripeWebhookSecret = "" }()
+	for currency, money := range map[string]float64{"usd": 15, "jpy": 1500, "kwd": 1.5} {
+		body := []byte(fmt.Sprintf(`{"type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"T1","payment_status":"paid","amount_total":1500,"currency":%q}}}`, currency))
+		now := time.Now()
+		header := http.Header{}
+		header.Set("Stripe-Signature", fmt.Sprintf("t=%d,v1=%s", now.Unix(), Sign([]byte(fmt.Sprintf("%d.%s", now.Unix(), body)), "whsec_test")))
+		event, err := (&stripeProvider{}).VerifyWebhook(header, body)
+		assert.NoError(t, err)
+		assert.Equal(t, &Event{TradeNo: "T1", ProviderTradeNo: "cs_1", Paid: true, Money: money}, event, currency)
+	}
+}
@@ -0,0 +1,152 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// https://docs.stripe.com/api/checkout/sessions/create
+// https://docs.stripe.com/webhooks#verify-manually
+
+const stripeApiBase = "https://api.stripe.com"
+
+// the notifications signed earlier than the tolerance are rejected to prevent replay
+const stripeSignatureTolerance = 5 * time.Minute
+
+type stripeProvider struct{}
+
+func init() {
+	register(&stripeProvider{})
+}
+
+func (p *stripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *stripeProvider) Enabled() bool {
+	return config.StripeApiSecret != "" && config.StripeWebhookSecret != ""
+}
+
+type stripeCheckoutSession struct {
+	Id                string `json:"id"`
+	Url               string `json:"url"`
+	ClientReferenceId string `json:"client_reference_id"`
+	PaymentStatus     string `json:"payment_status"`
+	AmountTotal       int64  `json:"amount_total"`
+	Currency          string `json:"currency"`
+	Error             *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type stripeEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Object stripeCheckoutSession `json:"object"`
+	} `json:"data"`
+}
+
+func (p *stripeProvider) CreatePayment(ctx context.Context, order *Order) (string, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", order.ReturnURL)
+	form.Set("cancel_url", order.ReturnURL)
+	form.Set("client_reference_id", order.TradeNo)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(order.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(int64(math.Round(RoundMoney(order.Money, order.Currency)*minorUnits(order.Currency))), 10))
+	form.Set("line_items[0][price_data][product_data][name]", order.Description)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stripeApiBase+"/v1/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.StripeApiSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// the trade no keeps a retried creation from opening another session
+	req.Header.Set("Idempotency-Key", order.TradeNo)
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var session stripeCheckoutSession
+	if err = json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return "", err
+	}
+	if session.Error != nil {
+		return "", fmt.Errorf("stripe error: %s", session.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK || session.Url == "" {
+		return "", fmt.Errorf("stripe returned status %d", resp.StatusCode)
+	}
+	return session.Url, nil
+}
+
+// verifyStripeSignature checks the Stripe-Signature header, which looks like t=1492774577,v1=5257a869...
+func verifyStripeSignature(signatureHeader string, body []byte, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signatureHeader, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return fmt.Errorf("invalid stripe signature header")
+	}
+	if now.Sub(time.Unix(signedAt, 0)) > stripeSignatureTolerance {
+		return fmt.Errorf("stripe signature is expired")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		actual, err := hex.DecodeString(signature)
+		if err == nil && hmac.Equal(actual, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("stripe signature mismatch")
+}
+
+func (p *stripeProvider) VerifyWebhook(header http.Header, body []byte) (*Event, error) {
+	if err := verifyStripeSignature(header.Get("Stripe-Signature"), body, config.StripeWebhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+	var event stripeEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	session := event.Data.Object
+	// the delayed payment methods complete the session unpaid, then succeed asynchronously
+	completed := event.Type == "checkout.session.completed" || event.Type == "checkout.session.async_payment_succeeded"
+	return &Event{
+		TradeNo:         session.ClientReferenceId,
+		ProviderTradeNo: session.Id,
+		Paid:            completed && session.PaymentStatus == "paid",
+		Failed:          event.Type == "checkout.session.expired" || event.Type == "checkout.session.async_payment_failed",
+		Money:           float64(session.AmountTotal) / minorUnits(session.Currency),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// the generic provider sends the users to a pay page of any gateway, which notifies the result with a json body
+// like {"trade_no": "...", "provider_trade_no": "...", "status": "paid", "money": 10.5}, where the status is paid or failed,
+// signed by the hex encoded HMAC-SHA256 of the body in the X-Signature header. it can be stubbed locally by
+// posting such a notification
+
+const SignatureHeader = "X-Signature"
+
+type webhookProvider struct{}
+
+func init() {
+	register(&webhookProvider{})
+}
+
+func (p *webhookProvider) Name() string {
+	return "webhook"
+}
+
+func (p *webhookProvider) Enabled() bool {
+	return config.PaymentGatewayAddress != "" && config.PaymentWebhookSecret != ""
+}
+
+type webhookNotification struct {
+	TradeNo         string  `json:"trade_no"`
+	ProviderTradeNo string  `json:"provider_trade_no"`
+	Status          string  `json:"status"`
+	Money           float64 `json:"money"`
+}
+
+func (p *webhookProvider) CreatePayment(ctx context.Context, order *Order) (string, error) {
+	replacer := strings.NewReplacer(
+		"{trade_no}", url.QueryEscape(order.TradeNo),
+		"{money}", strconv.FormatFloat(order.Money, 'f', 2, 64),
+		"{currency}", url.QueryEscape(order.Currency),
+		"{notify_url}", url.QueryEscape(order.NotifyURL),
+		"{return_url}", url.QueryEscape(order.ReturnURL),
+	)
+	return replacer.Replace(config.PaymentGatewayAddress), nil
+}
+
+// Sign returns the signature of the body the generic provider expects
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *webhookProvider) VerifyWebhook(header http.Header, body []byte) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	expected, _ := hex.DecodeString(Sign(body, config.PaymentWebhookSecret))
+	if !hmac.Equal(signature, expected) {
+		return nil, fmt.Errorf("signature mismatch")
+	}
+	var notification webhookNotification
+	if err = json.Unmarshal(body, &notification); err != nil {
+		return nil, err
+	}
+	return &Event{
+		TradeNo:         notification.TradeNo,
+		ProviderTradeNo: notification.ProviderTradeNo,
+		Paid:            notification.Status == "paid",
+		Failed:          notification.Status == "failed",
+		Money:           notification.Money,
+	}, nil
+}
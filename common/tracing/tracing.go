@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the spans are exported with otlp over http when tracing is enabled, the exporter is configured by
+// the standard environment variables such as OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_EXPORTER_OTLP_HEADERS.
+// the trace context is only taken from the callers and propagated upstream when tracing is enabled,
+// the baggage of the callers is never passed on, it may carry data not meant for the upstream
+
+const tracerName = "github.com/songquanpeng/one-api"
+
+var tracer = otel.Tracer(tracerName)
+
+// Init sets up the propagator and the exporter, the returned function flushes the spans left
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if !config.TracingEnabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("one-api"),
+		semconv.ServiceVersion(common.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, opts...)
+}
+
+// StartStage starts the span of a stage of the request, the request context carries the span
+// so that the spans started within the stage are its children, until the returned function ends it.
+// the values added to the request context within the stage are kept, only the span is restored
+func StartStage(c *gin.Context, name string, attrs ...attribute.KeyValue) (trace.Span, func()) {
+	parent := trace.SpanFromContext(c.Request.Context())
+	ctx, span := tracer.Start(c.Request.Context(), name, trace.WithAttributes(attrs...))
+	c.Request = c.Request.WithContext(ctx)
+	return span, func() {
+		span.End()
+		c.Request = c.Request.WithContext(trace.ContextWithSpan(c.Request.Context(), parent))
+	}
+}
+
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject propagates the trace context to the upstream request
+func Inject(ctx context.Context, header http.Header) {
+	if !config.TracingEnabled {
+		return
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract picks up the trace context of the caller
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if !config.TracingEnabled {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// GetTraceId returns the trace id of the span in the context, it is empty when there is no span
+func GetTraceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	recorder     = tracetest.NewSpanRecorder()
+	recorderOnce sync.Once
+)
+
+// setupRecorder records the spans of the package tracer, the global tracer is bound to the
+// first provider set, so the recorder is shared by the tests
+func setupRecorder(t *testing.T) {
+	recorderOnce.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+	enabled := config.TracingEnabled
+	t.Cleanup(func() { config.TracingEnabled = enabled })
+}
+
+type contextKey string
+
+func TestStartStage(t *testing.T) {
+	setupRecorder(t)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx, root := Start(c.Request.Context(), "root")
+	c.Request = c.Request.WithContext(ctx)
+
+	span, endSpan := StartStage(c, "stage")
+	_, child := Start(c.Request.Context(), "child")
+	child.End()
+	// a value added within the stage outlives it
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey("key"), "value"))
+	endSpan()
+	root.End()
+
+	assert.Equal(t, "value", c.Request.Context().Value(contextKey("key")))
+	assert.Equal(t, root.SpanContext(), trace.SpanContextFromContext(c.Request.Context()))
+	var recorded sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().SpanID() == child.SpanContext().SpanID() {
+			recorded = s
+		}
+	}
+	require.NotNil(t, recorded)
+	assert.Equal(t, span.SpanContext().SpanID(), recorded.Parent().SpanID())
+}
+
+func TestPropagation(t *testing.T) {
+	setupRecorder(t)
+	inbound := http.Header{}
+	inbound.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	inbound.Set("baggage", "user=secret")
+
+	config.TracingEnabled = true
+	shutdown, err := Init(context.Background())
+	require.NoError(t, err)
+	defer func() { _ = shutdown(context.Background()) }()
+	ctx := Extract(context.Background(), inbound)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", GetTraceId(ctx))
+	outbound := http.Header{}
+	Inject(ctx, outbound)
+	assert.Contains(t, outbound.Get("traceparent"), "4bf92f3577b34da6a3ce929d0e0e4736")
+	// the baggage of the caller is not passed on
+	assert.Empty(t, outbound.Get("baggage"))
+
+	// nothing is taken from the caller or passed upstream when tracing is disabled
+	config.TracingEnabled = false
+	assert.Empty(t, GetTraceId(Extract(context.Background(), inbound)))
+	outbound = http.Header{}
+	Inject(ctx, outbound)
+	assert.Empty(t, outbound)
+}
@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// the payloads are signed by the hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret of the endpoint,
+// the receivers recompute it from the timestamp header and the raw body to verify the sender
+
+const (
+	SignatureHeader = "X-OneAPI-Signature"
+	TimestampHeader = "X-OneAPI-Timestamp"
+	EventHeader     = "X-OneAPI-Event"
+	DeliveryHeader  = "X-OneAPI-Delivery"
+)
+
+// MaxAttempts is the number of deliveries tried before a delivery is given up
+const MaxAttempts = 5
+
+const (
+	initialBackoff = 30 * time.Second
+	maxBackoff     = time.Hour
+)
+
+// Backoff returns the delay before the next attempt after the given number of failed attempts, the delay
+// grows fourfold each time: 30s, 2m, 8m, 32m, then an hour
+func Backoff(attempts int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 4
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// ErrForbiddenAddress is returned for the endpoints on the private networks, unless they are allowed
+var ErrForbiddenAddress = errors.New("webhook endpoint resolves to a private, loopback or link-local address")
+
+// httpClient checks every address it dials, so that a name resolving to the internal network, at first or
+// after it is saved, is refused. No proxy is used, as the proxy would be dialed instead of the endpoint
+var httpClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkAddress}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// IsForbiddenIP reports whether the ip is on a private, loopback or link-local network
+func IsForbiddenIP(ip net.IP) bool {
+	if config.WebhookAllowPrivateAddress {
+		return false
+	}
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+func checkAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsForbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// ValidateURL checks the endpoint before it is saved, the names are checked again whenever they are resolved
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("webhook endpoint must be an https url")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && IsForbiddenIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts the signed payload to the endpoint, any status other than 2xx is taken as a failure
+func Send(ctx context.Context, url string, secret string, event string, deliveryId int, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(deliveryId))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, body))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
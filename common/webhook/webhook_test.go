@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func allowPrivateAddress(t *testing.T, allow bool) {
+	allowed := config.WebhookAllowPrivateAddress
+	config.WebhookAllowPrivateAddress = allow
+	t.Cleanup(func() { config.WebhookAllowPrivateAddress = allowed })
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, 2*time.Minute, Backoff(2))
+	assert.Equal(t, 8*time.Minute, Backoff(3))
+	assert.Equal(t, 32*time.Minute, Backoff(4))
+	assert.Equal(t, time.Hour, Backoff(5))
+	assert.Equal(t, time.Hour, Backoff(20))
+}
+
+func TestSend(t *testing.T) {
+	allowPrivateAddress(t, true)
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		assert.Equal(t, "sha256="+Sign("secret", timestamp, body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, "channel.disabled", r.Header.Get(EventHeader))
+		assert.Equal(t, "42", r.Header.Get(DeliveryHeader))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	code, err := Send(context.Background(), server.URL, "secret", "channel.disabled", 42, []byte(`{"event":"channel.disabled"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	status = http.StatusInternalServerError
+	code, err = Send(context.Background(), server.URL, "secret", "channel.disabled", 42, []byte(`{}`))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
+
+func TestSendToPrivateAddress(t *testing.T) {
+	allowPrivateAddress(t, false)
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer server.Close()
+
+	// the address is checked when it is dialed, whatever the url looks like
+	_, err := Send(context.Background(), server.URL, "secret", "channel.disabled", 1, []byte(`{}`))
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	_, err = Send(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1), "secret", "channel.disabled", 1, []byte(`{}`))
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.Zero(t, hits)
+}
+
+func TestValidateURL(t *testing.T) {
+	allowPrivateAddress(t, false)
+	assert.NoError(t, ValidateURL("https://example.com/hook"))
+	assert.NoError(t, ValidateURL("https://8.8.8.8/hook"))
+	assert.Error(t, ValidateURL("http://example.com/hook"))
+	assert.Error(t, ValidateURL("https:///hook"))
+	for _, address := range []string{"https://127.0.0.1/hook", "https://10.0.0.1/hook", "https://192.168.1.1:8443/hook",
+		"https://169.254.169.254/latest", "https://[::1]/hook", "https://[fe80::1]/hook", "https://0.0.0.0/hook"} {
+		assert.ErrorIs(t, ValidateURL(address), ErrForbiddenAddress, address)
+	}
+	allowPrivateAddress(t, true)
+	assert.NoError(t, ValidateURL("https://10.0.0.1/hook"))
+}
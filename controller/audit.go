@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const auditLogExportBatchSize = 100
+
+func getAuditLogFilter(c *gin.Context) *model.AuditLogFilter {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	return &model.AuditLogFilter{
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		ModelName:      c.Query("model_name"),
+		RequestId:      c.Query("request_id"),
+		Channel:        channel,
+		Keyword:        c.Query("keyword"),
+	}
+}
+
+func GetAuditLogs(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	logs, err := model.GetAuditLogs(getAuditLogFilter(c), p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
+
+func GetAuditLog(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	log, err := model.GetAuditLogById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    log,
+	})
+}
+
+// ExportAuditLogs streams the audit logs matching the filter as json lines, along with the bodies
+func ExportAuditLogs(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-logs-%d.jsonl", helper.GetTimestamp()))
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	err := model.ExportAuditLogs(getAuditLogFilter(c), auditLogExportBatchSize, func(logs []*model.AuditLog) error {
+		for _, log := range logs {
+			if err := encoder.Encode(log); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// the status is already sent, the export can only be cut short
+		logger.Error(c.Request.Context(), "failed to export audit logs: "+err.Error())
+	}
+}
+
+func DeleteHistoryAuditLogs(c *gin.Context) {
+	targetTimestamp, _ := strconv.ParseInt(c.Query("target_timestamp"), 10, 64)
+	if targetTimestamp == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "target timestamp is required",
+		})
+		return
+	}
+	count, err := model.DeleteOldAuditLog(targetTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+// AutomaticallyCleanAuditLogs deletes the audit logs older than the retention every hour
+func AutomaticallyCleanAuditLogs() {
+	for {
+		time.Sleep(time.Hour)
+		if config.AuditLogRetentionDays <= 0 {
+			continue
+		}
+		targetTimestamp := helper.GetTimestamp() - int64(config.AuditLogRetentionDays)*24*60*60
+		count, err := model.DeleteOldAuditLog(targetTimestamp)
+		if err != nil {
+			logger.SysError("failed to clean audit logs: " + err.Error())
+			continue
+		}
+		if count > 0 {
+			logger.SysLogf("cleaned %d expired audit logs", count)
+		}
+	}
+}
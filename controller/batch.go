@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/controller"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// https://platform.openai.com/docs/api-reference/batch/list
+
+func ListBatches(c *gin.Context) {
+	userId := c.GetInt(ctxkey.Id)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	batches, err := model.GetUserBatches(userId, 0, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": relaymodel.Error{
+				Message: err.Error(),
+				Type:    "one_api_error",
+				Code:    "list_batches_failed",
+			},
+		})
+		return
+	}
+	batchList := relaymodel.BatchList{
+		Object: "list",
+		Data:   make([]relaymodel.Batch, 0, len(batches)),
+	}
+	for _, batch := range batches {
+		batchList.Data = append(batchList.Data, relaymodel.Batch{
+			Id:           batch.BatchId,
+			Object:       "batch",
+			Endpoint:     batch.Endpoint,
+			InputFileId:  batch.InputFileId,
+			Status:       batch.Status,
+			OutputFileId: batch.OutputFileId,
+			CreatedAt:    batch.CreatedTime,
+		})
+	}
+	c.JSON(http.StatusOK, batchList)
+}
+
+func settleBatches() {
+	batches, err := model.GetUnsettledBatches()
+	if err != nil {
+		logger.SysError("failed to get unsettled batches: " + err.Error())
+		return
+	}
+	for _, batch := range batches {
+		err = controller.SettleBatch(context.Background(), batch)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to settle batch %s: %s", batch.BatchId, err.Error()))
+		}
+		time.Sleep(config.RequestInterval)
+	}
+}
+
+func AutomaticallySettleBatches(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Minute)
+		logger.SysLog("settling unfinished batches")
+		settleBatches()
+		logger.SysLog("batch settlement finished")
+	}
+}
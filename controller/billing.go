@@ -6,6 +6,7 @@ import (
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/model"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"strconv"
 )
 
 func GetSubscription(c *gin.Context) {
@@ -29,6 +30,14 @@ func GetSubscription(c *gin.Context) {
 			usedQuota, err = model.GetUserUsedQuota(userId)
 		}
 	}
+	var subscription *model.Subscription
+	if err == nil {
+		subscription, err = model.GetSubscriptionByUserId(c.GetInt(ctxkey.Id))
+	}
+	if subscription != nil && subscription.ExpiredTime != -1 && (expiredTime <= 0 || subscription.ExpiredTime < expiredTime) {
+		// the access ends along with the subscription, unless the token expires earlier
+		expiredTime = subscription.ExpiredTime
+	}
 	if expiredTime <= 0 {
 		expiredTime = 0
 	}
@@ -50,7 +59,7 @@ func GetSubscription(c *gin.Context) {
 	if token != nil && token.UnlimitedQuota {
 		amount = 100000000
 	}
-	subscription := OpenAISubscriptionResponse{
+	subscriptionResponse := OpenAISubscriptionResponse{
 		Object:             "billing_subscription",
 		HasPaymentMethod:   true,
 		SoftLimitUSD:       amount,
@@ -58,7 +67,13 @@ func GetSubscription(c *gin.Context) {
 		SystemHardLimitUSD: amount,
 		AccessUntil:        expiredTime,
 	}
-	c.JSON(200, subscription)
+	if subscription != nil {
+		subscriptionResponse.Plan = &OpenAISubscriptionPlan{
+			Title: subscription.Plan.Name,
+			Id:    strconv.Itoa(subscription.Plan.Id),
+		}
+	}
+	c.JSON(200, subscriptionResponse)
 	return
 }
 
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+// prepareBudget validates the budget and fills in the user it belongs to, the users may only budget themselves and
+// their own tokens, while the admins may budget anyone and the groups
+func prepareBudget(c *gin.Context, budget *model.Budget, admin bool) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+	userId := c.GetInt(ctxkey.Id)
+	switch budget.Scope {
+	case model.BudgetScopeUser:
+		if !admin && budget.TargetId != userId {
+			return errors.New("只能为自己设置预算")
+		}
+		if _, err := model.GetUserById(budget.TargetId, false); err != nil {
+			return errors.New("用户不存在")
+		}
+		budget.UserId = budget.TargetId
+	case model.BudgetScopeToken:
+		token, err := model.GetTokenById(budget.TargetId)
+		if err != nil || (!admin && token.UserId != userId) {
+			return errors.New("令牌不存在")
+		}
+		budget.UserId = token.UserId
+	case model.BudgetScopeGroup:
+		if !admin {
+			return errors.New("无权为分组设置预算")
+		}
+	}
+	// the webhooks and the message pusher are set up by the admins, the users are notified by email only
+	if !admin && (budget.NotifyBy == model.BudgetNotifyByWebhook || budget.NotifyBy == model.BudgetNotifyByMessagePusher) {
+		return errors.New("无权使用该通知方式")
+	}
+	budget.Managed = admin
+	return nil
+}
+
+func getBudgets(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	budgets, err := model.GetBudgets(userId, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budgets,
+	})
+}
+
+func addBudget(c *gin.Context, admin bool) {
+	budget := model.Budget{}
+	err := c.ShouldBindJSON(&budget)
+	if err == nil {
+		err = prepareBudget(c, &budget, admin)
+	}
+	if err == nil {
+		err = budget.Insert()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budget,
+	})
+}
+
+// getOwnedBudget returns the budget the user may change, the users cannot change the budgets set by the admins
+func getOwnedBudget(c *gin.Context, id int, admin bool) (*model.Budget, error) {
+	budget, err := model.GetBudgetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if !admin && (budget.UserId != c.GetInt(ctxkey.Id) || budget.Managed) {
+		return nil, errors.New("无权修改该预算")
+	}
+	return budget, nil
+}
+
+func updateBudget(c *gin.Context, admin bool) {
+	budget := model.Budget{}
+	err := c.ShouldBindJSON(&budget)
+	if err == nil {
+		_, err = getOwnedBudget(c, budget.Id, admin)
+	}
+	if err == nil {
+		err = prepareBudget(c, &budget, admin)
+	}
+	if err == nil {
+		err = budget.Update()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    budget,
+	})
+}
+
+func deleteBudget(c *gin.Context, admin bool) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	_, err := getOwnedBudget(c, id, admin)
+	if err == nil {
+		err = model.DeleteBudgetById(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetAllBudgets(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getBudgets(c, userId)
+}
+
+func AddBudget(c *gin.Context) {
+	addBudget(c, true)
+}
+
+func UpdateBudget(c *gin.Context) {
+	updateBudget(c, true)
+}
+
+func DeleteBudget(c *gin.Context) {
+	deleteBudget(c, true)
+}
+
+func GetSelfBudgets(c *gin.Context) {
+	getBudgets(c, c.GetInt(ctxkey.Id))
+}
+
+func AddSelfBudget(c *gin.Context) {
+	addBudget(c, false)
+}
+
+func UpdateSelfBudget(c *gin.Context) {
+	updateBudget(c, false)
+}
+
+func DeleteSelfBudget(c *gin.Context) {
+	deleteBudget(c, false)
+}
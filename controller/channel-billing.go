@@ -9,6 +9,7 @@ import (
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"io"
 	"net/http"
@@ -21,12 +22,18 @@ import (
 // https://github.com/songquanpeng/one-api/issues/79
 
 type OpenAISubscriptionResponse struct {
-	Object             string  `json:"object"`
-	HasPaymentMethod   bool    `json:"has_payment_method"`
-	SoftLimitUSD       float64 `json:"soft_limit_usd"`
-	HardLimitUSD       float64 `json:"hard_limit_usd"`
-	SystemHardLimitUSD float64 `json:"system_hard_limit_usd"`
-	AccessUntil        int64   `json:"access_until"`
+	Object             string                  `json:"object"`
+	HasPaymentMethod   bool                    `json:"has_payment_method"`
+	SoftLimitUSD       float64                 `json:"soft_limit_usd"`
+	HardLimitUSD       float64                 `json:"hard_limit_usd"`
+	SystemHardLimitUSD float64                 `json:"system_hard_limit_usd"`
+	AccessUntil        int64                   `json:"access_until"`
+	Plan               *OpenAISubscriptionPlan `json:"plan,omitempty"`
+}
+
+type OpenAISubscriptionPlan struct {
+	Title string `json:"title"`
+	Id    string `json:"id"`
 }
 
 type OpenAIUsageDailyCost struct {
@@ -101,6 +108,32 @@ type SiliconFlowUsageResponse struct {
 	} `json:"data"`
 }
 
+type DeepSeekBalanceResponse struct {
+	IsAvailable  bool `json:"is_available"`
+	BalanceInfos []struct {
+		Currency     string `json:"currency"`
+		TotalBalance string `json:"total_balance"`
+	} `json:"balance_infos"`
+}
+
+type MoonshotBalanceResponse struct {
+	Code   int    `json:"code"`
+	Error  string `json:"error"`
+	Status bool   `json:"status"`
+	Data   struct {
+		AvailableBalance float64 `json:"available_balance"`
+		VoucherBalance   float64 `json:"voucher_balance"`
+		CashBalance      float64 `json:"cash_balance"`
+	} `json:"data"`
+}
+
+type OpenRouterCreditsResponse struct {
+	Data struct {
+		TotalCredits float64 `json:"total_credits"`
+		TotalUsage   float64 `json:"total_usage"`
+	} `json:"data"`
+}
+
 // GetAuthHeader get auth header
 func GetAuthHeader(token string) http.Header {
 	h := http.Header{}
@@ -245,6 +278,102 @@ func updateChannelSiliconFlowBalance(channel *model.Channel) (float64, error) {
 	return balance, nil
 }
 
+func updateChannelDeepSeekBalance(channel *model.Channel) (float64, error) {
+	url := fmt.Sprintf("%s/user/balance", channel.GetBaseURL())
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	if err != nil {
+		return 0, err
+	}
+	response := DeepSeekBalanceResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	if len(response.BalanceInfos) == 0 {
+		return 0, errors.New("no balance info")
+	}
+	// the balance is kept in USD, the balance in CNY is converted when there is no balance in USD
+	info := response.BalanceInfos[0]
+	for _, balanceInfo := range response.BalanceInfos {
+		if balanceInfo.Currency == "USD" {
+			info = balanceInfo
+			break
+		}
+	}
+	balance, err := strconv.ParseFloat(info.TotalBalance, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch info.Currency {
+	case "USD":
+	case "CNY":
+		balance /= ratio.USD2RMB
+	default:
+		return 0, fmt.Errorf("unsupported currency: %s", info.Currency)
+	}
+	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+func updateChannelMoonshotBalance(channel *model.Channel) (float64, error) {
+	url := fmt.Sprintf("%s/v1/users/me/balance", channel.GetBaseURL())
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	if err != nil {
+		return 0, err
+	}
+	response := MoonshotBalanceResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	if !response.Status || response.Code != 0 {
+		return 0, fmt.Errorf("code: %d, message: %s", response.Code, response.Error)
+	}
+	// the balance is in CNY, it is kept in USD
+	balance := response.Data.AvailableBalance / ratio.USD2RMB
+	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+func updateChannelOpenRouterBalance(channel *model.Channel) (float64, error) {
+	url := fmt.Sprintf("%s/v1/credits", channel.GetBaseURL())
+	body, err := GetResponseBody("GET", url, channel, GetAuthHeader(channel.Key))
+	if err != nil {
+		return 0, err
+	}
+	response := OpenRouterCreditsResponse{}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return 0, err
+	}
+	balance := response.Data.TotalCredits - response.Data.TotalUsage
+	channel.UpdateBalance(balance)
+	return balance, nil
+}
+
+// isBalanceSupported tells whether updateChannelBalance knows how to query the balance of the channel type
+func isBalanceSupported(channelType int) bool {
+	switch channelType {
+	case channeltype.OpenAI, channeltype.Custom, channeltype.CloseAI, channeltype.OpenAISB, channeltype.AIProxy,
+		channeltype.API2GPT, channeltype.AIGC2D, channeltype.SiliconFlow, channeltype.DeepSeek, channeltype.Moonshot,
+		channeltype.OpenRouter:
+		return true
+	default:
+		return false
+	}
+}
+
+// isBalanceDisableSupported tells whether the channel type is disabled once the balance is used up, the other
+// types only report the balance, as they may report no balance while still serving the requests
+func isBalanceDisableSupported(channelType int) bool {
+	switch channelType {
+	case channeltype.OpenAI, channeltype.Custom, channeltype.DeepSeek, channeltype.Moonshot, channeltype.OpenRouter:
+		return true
+	default:
+		return false
+	}
+}
+
 func updateChannelBalance(channel *model.Channel) (float64, error) {
 	baseURL := channeltype.ChannelBaseURLs[channel.Type]
 	if channel.GetBaseURL() == "" {
@@ -271,6 +400,12 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 		return updateChannelAIGC2DBalance(channel)
 	case channeltype.SiliconFlow:
 		return updateChannelSiliconFlowBalance(channel)
+	case channeltype.DeepSeek:
+		return updateChannelDeepSeekBalance(channel)
+	case channeltype.Moonshot:
+		return updateChannelMoonshotBalance(channel)
+	case channeltype.OpenRouter:
+		return updateChannelOpenRouterBalance(channel)
 	default:
 		return 0, errors.New("尚未实现")
 	}
@@ -331,6 +466,7 @@ func UpdateChannelBalance(c *gin.Context) {
 		})
 		return
 	}
+	checkChannelBalance(channel, balance)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -339,28 +475,61 @@ func UpdateChannelBalance(c *gin.Context) {
 	return
 }
 
+// checkChannelBalance acts on the balance of the channel: the channel is disabled once the balance is used up and
+// enabled again once it recovers, and the balance below the threshold is notified once until it recovers
+func checkChannelBalance(channel *model.Channel, balance float64) {
+	state := channel.BalanceState
+	switch {
+	case balance <= 0:
+		// err is nil & balance <= 0 means quota is used up
+		if channel.Status == model.ChannelStatusEnabled && isBalanceDisableSupported(channel.Type) {
+			monitor.DisableChannel(channel.Id, channel.Name, "余额不足")
+			// the state is reset by the disabling, it is saved again below
+			channel.BalanceState = model.ChannelBalanceStateNormal
+			state = model.ChannelBalanceStateExhausted
+		}
+	case state == model.ChannelBalanceStateExhausted:
+		// only the channels disabled for the balance are enabled again, not the ones disabled for the other reasons
+		if channel.Status == model.ChannelStatusAutoDisabled {
+			monitor.EnableChannel(channel.Id, channel.Name)
+		}
+		state = model.ChannelBalanceStateNormal
+	}
+	threshold := channel.GetBalanceThreshold()
+	if balance > 0 && threshold > 0 {
+		if balance < threshold && state == model.ChannelBalanceStateNormal {
+			monitor.ChannelBalanceLow(channel.Id, channel.Name, balance, threshold)
+			state = model.ChannelBalanceStateLow
+		} else if balance >= threshold && state == model.ChannelBalanceStateLow {
+			state = model.ChannelBalanceStateNormal
+		}
+	}
+	if state != channel.BalanceState {
+		model.UpdateChannelBalanceState(channel.Id, state)
+		channel.BalanceState = state
+	}
+}
+
 func updateAllChannelsBalance() error {
 	channels, err := model.GetAllChannels(0, 0, "all")
 	if err != nil {
 		return err
 	}
 	for _, channel := range channels {
-		if channel.Status != model.ChannelStatusEnabled {
+		// the channels disabled for the used up balance are checked as well, so that they are enabled once it recovers
+		exhausted := channel.Status == model.ChannelStatusAutoDisabled && channel.BalanceState == model.ChannelBalanceStateExhausted
+		if channel.Status != model.ChannelStatusEnabled && !exhausted {
 			continue
 		}
 		// TODO: support Azure
-		if channel.Type != channeltype.OpenAI && channel.Type != channeltype.Custom {
+		if !isBalanceSupported(channel.Type) {
 			continue
 		}
 		balance, err := updateChannelBalance(channel)
 		if err != nil {
 			continue
-		} else {
-			// err is nil & balance <= 0 means quota is used up
-			if balance <= 0 {
-				monitor.DisableChannel(channel.Id, channel.Name, "余额不足")
-			}
 		}
+		checkChannelBalance(channel, balance)
 		time.Sleep(config.RequestInterval)
 	}
 	return nil
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB opens an in-memory database for the test, it is left in place afterwards, and redis is
+// left disabled, as the notifications run in the background may still be using them
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Channel{}, &model.Ability{}, &model.Webhook{}, &model.WebhookDelivery{}, &model.AuditLog{}))
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled = false
+	usingSQLite := common.UsingSQLite
+	common.UsingSQLite = true
+	t.Cleanup(func() {
+		common.UsingSQLite = usingSQLite
+	})
+}
+
+// setupBalanceChannel creates a channel of the type, whose upstream responds to the path with the body
+func setupBalanceChannel(t *testing.T, channelType int, path string, body string) *model.Channel {
+	client.Init()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	baseURL := server.URL
+	channel := &model.Channel{Type: channelType, Name: "balance", Key: "sk-test", Status: model.ChannelStatusEnabled, BaseURL: &baseURL}
+	require.NoError(t, model.DB.Create(channel).Error)
+	return channel
+}
+
+func getChannel(t *testing.T, id int) *model.Channel {
+	channel, err := model.GetChannelById(id, true)
+	require.NoError(t, err)
+	return channel
+}
+
+func TestUpdateChannelDeepSeekBalance(t *testing.T) {
+	setupTestDB(t)
+	channel := setupBalanceChannel(t, channeltype.DeepSeek, "/user/balance",
+		`{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"70.00"},{"currency":"USD","total_balance":"12.50"}]}`)
+	balance, err := updateChannelBalance(channel)
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, balance)
+	assert.Equal(t, 12.5, getChannel(t, channel.Id).Balance)
+
+	// the balance in CNY is converted to USD
+	channel = setupBalanceChannel(t, channeltype.DeepSeek, "/user/balance",
+		`{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"70.00"}]}`)
+	balance, err = updateChannelBalance(channel)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, balance)
+
+	channel = setupBalanceChannel(t, channeltype.DeepSeek, "/user/balance",
+		`{"is_available":true,"balance_infos":[{"currency":"EUR","total_balance":"10.00"}]}`)
+	_, err = updateChannelBalance(channel)
+	assert.Error(t, err)
+	channel = setupBalanceChannel(t, channeltype.DeepSeek, "/user/balance", `{"is_available":false,"balance_infos":[]}`)
+	_, err = updateChannelBalance(channel)
+	assert.Error(t, err)
+}
+
+func TestUpdateChannelMoonshotBalance(t *testing.T) {
+	setupTestDB(t)
+	channel := setupBalanceChannel(t, channeltype.Moonshot, "/v1/users/me/balance",
+		`{"code":0,"status":true,"data":{"available_balance":35,"voucher_balance":0,"cash_balance":35}}`)
+	balance, err := updateChannelBalance(channel)
+	require.NoError(t, err)
+	// the balance in CNY is converted to USD
+	assert.Equal(t, 5.0, balance)
+	assert.Equal(t, 5.0, getChannel(t, channel.Id).Balance)
+
+	channel = setupBalanceChannel(t, channeltype.Moonshot, "/v1/users/me/balance",
+		`{"code":401,"error":"invalid key","status":false}`)
+	_, err = updateChannelBalance(channel)
+	assert.Error(t, err)
+}
+
+func TestUpdateChannelOpenRouterBalance(t *testing.T) {
+	setupTestDB(t)
+	channel := setupBalanceChannel(t, channeltype.OpenRouter, "/v1/credits",
+		`{"data":{"total_credits":25.5,"total_usage":5.5}}`)
+	balance, err := updateChannelBalance(channel)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, balance)
+	assert.Equal(t, 20.0, getChannel(t, channel.Id).Balance)
+}
+
+func TestCheckChannelBalance(t *testing.T) {
+	setupTestDB(t)
+	allowed := config.WebhookAllowPrivateAddress
+	config.WebhookAllowPrivateAddress = true
+	t.Cleanup(func() { config.WebhookAllowPrivateAddress = allowed })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	require.NoError(t, (&model.Webhook{Name: "test", URL: server.URL, Secret: "secret", Events: model.WebhookEventChannelBalanceLow}).Insert())
+	countAlerts := func() int64 {
+		var count int64
+		model.DB.Model(&model.WebhookDelivery{}).Where("event = ?", model.WebhookEventChannelBalanceLow).Count(&count)
+		return count
+	}
+	threshold := 10.0
+	channel := setupBalanceChannel(t, channeltype.OpenRouter, "/v1/credits", `{}`)
+	channel.BalanceThreshold = &threshold
+
+	// the low balance is alerted once until it recovers
+	checkChannelBalance(channel, 5)
+	assert.Equal(t, model.ChannelBalanceStateLow, getChannel(t, channel.Id).BalanceState)
+	checkChannelBalance(channel, 3)
+	assert.Equal(t, int64(1), countAlerts())
+	checkChannelBalance(channel, 20)
+	assert.Equal(t, model.ChannelBalanceStateNormal, getChannel(t, channel.Id).BalanceState)
+	checkChannelBalance(channel, 5)
+	assert.Equal(t, int64(2), countAlerts())
+
+	// the channel is disabled once the balance is used up, and enabled again once it recovers
+	checkChannelBalance(channel, 0)
+	saved := getChannel(t, channel.Id)
+	assert.Equal(t, model.ChannelStatusAutoDisabled, saved.Status)
+	assert.Equal(t, model.ChannelBalanceStateExhausted, saved.BalanceState)
+	saved.BalanceThreshold = &threshold
+	checkChannelBalance(saved, 20)
+	saved = getChannel(t, channel.Id)
+	assert.Equal(t, model.ChannelStatusEnabled, saved.Status)
+	assert.Equal(t, model.ChannelBalanceStateNormal, saved.BalanceState)
+
+	// the channel disabled for another reason is left disabled as the balance recovers
+	checkChannelBalance(saved, 0)
+	monitor.DisableChannel(channel.Id, channel.Name, "invalid key")
+	saved = getChannel(t, channel.Id)
+	assert.Equal(t, model.ChannelBalanceStateNormal, saved.BalanceState)
+	checkChannelBalance(saved, 20)
+	assert.Equal(t, model.ChannelStatusAutoDisabled, getChannel(t, channel.Id).Status)
+
+	// the types reporting the balance only are not disabled
+	channel = setupBalanceChannel(t, channeltype.AIProxy, "/", `{}`)
+	checkChannelBalance(channel, 0)
+	saved = getChannel(t, channel.Id)
+	assert.Equal(t, model.ChannelStatusEnabled, saved.Status)
+	assert.Equal(t, model.ChannelBalanceStateNormal, saved.BalanceState)
+}
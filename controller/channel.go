@@ -23,6 +23,9 @@ func GetAllChannels(c *gin.Context) {
 		})
 		return
 	}
+	for _, channel := range channels {
+		channel.LoadInFlight()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -41,6 +44,9 @@ func SearchChannels(c *gin.Context) {
 		})
 		return
 	}
+	for _, channel := range channels {
+		channel.LoadInFlight()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -66,6 +72,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.LoadInFlight()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"net/http"
+	"strconv"
+)
+
+// https://platform.openai.com/docs/api-reference/files/list
+
+func ListFiles(c *gin.Context) {
+	userId := c.GetInt(ctxkey.Id)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	files, err := model.GetUserFiles(userId, c.Query("purpose"), 0, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": relaymodel.Error{
+				Message: err.Error(),
+				Type:    "one_api_error",
+				Code:    "list_files_failed",
+			},
+		})
+		return
+	}
+	fileList := relaymodel.FileList{
+		Object: "list",
+		Data:   make([]relaymodel.File, 0, len(files)),
+	}
+	for _, file := range files {
+		fileList.Data = append(fileList.Data, relaymodel.File{
+			Id:        file.FileId,
+			Object:    "file",
+			Bytes:     file.Bytes,
+			CreatedAt: file.CreatedTime,
+			Filename:  file.Filename,
+			Purpose:   file.Purpose,
+		})
+	}
+	c.JSON(http.StatusOK, fileList)
+}
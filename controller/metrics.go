@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+)
+
+// GetMetrics exposes the prometheus metrics, the route is only bound when the metrics token is set
+func GetMetrics(c *gin.Context) {
+	token := c.GetHeader("Authorization")
+	if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+config.MetricsToken)) != 1 {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	for type_, depth := range model.GetBatchUpdateQueueDepth() {
+		metrics.BatchUpdateQueueDepth.Set(float64(depth), type_)
+	}
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := metrics.Write(c.Writer); err != nil {
+		logger.SysError("failed to write metrics: " + err.Error())
+	}
+}
+
+// updateChannelGauges refreshes the status and balance gauges of the channels
+func updateChannelGauges() {
+	channels, err := model.GetAllChannels(0, 0, "all")
+	if err != nil {
+		logger.SysError("failed to get channels for metrics: " + err.Error())
+		return
+	}
+	metrics.ChannelStatus.Replace(func(set func(value float64, labelValues ...string)) {
+		for _, channel := range channels {
+			set(float64(channel.Status), strconv.Itoa(channel.Id), channel.Name)
+		}
+	})
+	metrics.ChannelBalance.Replace(func(set func(value float64, labelValues ...string)) {
+		for _, channel := range channels {
+			set(channel.Balance, strconv.Itoa(channel.Id), channel.Name)
+		}
+	})
+}
+
+// AutomaticallyUpdateMetrics refreshes the channel gauges in the background,
+// so that the scrapes do not hit the database
+func AutomaticallyUpdateMetrics(frequency int) {
+	for {
+		updateChannelGauges()
+		time.Sleep(time.Duration(frequency) * time.Second)
+	}
+}
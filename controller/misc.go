@@ -6,6 +6,7 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/message"
+	"github.com/songquanpeng/one-api/common/payment"
 	"github.com/songquanpeng/one-api/model"
 	"net/http"
 	"strings"
@@ -33,6 +34,10 @@ func GetStatus(c *gin.Context) {
 			"turnstile_check":             config.TurnstileCheckEnabled,
 			"turnstile_site_key":          config.TurnstileSiteKey,
 			"top_up_link":                 config.TopUpLink,
+			"top_up_price":                config.TopUpPrice,
+			"top_up_min_amount":           config.TopUpMinAmount,
+			"payment_currency":            config.PaymentCurrency,
+			"payment_providers":           payment.EnabledProviders(),
 			"chat_link":                   config.ChatLink,
 			"quota_per_unit":              config.QuotaPerUnit,
 			"display_in_currency":         config.DisplayInCurrencyEnabled,
@@ -137,7 +137,9 @@ func ListModels(c *gin.Context) {
 	} else {
 		userId := c.GetInt(ctxkey.Id)
 		userGroup, _ := model.CacheGetUserGroup(userId)
-		availableModels, _ = model.CacheGetGroupModels(ctx, userGroup)
+		groupModels, _ := model.CacheGetGroupModels(ctx, userGroup)
+		// the models may be shared by the cache, the aliases are appended to a copy
+		availableModels = append(groupModels[:len(groupModels):len(groupModels)], model.GetModelAliasesOf(groupModels)...)
 	}
 	modelSet := make(map[string]bool)
 	for _, availableModel := range availableModels {
@@ -152,12 +154,14 @@ func ListModels(c *gin.Context) {
 	}
 	for modelName, ok := range modelSet {
 		if ok {
+			// the root of an alias is the model it resolves to
+			root, _ := model.ResolveModelAlias(modelName)
 			availableOpenAIModels = append(availableOpenAIModels, OpenAIModels{
 				Id:      modelName,
 				Object:  "model",
 				Created: 1626777600,
 				OwnedBy: "custom",
-				Root:    modelName,
+				Root:    root,
 				Parent:  nil,
 			})
 		}
@@ -204,6 +208,8 @@ func GetUserAvailableModels(c *gin.Context) {
 		})
 		return
 	}
+	// the models may be shared by the cache, the aliases are appended to a copy
+	models = append(models[:len(models):len(models)], model.GetModelAliasesOf(models)...)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,424 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+)
+
+type addOrganizationRequest struct {
+	Name    string `json:"name"`
+	Quota   int64  `json:"quota"`
+	OwnerId int    `json:"owner_id"`
+}
+
+func GetAllOrganizations(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	organizations, err := model.GetAllOrganizations(p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+func AddOrganization(c *gin.Context) {
+	req := addOrganizationRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.OwnerId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if req.Name == "" || len(req.Name) > 64 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织名称长度必须在1-64之间",
+		})
+		return
+	}
+	if _, err = model.GetUserById(req.OwnerId, false); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "组织所有者不存在",
+		})
+		return
+	}
+	organization := &model.Organization{
+		Name:   req.Name,
+		Status: model.OrganizationStatusEnabled,
+		Quota:  req.Quota,
+	}
+	if err = model.CreateOrganization(organization, req.OwnerId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organization,
+	})
+}
+
+func UpdateOrganization(c *gin.Context) {
+	organization := model.Organization{}
+	err := c.ShouldBindJSON(&organization)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanOrganization, err := model.GetOrganizationById(organization.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if organization.Name != "" {
+		cleanOrganization.Name = organization.Name
+	}
+	if organization.Status == model.OrganizationStatusEnabled || organization.Status == model.OrganizationStatusDisabled {
+		cleanOrganization.Status = organization.Status
+	}
+	cleanOrganization.Quota = organization.Quota
+	if err = cleanOrganization.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanOrganization,
+	})
+}
+
+func DeleteOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteOrganizationById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetSelfOrganizations(c *gin.Context) {
+	organizations, members, err := model.GetUserOrganizations(c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	memberOf := make(map[int]*model.OrganizationMember, len(members))
+	for _, member := range members {
+		memberOf[member.OrganizationId] = member
+	}
+	data := make([]gin.H, 0, len(organizations))
+	for _, organization := range organizations {
+		data = append(data, gin.H{
+			"organization": organization,
+			"member":       memberOf[organization.Id],
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+// getManagingRole returns the role the user manages the organization with,
+// the admins of the system manage every organization as its owner
+func getManagingRole(c *gin.Context, organizationId int) (int, error) {
+	if c.GetInt(ctxkey.Role) >= model.RoleAdminUser {
+		if _, err := model.GetOrganizationById(organizationId); err != nil {
+			return 0, err
+		}
+		return model.OrganizationRoleOwner, nil
+	}
+	member, err := model.GetOrganizationMember(organizationId, c.GetInt(ctxkey.Id))
+	if err != nil {
+		return 0, err
+	}
+	if member.Role < model.OrganizationRoleAdmin {
+		return 0, errors.New("无权管理该组织")
+	}
+	return member.Role, nil
+}
+
+func GetOrganizationMembers(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getManagingRole(c, id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	members, err := model.GetOrganizationMembers(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    members,
+	})
+}
+
+type organizationMemberRequest struct {
+	UserId         int    `json:"user_id"`
+	Username       string `json:"username"`
+	Role           int    `json:"role"`
+	QuotaLimit     int64  `json:"quota_limit"`
+	ResetUsedQuota bool   `json:"reset_used_quota"`
+}
+
+func validateOrganizationMemberRequest(req *organizationMemberRequest) error {
+	if req.Role == 0 {
+		req.Role = model.OrganizationRoleMember
+	}
+	if req.Role != model.OrganizationRoleMember && req.Role != model.OrganizationRoleAdmin {
+		return errors.New("成员角色只能为普通成员或管理员")
+	}
+	if req.QuotaLimit < 0 {
+		return errors.New("成员额度上限不能为负数")
+	}
+	return nil
+}
+
+func AddOrganizationMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	role, err := getManagingRole(c, id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	req := organizationMemberRequest{}
+	if err = c.ShouldBindJSON(&req); err == nil {
+		err = validateOrganizationMemberRequest(&req)
+	}
+	if err == nil && req.Role >= role {
+		err = errors.New("无权添加该角色的成员")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	user := &model.User{Username: req.Username}
+	if req.UserId != 0 {
+		user, err = model.GetUserById(req.UserId, false)
+	} else {
+		err = user.FillUserByUsername()
+	}
+	if err != nil || user.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "用户不存在",
+		})
+		return
+	}
+	member := &model.OrganizationMember{
+		OrganizationId: id,
+		UserId:         user.Id,
+		Role:           req.Role,
+		QuotaLimit:     req.QuotaLimit,
+	}
+	if err = member.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "添加成员失败，该用户可能已是组织成员",
+		})
+		return
+	}
+	member.Username = user.Username
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+}
+
+// getManagedMember returns the member of the organization the user may manage, who holds a lower role,
+// along with the role the user manages the organization with
+func getManagedMember(c *gin.Context, organizationId int, userId int) (*model.OrganizationMember, int, error) {
+	role, err := getManagingRole(c, organizationId)
+	if err != nil {
+		return nil, 0, err
+	}
+	member, err := model.GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if member.Role == model.OrganizationRoleOwner || (member.Role >= role && role != model.OrganizationRoleOwner) {
+		return nil, 0, errors.New("无权管理该成员")
+	}
+	return member, role, nil
+}
+
+func UpdateOrganizationMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	req := organizationMemberRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err == nil {
+		err = validateOrganizationMemberRequest(&req)
+	}
+	var member *model.OrganizationMember
+	var role int
+	if err == nil {
+		member, role, err = getManagedMember(c, id, req.UserId)
+	}
+	if err == nil && req.Role >= role {
+		// only the owner promotes the members to admins
+		err = errors.New("无权设置该角色")
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	member.Role = req.Role
+	member.QuotaLimit = req.QuotaLimit
+	if req.ResetUsedQuota {
+		member.UsedQuota = 0
+	}
+	if err = member.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    member,
+	})
+}
+
+func DeleteOrganizationMember(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userId, _ := strconv.Atoi(c.Param("user_id"))
+	member, _, err := getManagedMember(c, id, userId)
+	if err == nil {
+		err = member.Delete()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetOrganizationTokens(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getManagingRole(c, id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	tokens, err := model.GetOrganizationTokens(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    tokens,
+	})
+}
+
+// GetOrganizationUsage reports the consumption of the organization by member and model, built from the consume logs
+func GetOrganizationUsage(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if _, err := getManagingRole(c, id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	usages, err := model.GetOrganizationUsage(id, startTimestamp, endTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	var quota int64
+	var requestCount int
+	for _, usage := range usages {
+		quota += usage.Quota
+		requestCount += usage.RequestCount
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"quota":         quota,
+			"request_count": requestCount,
+			"usages":        usages,
+		},
+	})
+}
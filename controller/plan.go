@@ -0,0 +1,249 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllPlans(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	plans, err := model.GetAllPlans(p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plans,
+	})
+}
+
+func GetPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	plan, err := model.GetPlanById(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    plan,
+	})
+}
+
+func AddPlan(c *gin.Context) {
+	plan := model.Plan{}
+	err := c.ShouldBindJSON(&plan)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if plan.Period == "" {
+		plan.Period = model.PlanPeriodMonthly
+	}
+	if err = plan.Validate(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanPlan := model.Plan{
+		Name:        plan.Name,
+		Description: plan.Description,
+		Status:      model.PlanStatusEnabled,
+		Quota:       plan.Quota,
+		Period:      plan.Period,
+		Duration:    plan.Duration,
+		Models:      plan.Models,
+		Group:       plan.Group,
+		CreatedTime: helper.GetTimestamp(),
+	}
+	if err = cleanPlan.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanPlan,
+	})
+}
+
+func UpdatePlan(c *gin.Context) {
+	plan := model.Plan{}
+	err := c.ShouldBindJSON(&plan)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = plan.Validate(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanPlan, err := model.GetPlanById(plan.Id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	cleanPlan.Name = plan.Name
+	cleanPlan.Description = plan.Description
+	if plan.Status == model.PlanStatusEnabled || plan.Status == model.PlanStatusDisabled {
+		cleanPlan.Status = plan.Status
+	}
+	// the changes apply to the subscribers from their next period
+	cleanPlan.Quota = plan.Quota
+	cleanPlan.Period = plan.Period
+	cleanPlan.Duration = plan.Duration
+	cleanPlan.Models = plan.Models
+	cleanPlan.Group = plan.Group
+	if err = cleanPlan.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    cleanPlan,
+	})
+}
+
+func DeletePlan(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeletePlanById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSubscription(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	subscription, err := model.GetSubscriptionByUserId(userId)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    subscription,
+	})
+}
+
+type subscribeRequest struct {
+	UserId int `json:"user_id"`
+	PlanId int `json:"plan_id"`
+}
+
+func SubscribeUser(c *gin.Context) {
+	req := subscribeRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil || req.UserId == 0 || req.PlanId == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无效的参数",
+		})
+		return
+	}
+	if err = model.Subscribe(req.UserId, req.PlanId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func UnsubscribeUser(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Param("id"))
+	if err := model.Unsubscribe(userId); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// AutomaticallyResetSubscriptions renews the subscriptions whose period is over and ends the expired ones every minute
+func AutomaticallyResetSubscriptions() {
+	for {
+		time.Sleep(time.Minute)
+		count, err := model.ResetSubscriptions()
+		if err != nil {
+			logger.SysError("failed to reset subscriptions: " + err.Error())
+			continue
+		}
+		if count > 0 {
+			logger.SysLogf("reset %d subscriptions", count)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/adaptor/anthropic"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+)
+
+// https://docs.anthropic.com/en/api/messages
+
+type anthropicConverter struct {
+	*anthropic.StreamConverter
+}
+
+func (a anthropicConverter) ConvertResponse(statusCode int, body []byte) []byte {
+	if statusCode != http.StatusOK {
+		return anthropic.ErrorOpenAI2Claude(statusCode, body)
+	}
+	var textResponse openai.TextResponse
+	if err := json.Unmarshal(body, &textResponse); err != nil {
+		return anthropic.ErrorOpenAI2Claude(http.StatusInternalServerError, body)
+	}
+	jsonResponse, _ := json.Marshal(anthropic.ResponseOpenAI2Claude(&textResponse))
+	return jsonResponse
+}
+
+func (a anthropicConverter) ConvertStreamData(data string) []string {
+	return a.Convert(data)
+}
+
+func (a anthropicConverter) FinishStream() []string {
+	return a.Finish()
+}
+
+func (a anthropicConverter) StreamContentType() string {
+	return "text/event-stream"
+}
+
+// RelayAnthropicMessages serves the anthropic messages api,
+// the request is converted to a chat completions request and relayed as usual
+func RelayAnthropicMessages(c *gin.Context) {
+	var request anthropic.InboundRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		c.Data(http.StatusBadRequest, "application/json", anthropic.ErrorOpenAI2Claude(http.StatusBadRequest, []byte(err.Error())))
+		return
+	}
+	textRequest := anthropic.ConvertInboundRequest(&request)
+	jsonRequest, err := json.Marshal(textRequest)
+	if err != nil {
+		c.Data(http.StatusInternalServerError, "application/json", anthropic.ErrorOpenAI2Claude(http.StatusInternalServerError, []byte(err.Error())))
+		return
+	}
+	defer startAuditLog(c)()
+	c.Set(ctxkey.KeyRequestBody, jsonRequest)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(jsonRequest))
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Request.URL.RawQuery = ""
+
+	promptTokens := openai.CountTokenMessages(textRequest.Messages, textRequest.Model)
+	writer := newInboundWriter(c.Writer, anthropicConverter{anthropic.NewStreamConverter(textRequest.Model, promptTokens)})
+	c.Writer = writer
+	Relay(c)
+	writer.Finish()
+}
+
+// CountAnthropicTokens estimates the input tokens of a messages api request locally
+func CountAnthropicTokens(c *gin.Context) {
+	var request anthropic.InboundRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		c.Data(http.StatusBadRequest, "application/json", anthropic.ErrorOpenAI2Claude(http.StatusBadRequest, []byte(err.Error())))
+		return
+	}
+	textRequest := anthropic.ConvertInboundRequest(&request)
+	c.JSON(http.StatusOK, gin.H{
+		"input_tokens": openai.CountTokenMessages(textRequest.Messages, textRequest.Model),
+	})
+}
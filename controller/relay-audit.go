@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/audit"
+	"github.com/songquanpeng/one-api/relay/controller"
+)
+
+// the chunks of a stream carry much more than the content, so more of the stream is kept for the reassembly
+const streamCaptureFactor = 16
+
+// auditWriter keeps a copy of the response written to the client for the audit log
+type auditWriter struct {
+	gin.ResponseWriter
+	buffer    bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newAuditWriter(writer gin.ResponseWriter) *auditWriter {
+	return &auditWriter{
+		ResponseWriter: writer,
+		limit:          config.AuditLogMaxBodySize * streamCaptureFactor,
+	}
+}
+
+func (w *auditWriter) capture(data []byte) {
+	if w.truncated {
+		return
+	}
+	if remain := w.limit - w.buffer.Len(); w.limit > 0 && len(data) > remain {
+		w.buffer.Write(data[:remain])
+		w.truncated = true
+		return
+	}
+	w.buffer.Write(data)
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// startAuditLog captures the request and the response for the audit log when the request is audited, the returned
+// function records them. The handlers converting the requests of the other apis start it before the conversion,
+// so that the request received from the client and the response finally written to it are kept
+func startAuditLog(c *gin.Context) func() {
+	if c.GetBool(ctxkey.AuditLogStarted) || !audit.ShouldAudit(c.GetString(ctxkey.Group), c.GetBool(ctxkey.AuditLog)) {
+		return func() {}
+	}
+	c.Set(ctxkey.AuditLogStarted, true)
+	// the body is rewritten by the conversions and the fallbacks, the one received is kept
+	requestBody, _ := common.GetRequestBody(c)
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	writer := newAuditWriter(c.Writer)
+	c.Writer = writer
+	startTime := time.Now()
+	return func() {
+		recordAuditLog(c, writer, requestBody, path, startTime)
+	}
+}
+
+// recordAuditLog records the request along with the response written to the client, after all the retries
+func recordAuditLog(c *gin.Context, writer *auditWriter, requestBody []byte, path string, startTime time.Time) {
+	c.Writer = writer.ResponseWriter
+	ctx := c.Request.Context()
+	modelName := c.GetString(ctxkey.OriginalModel)
+	if modelName == "" {
+		modelName = c.GetString(ctxkey.RequestModel)
+	}
+	log := &dbmodel.AuditLog{
+		CreatedAt:  helper.GetTimestamp(),
+		RequestId:  c.GetString(helper.RequestIdKey),
+		UserId:     c.GetInt(ctxkey.Id),
+		TokenId:    c.GetInt(ctxkey.TokenId),
+		TokenName:  c.GetString(ctxkey.TokenName),
+		Group:      c.GetString(ctxkey.Group),
+		ModelName:  modelName,
+		ChannelId:  c.GetInt(ctxkey.ChannelId),
+		Path:       path,
+		StatusCode: writer.Status(),
+		IsStream:   strings.HasPrefix(writer.Header().Get("Content-Type"), "text/event-stream"),
+		Duration:   time.Since(startTime).Milliseconds(),
+	}
+	responseBody := writer.buffer.Bytes()
+	go func() {
+		if log.IsStream {
+			if merged, ok := controller.MergeStreamResponse(responseBody); ok {
+				responseBody = merged
+			}
+		}
+		var responseTruncated bool
+		log.RequestBody, log.RequestTruncated = audit.Sanitize(requestBody)
+		log.ResponseBody, responseTruncated = audit.Sanitize(responseBody)
+		log.ResponseTruncated = responseTruncated || writer.truncated
+		log.Username = dbmodel.GetUsernameById(log.UserId)
+		dbmodel.RecordAuditLog(ctx, log)
+	}()
+}
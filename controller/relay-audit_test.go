@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/anthropic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogOfInboundRequest(t *testing.T) {
+	setupTestDB(t)
+	enabled := config.AuditLogEnabled
+	config.AuditLogEnabled = true
+	t.Cleanup(func() { config.AuditLogEnabled = enabled })
+	requestBody := `{"model":"claude-3-haiku","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}`
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewBufferString(requestBody))
+	c.Set(ctxkey.AuditLog, true)
+
+	// the request is converted and the response converted back the way the anthropic messages api does
+	record := startAuditLog(c)
+	c.Set(ctxkey.KeyRequestBody, []byte(`{"model":"claude-3-haiku","messages":[{"role":"user","content":"hi"}]}`))
+	c.Request.Body = io.NopCloser(bytes.NewBufferString(`{}`))
+	c.Request.URL.Path = "/v1/chat/completions"
+	writer := newInboundWriter(c.Writer, anthropicConverter{anthropic.NewStreamConverter("claude-3-haiku", 1)})
+	c.Writer = writer
+	// the relay does not audit the request again
+	startAuditLog(c)()
+	assert.Same(t, writer, c.Writer)
+	c.Data(http.StatusOK, "application/json", []byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"claude-3-haiku",`+
+		`"choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`))
+	writer.Finish()
+	record()
+
+	var logs []model.AuditLog
+	assert.Eventually(t, func() bool {
+		model.LOG_DB.Find(&logs)
+		return len(logs) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "/v1/messages", logs[0].Path)
+	assert.Equal(t, http.StatusOK, logs[0].StatusCode)
+	assert.JSONEq(t, requestBody, logs[0].RequestBody)
+	assert.Equal(t, recorder.Body.String(), logs[0].ResponseBody)
+	assert.Contains(t, logs[0].ResponseBody, `"type":"message"`)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/adaptor/gemini"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+)
+
+// https://ai.google.dev/api/generate-content
+
+type geminiConverter struct {
+	*gemini.StreamConverter
+	sse bool
+}
+
+func (g geminiConverter) ConvertResponse(statusCode int, body []byte) []byte {
+	if statusCode != http.StatusOK {
+		return gemini.ErrorOpenAI2Gemini(statusCode, body)
+	}
+	var textResponse openai.TextResponse
+	if err := json.Unmarshal(body, &textResponse); err != nil {
+		return gemini.ErrorOpenAI2Gemini(http.StatusInternalServerError, body)
+	}
+	jsonResponse, _ := json.Marshal(gemini.ResponseOpenAI2Gemini(&textResponse))
+	return jsonResponse
+}
+
+func (g geminiConverter) ConvertStreamData(data string) []string {
+	return g.Convert(data)
+}
+
+func (g geminiConverter) FinishStream() []string {
+	return g.Finish()
+}
+
+func (g geminiConverter) StreamContentType() string {
+	if g.sse {
+		return "text/event-stream"
+	}
+	return "application/json"
+}
+
+func abortWithGeminiError(c *gin.Context, statusCode int, message string) {
+	c.Data(statusCode, "application/json", gemini.ErrorOpenAI2Gemini(statusCode, []byte(message)))
+}
+
+// RelayGemini serves the generateContent, streamGenerateContent and countTokens methods of gemini,
+// the request is converted to a chat completions request and relayed as usual
+func RelayGemini(c *gin.Context) {
+	modelName, method, _ := strings.Cut(c.Param("model"), ":")
+	if method != "generateContent" && method != "streamGenerateContent" && method != "countTokens" {
+		abortWithGeminiError(c, http.StatusNotFound, fmt.Sprintf("method %s is not supported", method))
+		return
+	}
+	var request gemini.InboundRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		abortWithGeminiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	textRequest := gemini.ConvertInboundRequest(&request, modelName)
+	promptTokens := openai.CountTokenMessages(textRequest.Messages, textRequest.Model)
+	if method == "countTokens" {
+		c.JSON(http.StatusOK, gin.H{
+			"totalTokens": promptTokens,
+		})
+		return
+	}
+	textRequest.Stream = method == "streamGenerateContent"
+	jsonRequest, err := json.Marshal(textRequest)
+	if err != nil {
+		abortWithGeminiError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sse := c.Query("alt") == "sse"
+	defer startAuditLog(c)()
+	c.Set(ctxkey.KeyRequestBody, jsonRequest)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(jsonRequest))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Request.URL.RawQuery = ""
+
+	writer := newInboundWriter(c.Writer, geminiConverter{gemini.NewStreamConverter(textRequest.Model, promptTokens, sse), sse})
+	c.Writer = writer
+	Relay(c)
+	writer.Finish()
+}
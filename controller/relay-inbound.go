@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inboundConverter converts the openai format responses written by the relay to another protocol,
+// so that clients of other protocols can be served by any channel
+type inboundConverter interface {
+	// ConvertResponse converts a whole non-stream response, including error responses
+	ConvertResponse(statusCode int, body []byte) []byte
+	// ConvertStreamData converts the data of a single openai stream chunk to events of the protocol
+	ConvertStreamData(data string) []string
+	// FinishStream returns the events which should be sent after the openai stream is done
+	FinishStream() []string
+	// StreamContentType returns the content type of the converted stream
+	StreamContentType() string
+}
+
+// inboundWriter intercepts everything written to the client and feeds it to the converter
+type inboundWriter struct {
+	gin.ResponseWriter
+	converter  inboundConverter
+	statusCode int
+	decided    bool
+	isStream   bool
+	finished   bool
+	buffer     bytes.Buffer
+}
+
+func newInboundWriter(writer gin.ResponseWriter, converter inboundConverter) *inboundWriter {
+	return &inboundWriter{
+		ResponseWriter: writer,
+		converter:      converter,
+		statusCode:     http.StatusOK,
+	}
+}
+
+func (w *inboundWriter) WriteHeader(code int) {
+	if code > 0 && !w.decided {
+		w.statusCode = code
+	}
+}
+
+func (w *inboundWriter) WriteHeaderNow() {}
+
+func (w *inboundWriter) Status() int {
+	return w.statusCode
+}
+
+func (w *inboundWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.isStream = w.statusCode == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	if w.isStream {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Type", w.converter.StreamContentType())
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *inboundWriter) Write(data []byte) (int, error) {
+	w.decide()
+	w.buffer.Write(data)
+	if w.isStream {
+		w.convertStreamLines()
+	}
+	return len(data), nil
+}
+
+func (w *inboundWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *inboundWriter) Flush() {
+	if w.isStream {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *inboundWriter) writeEvents(events []string) {
+	for _, event := range events {
+		_, _ = w.ResponseWriter.WriteString(event)
+	}
+	if len(events) > 0 {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *inboundWriter) convertStreamLines() {
+	for {
+		line, err := w.buffer.ReadString('\n')
+		if err != nil {
+			// incomplete line, wait for the rest of it
+			w.buffer.Reset()
+			w.buffer.WriteString(line)
+			return
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			w.finishStream()
+			continue
+		}
+		w.writeEvents(w.converter.ConvertStreamData(data))
+	}
+}
+
+func (w *inboundWriter) finishStream() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	w.writeEvents(w.converter.FinishStream())
+}
+
+// Finish must be called after the relay is done, it writes the converted non-stream response
+func (w *inboundWriter) Finish() {
+	if w.isStream {
+		w.finishStream()
+		return
+	}
+	if w.buffer.Len() == 0 {
+		return
+	}
+	body := w.converter.ConvertResponse(w.statusCode, w.buffer.Bytes())
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	_, _ = w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// firstTokenWriter records the time to the first chunk of stream responses
+type firstTokenWriter struct {
+	gin.ResponseWriter
+	startTime  time.Time
+	firstToken time.Duration
+}
+
+func newFirstTokenWriter(writer gin.ResponseWriter, startTime time.Time) *firstTokenWriter {
+	return &firstTokenWriter{
+		ResponseWriter: writer,
+		startTime:      startTime,
+	}
+}
+
+func (w *firstTokenWriter) observe() {
+	if w.firstToken == 0 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.firstToken = time.Since(w.startTime)
+	}
+}
+
+func (w *firstTokenWriter) Write(data []byte) (int, error) {
+	w.observe()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *firstTokenWriter) WriteString(s string) (int, error) {
+	w.observe()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func recordRelayMetrics(c *gin.Context, writer *firstTokenWriter, err *model.ErrorWithStatusCode, duration time.Duration) {
+	channelId := c.GetInt(ctxkey.ChannelId)
+	modelName := c.GetString(ctxkey.OriginalModel)
+	group := c.GetString(ctxkey.Group)
+	statusCode := http.StatusOK
+	if err != nil {
+		statusCode = err.StatusCode
+	}
+	metrics.RecordRequest(channelId, modelName, group, statusCode, duration)
+	if writer.firstToken > 0 {
+		metrics.RecordFirstToken(channelId, modelName, group, writer.firstToken)
+	}
+}
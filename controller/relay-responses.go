@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+	dbmodel "github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://platform.openai.com/docs/api-reference/responses
+
+type responsesConverter struct {
+	*openai.ResponsesStreamConverter
+}
+
+func (r responsesConverter) ConvertResponse(statusCode int, body []byte) []byte {
+	if statusCode != http.StatusOK {
+		// errors of the responses api share the format of chat completions
+		return body
+	}
+	var textResponse openai.TextResponse
+	if err := json.Unmarshal(body, &textResponse); err != nil {
+		return body
+	}
+	jsonResponse, _ := json.Marshal(r.ConvertTextResponse(&textResponse))
+	return jsonResponse
+}
+
+func (r responsesConverter) ConvertStreamData(data string) []string {
+	return r.Convert(data)
+}
+
+func (r responsesConverter) FinishStream() []string {
+	return r.Finish()
+}
+
+func (r responsesConverter) StreamContentType() string {
+	return "text/event-stream"
+}
+
+func abortWithResponsesError(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": model.Error{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+// RelayResponses serves the responses api, requests are passed through to channels which support it natively,
+// for other channels they are converted to chat completions requests and relayed as usual
+func RelayResponses(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetInt(ctxkey.Id)
+	var request openai.ResponsesRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		abortWithResponsesError(c, http.StatusBadRequest, "invalid_responses_request", err.Error())
+		return
+	}
+	passThrough := openai.SupportResponses(c.GetInt(ctxkey.Channel))
+	var messages []model.Message
+	if request.PreviousResponseId != "" {
+		storedResponse, err := dbmodel.GetStoredResponse(request.PreviousResponseId, userId)
+		if err == nil && storedResponse.IsConverted() {
+			if err = json.Unmarshal([]byte(storedResponse.Messages), &messages); err != nil {
+				abortWithResponsesError(c, http.StatusInternalServerError, "invalid_stored_response", err.Error())
+				return
+			}
+			passThrough = false
+		} else if !passThrough {
+			abortWithResponsesError(c, http.StatusNotFound, "previous_response_not_found", fmt.Sprintf("Previous response with id '%s' not found.", request.PreviousResponseId))
+			return
+		}
+	}
+	if passThrough {
+		Relay(c)
+		return
+	}
+
+	messages = append(messages, openai.ConvertResponsesInput(request.Input)...)
+	textRequest := openai.ConvertResponsesRequest(&request, messages)
+	jsonRequest, err := json.Marshal(textRequest)
+	if err != nil {
+		abortWithResponsesError(c, http.StatusInternalServerError, "convert_request_failed", err.Error())
+		return
+	}
+	defer startAuditLog(c)()
+	c.Set(ctxkey.KeyRequestBody, jsonRequest)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(jsonRequest))
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Request.URL.RawQuery = ""
+
+	promptTokens := openai.CountTokenMessages(textRequest.Messages, textRequest.Model)
+	converter := openai.NewResponsesStreamConverter(&request, promptTokens)
+	writer := newInboundWriter(c.Writer, responsesConverter{converter})
+	c.Writer = writer
+	Relay(c)
+	writer.Finish()
+
+	response := converter.Response()
+	if response == nil || !request.ShouldStore() {
+		return
+	}
+	messages = append(messages, openai.ResponsesOutputMessage(response))
+	jsonMessages, err := json.Marshal(messages)
+	if err != nil {
+		logger.Errorf(ctx, "failed to marshal messages of response %s: %s", response.Id, err.Error())
+		return
+	}
+	storedResponse := &dbmodel.StoredResponse{
+		ResponseId: response.Id,
+		UserId:     userId,
+		ChannelId:  c.GetInt(ctxkey.ChannelId),
+		Messages:   string(jsonMessages),
+	}
+	if err = storedResponse.Insert(); err != nil {
+		logger.Errorf(ctx, "failed to record response %s: %s", response.Id, err.Error())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
@@ -13,21 +14,53 @@ import (
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/middleware"
 	dbmodel "github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/monitor"
+	"github.com/songquanpeng/one-api/monitor/breaker"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/controller"
+	"github.com/songquanpeng/one-api/relay/fallback"
 	"github.com/songquanpeng/one-api/relay/model"
 	"github.com/songquanpeng/one-api/relay/relaymode"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // https://platform.openai.com/docs/api-reference/chat
 
-func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
+// relayHelper relays the request to the selected channel, the attempt is 0 for the first try and counts up for retries
+func relayHelper(c *gin.Context, relayMode int, attempt int) *model.ErrorWithStatusCode {
+	span, endSpan := tracing.StartStage(c, "relay",
+		attribute.Int("relay.mode", relayMode),
+		attribute.Int("retry.attempt", attempt),
+		attribute.Int("channel.id", c.GetInt(ctxkey.ChannelId)),
+		attribute.Int("channel.type", c.GetInt(ctxkey.Channel)),
+		attribute.String("model", c.GetString(ctxkey.OriginalModel)),
+	)
+	defer endSpan()
+	// the slot of the channel is held until the response is relayed. realtime sessions are not counted,
+	// they last as long as the websocket and would keep the other requests waiting for the whole session
+	if relayMode != relaymode.Realtime {
+		release, acquireErr := concurrency.Acquire(c.Request.Context(), c.GetInt(ctxkey.ChannelId), c.GetInt(ctxkey.MaxConcurrency))
+		if acquireErr != nil {
+			tracing.RecordError(span, acquireErr)
+			return openai.ErrorWrapper(acquireErr, "channel_busy", http.StatusTooManyRequests)
+		}
+		defer release()
+	}
+	startTime := time.Now()
+	writer := newFirstTokenWriter(c.Writer, startTime)
+	c.Writer = writer
 	var err *model.ErrorWithStatusCode
 	switch relayMode {
 	case relaymode.ImagesGenerations:
 		err = controller.RelayImageHelper(c, relayMode)
+	case relaymode.ImagesEdits, relaymode.ImagesVariations:
+		err = controller.RelayImageEditHelper(c, relayMode)
 	case relaymode.AudioSpeech:
 		fallthrough
 	case relaymode.AudioTranslation:
@@ -36,39 +69,167 @@ func relayHelper(c *gin.Context, relayMode int) *model.ErrorWithStatusCode {
 		err = controller.RelayAudioHelper(c, relayMode)
 	case relaymode.Proxy:
 		err = controller.RelayProxyHelper(c, relayMode)
+	case relaymode.Files:
+		err = controller.RelayFileHelper(c, relayMode)
+	case relaymode.Batches:
+		err = controller.RelayBatchHelper(c, relayMode)
+	case relaymode.Responses:
+		err = controller.RelayResponsesHelper(c)
+	case relaymode.Realtime:
+		err = controller.RelayRealtimeHelper(c)
+	case relaymode.Rerank:
+		err = controller.RelayRerankHelper(c)
 	default:
 		err = controller.RelayTextHelper(c)
 	}
+	c.Writer = writer.ResponseWriter
+	channelId := c.GetInt(ctxkey.ChannelId)
+	// the responses served from the cache never reached the channel, they tell nothing about its health
+	cacheHit := c.GetBool(ctxkey.ResponseCacheHit)
+	// the duration of realtime sessions tells nothing about the latency
+	if err == nil && relayMode != relaymode.Realtime && !cacheHit {
+		latency := time.Since(startTime)
+		if writer.firstToken > 0 {
+			latency = writer.firstToken
+		}
+		dbmodel.RecordChannelLatency(channelId, latency)
+	}
+	recordRelayMetrics(c, writer, err, time.Since(startTime))
+	if err != nil {
+		span.SetAttributes(attribute.Int("http.status_code", err.StatusCode))
+		span.SetStatus(codes.Error, err.Message)
+	}
+	if (err == nil && !cacheHit) || (err != nil && isChannelFailure(err) && !c.GetBool(ctxkey.RateLimitExceeded)) {
+		// the breakers are kept for the concrete models, which are checked by the channel selection
+		modelName, _ := dbmodel.ResolveModelAlias(c.GetString(ctxkey.OriginalModel))
+		breaker.Record(channelId, modelName, err == nil)
+	}
 	return err
 }
 
+// isChannelFailure reports whether the error tells that the channel is unhealthy, rather than the request is bad
+func isChannelFailure(err *model.ErrorWithStatusCode) bool {
+	switch err.Code {
+	case "unsupported_channel_type", "channel_busy":
+		return false
+	}
+	switch err.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return true
+	}
+	return err.StatusCode/100 == 5
+}
+
 func Relay(c *gin.Context) {
 	ctx := c.Request.Context()
 	relayMode := relaymode.GetByPath(c.Request.URL.Path)
+	// the frames of realtime sessions are not written through the response writer
+	if relayMode != relaymode.Realtime {
+		defer startAuditLog(c)()
+	}
 	if config.DebugEnabled {
 		requestBody, _ := common.GetRequestBody(c)
 		logger.Debugf(ctx, "request body: %s", string(requestBody))
 	}
+	requestId := c.GetString(helper.RequestIdKey)
+	requestModel := c.GetString(ctxkey.RequestModel)
+	if requestModel != "" {
+		ctx = context.WithValue(ctx, helper.RequestModelKey, requestModel)
+		c.Request = c.Request.WithContext(ctx)
+	}
+	if organizationId := c.GetInt(ctxkey.OrganizationId); organizationId != 0 {
+		ctx = context.WithValue(ctx, helper.OrganizationIdKey, organizationId)
+		c.Request = c.Request.WithContext(ctx)
+	}
+	// the model is replaced when it is an alias, or the distributor has picked a fallback model,
+	// as the requested model has no available channel
+	if resolvedModel, _ := dbmodel.ResolveModelAlias(c.GetString(ctxkey.OriginalModel)); resolvedModel != "" && resolvedModel != requestModel {
+		if err := fallback.UseModel(c, resolvedModel); err != nil {
+			bizErr := openai.ErrorWrapper(err, "use_model_failed", http.StatusBadRequest)
+			bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, requestId)
+			c.JSON(bizErr.StatusCode, gin.H{
+				"error": bizErr.Error,
+			})
+			return
+		}
+	}
 	channelId := c.GetInt(ctxkey.ChannelId)
 	userId := c.GetInt(ctxkey.Id)
-	bizErr := relayHelper(c, relayMode)
+	bizErr := relayHelper(c, relayMode, 0)
 	if bizErr == nil {
 		monitor.Emit(channelId, true)
 		return
 	}
-	lastFailedChannelId := channelId
+	if c.GetBool(ctxkey.RateLimitExceeded) {
+		// the request is rejected by the rate limits of the token or the group, the channel is fine
+		bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, requestId)
+		c.JSON(bizErr.StatusCode, gin.H{
+			"error": bizErr.Error,
+		})
+		return
+	}
 	channelName := c.GetString(ctxkey.ChannelName)
 	group := c.GetString(ctxkey.Group)
-	originalModel := c.GetString(ctxkey.OriginalModel)
 	go processChannelRelayError(ctx, userId, channelId, channelName, bizErr)
-	requestId := c.GetString(helper.RequestIdKey)
+	attempt := 0
+	bizErr = retryRelay(c, relayMode, bizErr, &attempt)
+	// the channels of the model are exhausted, the request is re-issued with the next models of the fallback chain
+	if bizErr != nil && shouldRetry(c, bizErr.StatusCode) {
+		for _, fallbackModel := range fallback.GetNextModels(c, requestModel, c.GetString(ctxkey.OriginalModel)) {
+			channel, err := getSatisfiedChannel(relayMode, group, fallbackModel, false)
+			if err != nil {
+				logger.Infof(ctx, "no available channel for fallback model %s", fallbackModel)
+				continue
+			}
+			resolvedModel, _ := dbmodel.ResolveModelAlias(fallbackModel)
+			if err = fallback.UseModel(c, resolvedModel); err != nil {
+				logger.Errorf(ctx, "failed to fall back to model %s: %s", fallbackModel, err.Error())
+				break
+			}
+			logger.Infof(ctx, "falling back to model %s, using channel #%d", fallbackModel, channel.Id)
+			middleware.SetupContextForSelectedChannel(c, channel, fallbackModel)
+			attempt++
+			bizErr = relayHelper(c, relayMode, attempt)
+			if bizErr == nil {
+				return
+			}
+			go processChannelRelayError(ctx, userId, channel.Id, channel.Name, bizErr)
+			if !shouldRetry(c, bizErr.StatusCode) {
+				break
+			}
+			bizErr = retryRelay(c, relayMode, bizErr, &attempt)
+			if bizErr == nil {
+				return
+			}
+		}
+	}
+	if bizErr != nil {
+		if bizErr.StatusCode == http.StatusTooManyRequests {
+			bizErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
+		}
+
+		// BUG: bizErr is in race condition
+		bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, requestId)
+		c.JSON(bizErr.StatusCode, gin.H{
+			"error": bizErr.Error,
+		})
+	}
+}
+
+// retryRelay retries the failed request on the other channels of the same model, the attempt counts the tries made
+func retryRelay(c *gin.Context, relayMode int, bizErr *model.ErrorWithStatusCode, attempt *int) *model.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	userId := c.GetInt(ctxkey.Id)
+	group := c.GetString(ctxkey.Group)
+	originalModel := c.GetString(ctxkey.OriginalModel)
+	lastFailedChannelId := c.GetInt(ctxkey.ChannelId)
 	retryTimes := config.RetryTimes
 	if !shouldRetry(c, bizErr.StatusCode) {
 		logger.Errorf(ctx, "relay error happen, status code is %d, won't retry in this case", bizErr.StatusCode)
 		retryTimes = 0
 	}
 	for i := retryTimes; i > 0; i-- {
-		channel, err := dbmodel.CacheGetRandomSatisfiedChannel(group, originalModel, i != retryTimes)
+		channel, err := getSatisfiedChannel(relayMode, group, originalModel, i != retryTimes)
 		if err != nil {
 			logger.Errorf(ctx, "CacheGetRandomSatisfiedChannel failed: %+v", err)
 			break
@@ -80,9 +241,10 @@ func Relay(c *gin.Context) {
 		middleware.SetupContextForSelectedChannel(c, channel, originalModel)
 		requestBody, err := common.GetRequestBody(c)
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
-		bizErr = relayHelper(c, relayMode)
+		*attempt++
+		bizErr = relayHelper(c, relayMode, *attempt)
 		if bizErr == nil {
-			return
+			return nil
 		}
 		channelId := c.GetInt(ctxkey.ChannelId)
 		lastFailedChannelId = channelId
@@ -90,17 +252,19 @@ func Relay(c *gin.Context) {
 		// BUG: bizErr is in race condition
 		go processChannelRelayError(ctx, userId, channelId, channelName, bizErr)
 	}
-	if bizErr != nil {
-		if bizErr.StatusCode == http.StatusTooManyRequests {
-			bizErr.Error.Message = "当前分组上游负载已饱和，请稍后再试"
-		}
+	return bizErr
+}
 
-		// BUG: bizErr is in race condition
-		bizErr.Error.Message = helper.MessageWithRequestId(bizErr.Error.Message, requestId)
-		c.JSON(bizErr.StatusCode, gin.H{
-			"error": bizErr.Error,
-		})
+// getSatisfiedChannel picks the channel to retry on, the passed through responses api requests
+// and the rerank requests can only be served by the channels implementing them
+func getSatisfiedChannel(relayMode int, group string, modelName string, ignoreFirstPriority bool) (*dbmodel.Channel, error) {
+	switch relayMode {
+	case relaymode.Responses:
+		return dbmodel.CacheGetSatisfiedChannelOfTypes(group, modelName, openai.ResponsesChannelTypes)
+	case relaymode.Rerank:
+		return dbmodel.CacheGetSatisfiedChannelOfTypes(group, modelName, append([]int{channeltype.Cohere}, openai.RerankChannelTypes...))
 	}
+	return dbmodel.CacheGetRandomSatisfiedChannel(group, modelName, ignoreFirstPriority)
 }
 
 func shouldRetry(c *gin.Context, statusCode int) bool {
@@ -124,6 +288,10 @@ func shouldRetry(c *gin.Context, statusCode int) bool {
 
 func processChannelRelayError(ctx context.Context, userId int, channelId int, channelName string, err *model.ErrorWithStatusCode) {
 	logger.Errorf(ctx, "relay error (channel id %d, user id: %d): %s", channelId, userId, err.Message)
+	if err.Code == "channel_busy" {
+		// a saturated channel is not unhealthy
+		return
+	}
 	// https://platform.openai.com/docs/guides/error-codes/api-errors
 	if monitor.ShouldDisableChannel(&err.Error, err.StatusCode) {
 		monitor.DisableChannel(channelId, channelName, err.Message)
@@ -9,6 +9,7 @@ import (
 	"github.com/songquanpeng/one-api/common/network"
 	"github.com/songquanpeng/one-api/common/random"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/fallback"
 	"net/http"
 	"strconv"
 )
@@ -117,6 +118,19 @@ func validateToken(c *gin.Context, token model.Token) error {
 			return fmt.Errorf("无效的网段：%s", err.Error())
 		}
 	}
+	if token.RpmLimit < 0 || token.TpmLimit < 0 {
+		return fmt.Errorf("速率限制不能为负数")
+	}
+	if token.ModelFallback != nil {
+		if _, err := fallback.ParseChains(*token.ModelFallback); err != nil {
+			return fmt.Errorf("无效的模型降级链：%s", err.Error())
+		}
+	}
+	if token.OrganizationId != 0 {
+		if _, err := model.GetOrganizationMember(token.OrganizationId, c.GetInt(ctxkey.Id)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -150,6 +164,12 @@ func AddToken(c *gin.Context) {
 		UnlimitedQuota: token.UnlimitedQuota,
 		Models:         token.Models,
 		Subnet:         token.Subnet,
+		ResponseCache:  token.ResponseCache,
+		RpmLimit:       token.RpmLimit,
+		TpmLimit:       token.TpmLimit,
+		AuditLog:       token.AuditLog,
+		ModelFallback:  token.ModelFallback,
+		OrganizationId: token.OrganizationId,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -239,6 +259,11 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.UnlimitedQuota = token.UnlimitedQuota
 		cleanToken.Models = token.Models
 		cleanToken.Subnet = token.Subnet
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
+		cleanToken.AuditLog = token.AuditLog
+		cleanToken.ModelFallback = token.ModelFallback
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/payment"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+)
+
+const maxPaymentWebhookBodySize = 1 << 20
+
+type createTopUpOrderRequest struct {
+	Amount   int    `json:"amount"` // units of quota, i.e. QuotaPerUnit
+	Provider string `json:"provider"`
+}
+
+func CreateTopUpOrder(c *gin.Context) {
+	req := createTopUpOrderRequest{}
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if req.Amount < config.TopUpMinAmount || req.Amount <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("充值数量不能小于 %d", config.TopUpMinAmount),
+		})
+		return
+	}
+	provider, err := payment.GetProvider(req.Provider)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "不支持的支付方式",
+		})
+		return
+	}
+	userId := c.GetInt(ctxkey.Id)
+	order := &model.TopUpOrder{
+		UserId:      userId,
+		Username:    model.GetUsernameById(userId),
+		TradeNo:     fmt.Sprintf("T%d%s", helper.GetTimestamp(), random.GetRandomNumberString(8)),
+		Provider:    provider.Name(),
+		Amount:      req.Amount,
+		Quota:       int64(float64(req.Amount) * config.QuotaPerUnit),
+		Money:       payment.RoundMoney(float64(req.Amount)*config.TopUpPrice, config.PaymentCurrency),
+		Currency:    config.PaymentCurrency,
+		Status:      model.TopUpOrderStatusPending,
+		CreatedTime: helper.GetTimestamp(),
+	}
+	if err = order.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	payURL, err := provider.CreatePayment(c.Request.Context(), &payment.Order{
+		TradeNo:     order.TradeNo,
+		Money:       order.Money,
+		Currency:    order.Currency,
+		Description: fmt.Sprintf("%s 充值 %d", config.SystemName, order.Amount),
+		ReturnURL:   fmt.Sprintf("%s/topup?trade_no=%s", config.ServerAddress, order.TradeNo),
+		NotifyURL:   fmt.Sprintf("%s/api/payment/webhook/%s", config.ServerAddress, provider.Name()),
+	})
+	if err != nil {
+		logger.Error(c.Request.Context(), fmt.Sprintf("failed to create payment of top-up order %s: %s", order.TradeNo, err.Error()))
+		_ = model.FailTopUpOrder(order)
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "创建支付失败，请稍后重试",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"trade_no": order.TradeNo,
+			"pay_url":  payURL,
+		},
+	})
+}
+
+func getTopUpOrders(c *gin.Context, userId int) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	status, _ := strconv.Atoi(c.Query("status"))
+	orders, err := model.GetTopUpOrders(userId, status, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    orders,
+	})
+}
+
+func GetUserTopUpOrders(c *gin.Context) {
+	getTopUpOrders(c, c.GetInt(ctxkey.Id))
+}
+
+func GetAllTopUpOrders(c *gin.Context) {
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	getTopUpOrders(c, userId)
+}
+
+// PaymentWebhook handles the notifications of the providers, the quota is credited once however many times
+// a notification is delivered
+func PaymentWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	provider, err := payment.GetProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	event, err := provider.VerifyWebhook(c.Request.Header, body)
+	if err != nil {
+		logger.Warnf(ctx, "rejected %s payment notification: %s", provider.Name(), err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if !event.Paid && !event.Failed {
+		// the other notifications are acknowledged, so that they are not delivered again
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "",
+		})
+		return
+	}
+	order, err := model.GetTopUpOrderByTradeNo(event.TradeNo)
+	if err != nil || order.Provider != provider.Name() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "订单不存在",
+		})
+		return
+	}
+	if event.Failed {
+		err = model.FailTopUpOrder(order)
+	} else if event.Money+0.005 < order.Money {
+		logger.Errorf(ctx, "top-up order %s is paid %.2f, less than %.2f", order.TradeNo, event.Money, order.Money)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "支付金额不符",
+		})
+		return
+	} else {
+		var credited bool
+		credited, err = model.CompleteTopUpOrder(order, event.ProviderTradeNo)
+		if credited {
+			logger.Infof(ctx, "top-up order %s is paid, credited %d quota to user %d", order.TradeNo, order.Quota, order.UserId)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
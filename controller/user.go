@@ -353,10 +353,21 @@ func GetSelf(c *gin.Context) {
 		})
 		return
 	}
+	subscription, err := model.GetSubscriptionByUserId(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    user,
+		"data": struct {
+			*model.User
+			Subscription *model.Subscription `json:"subscription,omitempty"`
+		}{user, subscription},
 	})
 	return
 }
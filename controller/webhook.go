@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+)
+
+func GetAllWebhooks(c *gin.Context) {
+	hooks, err := model.GetAllWebhooks()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    hooks,
+	})
+}
+
+func GetWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.WebhookEvents,
+	})
+}
+
+// AddWebhook creates the endpoint, a secret is generated when none is given, it is only shown in the response
+func AddWebhook(c *gin.Context) {
+	hook := model.Webhook{}
+	err := c.ShouldBindJSON(&hook)
+	if err == nil {
+		err = hook.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if hook.Secret == "" {
+		hook.Secret = random.GetRandomString(32)
+	}
+	if err = hook.Insert(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    hook,
+	})
+}
+
+// UpdateWebhook updates the endpoint, the secret is kept when none is given
+func UpdateWebhook(c *gin.Context) {
+	hook := model.Webhook{}
+	err := c.ShouldBindJSON(&hook)
+	if err == nil {
+		err = hook.Validate()
+	}
+	var origin *model.Webhook
+	if err == nil {
+		origin, err = model.GetWebhookById(hook.Id)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if hook.Secret == "" {
+		hook.Secret = origin.Secret
+	}
+	if err = hook.Update(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	hook.Secret = ""
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    hook,
+	})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteWebhookById(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	webhookId, _ := strconv.Atoi(c.Query("webhook_id"))
+	status, _ := strconv.Atoi(c.Query("status"))
+	deliveries, err := model.GetWebhookDeliveries(webhookId, c.Query("event"), status, p*config.ItemsPerPage, config.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    deliveries,
+	})
+}
+
+func AutomaticallyRetryWebhookDeliveries() {
+	for {
+		time.Sleep(30 * time.Second)
+		count, err := model.RetryWebhookDeliveries()
+		if err != nil {
+			logger.SysError("failed to retry webhook deliveries: " + err.Error())
+			continue
+		}
+		if count > 0 {
+			logger.SysLogf("retried %d webhook deliveries", count)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"fmt"
 	"github.com/gin-contrib/sessions"
@@ -11,6 +12,7 @@ import (
 	"github.com/songquanpeng/one-api/common/client"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/controller"
 	"github.com/songquanpeng/one-api/middleware"
 	"github.com/songquanpeng/one-api/model"
@@ -80,6 +82,18 @@ func main() {
 		}
 		go controller.AutomaticallyTestChannels(frequency)
 	}
+	if config.IsMasterNode && config.BatchPollingFrequency > 0 {
+		go controller.AutomaticallySettleBatches(config.BatchPollingFrequency)
+	}
+	if config.IsMasterNode {
+		go controller.AutomaticallyCleanAuditLogs()
+		go controller.AutomaticallyResetSubscriptions()
+		go controller.AutomaticallyRetryWebhookDeliveries()
+	}
+	go model.SyncBudgets(config.BudgetSyncFrequency)
+	if config.MetricsEnabled && config.MetricsToken != "" {
+		go controller.AutomaticallyUpdateMetrics(config.MetricsRefreshFrequency)
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		config.BatchUpdateEnabled = true
 		logger.SysLog("batch update enabled with interval " + strconv.Itoa(config.BatchUpdateInterval) + "s")
@@ -90,12 +104,23 @@ func main() {
 	}
 	openai.InitTokenEncoders()
 	client.Init()
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logger.FatalLog("failed to initialize tracing: " + err.Error())
+	}
+	defer func() {
+		_ = shutdownTracing(context.Background())
+	}()
+	if config.TracingEnabled {
+		logger.SysLog("tracing enabled")
+	}
 
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.Recovery())
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
+	server.Use(middleware.Tracing())
 	server.Use(middleware.RequestId())
 	middleware.SetUpLogger(server)
 	// Initialize session store
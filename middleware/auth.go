@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/songquanpeng/one-api/common/blacklist"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/network"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/model"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 	"strings"
 )
@@ -91,7 +94,30 @@ func RootAuth() func(c *gin.Context) {
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
+		// the span covers the authentication only, it is ended before the next handlers run
+		_, span := tracing.Start(ctx, "middleware.TokenAuth")
+		defer span.End()
 		key := c.Request.Header.Get("Authorization")
+		if key == "" {
+			// anthropic sdk sends the key in x-api-key
+			key = c.Request.Header.Get("x-api-key")
+		}
+		if key == "" {
+			// google genai sdk sends the key in x-goog-api-key or the key query, the query is only taken on the gemini
+			// routes, elsewhere it would leak into the access logs of the proxies in between
+			key = c.Request.Header.Get("x-goog-api-key")
+			if key == "" && strings.HasPrefix(c.Request.URL.Path, "/v1beta/") {
+				key = c.Query("key")
+			}
+		}
+		if key == "" {
+			// browsers cannot set headers for websockets, the realtime api accepts the key as a subprotocol
+			for _, protocol := range websocket.Subprotocols(c.Request) {
+				if strings.HasPrefix(protocol, "openai-insecure-api-key.") {
+					key = strings.TrimPrefix(protocol, "openai-insecure-api-key.")
+				}
+			}
+		}
 		key = strings.TrimPrefix(key, "Bearer ")
 		key = strings.TrimPrefix(key, "sk-")
 		parts := strings.Split(key, "-")
@@ -129,9 +155,35 @@ func TokenAuth() func(c *gin.Context) {
 				return
 			}
 		}
+		planModels, err := model.CacheGetUserPlanModels(token.UserId)
+		if err != nil {
+			abortWithMessage(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if planModels != "" {
+			c.Set(ctxkey.AvailableModels, intersectModels(c.GetString(ctxkey.AvailableModels), planModels))
+			if requestModel != "" && !isModelInList(requestModel, planModels) {
+				abortWithMessage(c, http.StatusForbidden, fmt.Sprintf("当前订阅套餐无权使用模型：%s", requestModel))
+				return
+			}
+		}
 		c.Set(ctxkey.Id, token.UserId)
 		c.Set(ctxkey.TokenId, token.Id)
 		c.Set(ctxkey.TokenName, token.Name)
+		c.Set(ctxkey.ResponseCache, token.ResponseCache)
+		c.Set(ctxkey.TokenRpmLimit, token.RpmLimit)
+		c.Set(ctxkey.TokenTpmLimit, token.TpmLimit)
+		c.Set(ctxkey.AuditLog, token.AuditLog)
+		if token.ModelFallback != nil {
+			c.Set(ctxkey.ModelFallback, *token.ModelFallback)
+		}
+		if token.OrganizationId != 0 {
+			if _, err := model.GetOrganizationMember(token.OrganizationId, token.UserId); err != nil {
+				abortWithMessage(c, http.StatusForbidden, err.Error())
+				return
+			}
+			c.Set(ctxkey.OrganizationId, token.OrganizationId)
+		}
 		if len(parts) > 1 {
 			if model.IsAdmin(token.UserId) {
 				c.Set(ctxkey.SpecificChannelId, parts[1])
@@ -146,6 +198,8 @@ func TokenAuth() func(c *gin.Context) {
 			c.Set(ctxkey.SpecificChannelId, channelId)
 		}
 
+		span.SetAttributes(attribute.Int("user.id", token.UserId), attribute.Int("token.id", token.Id))
+		span.End()
 		c.Next()
 	}
 }
@@ -163,5 +217,20 @@ func shouldCheckModel(c *gin.Context) bool {
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		return true
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/messages") {
+		return true
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/responses") {
+		return true
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
+		return true
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/rerank") {
+		return true
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models") {
+		return true
+	}
 	return false
 }
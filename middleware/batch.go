@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"net/http"
+	"strconv"
+)
+
+// BatchAffinity routes batch requests to the channel which stores the input file or the batch itself
+func BatchAffinity() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		userId := c.GetInt(ctxkey.Id)
+		if batchId := c.Param("id"); batchId != "" {
+			batch, err := model.GetBatchByBatchId(batchId, userId)
+			if err != nil {
+				abortWithMessage(c, http.StatusNotFound, "批处理任务不存在："+batchId)
+				return
+			}
+			c.Set(ctxkey.SpecificChannelId, strconv.Itoa(batch.ChannelId))
+			c.Next()
+			return
+		}
+		var batchRequest relaymodel.BatchRequest
+		err := common.UnmarshalBodyReusable(c, &batchRequest)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		file, err := model.GetFileByFileId(batchRequest.InputFileId, userId)
+		if err != nil {
+			abortWithMessage(c, http.StatusNotFound, "文件不存在："+batchRequest.InputFileId)
+			return
+		}
+		c.Set(ctxkey.SpecificChannelId, strconv.Itoa(file.ChannelId))
+		c.Next()
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/fallback"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"go.opentelemetry.io/otel/attribute"
 	"net/http"
 	"strconv"
 )
@@ -17,10 +22,18 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		// the span covers the channel selection only, it is ended before the next handlers run
+		_, span := tracing.Start(c.Request.Context(), "middleware.Distribute")
+		defer span.End()
 		userId := c.GetInt(ctxkey.Id)
 		userGroup, _ := model.CacheGetUserGroup(userId)
 		c.Set(ctxkey.Group, userGroup)
+		if err := model.CheckBudgets(userId, c.GetInt(ctxkey.TokenId), userGroup); err != nil {
+			abortWithMessage(c, http.StatusForbidden, err.Error())
+			return
+		}
 		var requestModel string
+		var modelName string
 		var channel *model.Channel
 		channelId, ok := c.Get(ctxkey.SpecificChannelId)
 		if ok {
@@ -40,8 +53,21 @@ func Distribute() func(c *gin.Context) {
 			}
 		} else {
 			requestModel = c.GetString(ctxkey.RequestModel)
+			modelName = requestModel
 			var err error
-			channel, err = model.CacheGetRandomSatisfiedChannel(userGroup, requestModel, false)
+			relayMode := relaymode.GetByPath(c.Request.URL.Path)
+			channel, err = getRandomSatisfiedChannel(relayMode, userGroup, requestModel)
+			if err != nil && channel == nil {
+				// the channels of the requested model are all unavailable, the request is taken over by the fallback chain
+				for _, fallbackModel := range fallback.GetNextModels(c, requestModel, requestModel) {
+					if channel, err = getRandomSatisfiedChannel(relayMode, userGroup, fallbackModel); err == nil {
+						logger.Infof(c.Request.Context(), "no available channel for model %s, falling back to model %s", requestModel, fallbackModel)
+						modelName = fallbackModel
+						break
+					}
+					channel = nil
+				}
+			}
 			if err != nil {
 				message := fmt.Sprintf("当前分组 %s 下对于模型 %s 无可用渠道", userGroup, requestModel)
 				if channel != nil {
@@ -52,15 +78,32 @@ func Distribute() func(c *gin.Context) {
 				return
 			}
 		}
-		SetupContextForSelectedChannel(c, channel, requestModel)
+		SetupContextForSelectedChannel(c, channel, modelName)
+		span.SetAttributes(
+			attribute.String("group", userGroup),
+			attribute.String("model", modelName),
+			attribute.Int("channel.id", channel.Id),
+			attribute.Int("channel.type", channel.Type),
+		)
+		span.End()
 		c.Next()
 	}
 }
 
+// getRandomSatisfiedChannel picks the first channel of the request, the rerank requests can only be served by
+// the channels implementing the rerank api
+func getRandomSatisfiedChannel(relayMode int, group string, modelName string) (*model.Channel, error) {
+	if relayMode == relaymode.Rerank {
+		return model.CacheGetSatisfiedChannelOfTypes(group, modelName, append([]int{channeltype.Cohere}, openai.RerankChannelTypes...))
+	}
+	return model.CacheGetRandomSatisfiedChannel(group, modelName, false)
+}
+
 func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) {
 	c.Set(ctxkey.Channel, channel.Type)
 	c.Set(ctxkey.ChannelId, channel.Id)
 	c.Set(ctxkey.ChannelName, channel.Name)
+	c.Set(ctxkey.MaxConcurrency, channel.GetMaxConcurrency())
 	c.Set(ctxkey.ModelMapping, channel.GetModelMapping())
 	c.Set(ctxkey.OriginalModel, modelName) // for retry
 	c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", channel.Key))
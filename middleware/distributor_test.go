@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/fallback"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB opens an in-memory database for the test, the channels are picked from the database
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Channel{}, &model.Ability{}, &model.Budget{}))
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled = false
+	usingSQLite, memoryCacheEnabled := common.UsingSQLite, config.MemoryCacheEnabled
+	common.UsingSQLite, config.MemoryCacheEnabled = true, false
+	t.Cleanup(func() {
+		common.UsingSQLite, config.MemoryCacheEnabled = usingSQLite, memoryCacheEnabled
+	})
+	require.NoError(t, db.Create(&model.User{Id: 1, Username: "user", Group: "default", Status: model.UserStatusEnabled}).Error)
+}
+
+func addChannel(t *testing.T, channelType int, models string) *model.Channel {
+	channel := &model.Channel{Type: channelType, Name: "channel", Key: "sk-test", Status: model.ChannelStatusEnabled, Group: "default", Models: models}
+	require.NoError(t, channel.Insert())
+	return channel
+}
+
+func distribute(path string, requestModel string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, path, strings.NewReader(fmt.Sprintf(`{"model":%q}`, requestModel)))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(ctxkey.Id, 1)
+	c.Set(ctxkey.RequestModel, requestModel)
+	Distribute()(c)
+	return c
+}
+
+func TestDistributeRerank(t *testing.T) {
+	setupTestDB(t)
+	addChannel(t, channeltype.OpenAI, "rerank-v1,rerank-v2")
+	addChannel(t, channeltype.Azure, "rerank-v1")
+	jina := addChannel(t, channeltype.Jina, "rerank-v1")
+
+	// the rerank requests never land on the channels without the rerank api
+	for i := 0; i < 20; i++ {
+		c := distribute("/v1/rerank", "rerank-v1")
+		require.False(t, c.IsAborted())
+		assert.Equal(t, jina.Id, c.GetInt(ctxkey.ChannelId))
+	}
+	c := distribute("/v1/rerank", "rerank-v2")
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusServiceUnavailable, c.Writer.Status())
+
+	// the fallback models are picked the same way
+	groupModelFallback := fallback.GroupModelFallback2JSONString()
+	require.NoError(t, fallback.UpdateGroupModelFallbackByJSONString(`{"default":{"rerank-v2":["rerank-v1"]}}`))
+	t.Cleanup(func() { _ = fallback.UpdateGroupModelFallbackByJSONString(groupModelFallback) })
+	for i := 0; i < 20; i++ {
+		c = distribute("/v1/rerank", "rerank-v2")
+		require.False(t, c.IsAborted())
+		assert.Equal(t, jina.Id, c.GetInt(ctxkey.ChannelId))
+		assert.Equal(t, "rerank-v1", c.GetString(ctxkey.OriginalModel))
+	}
+
+	// the other requests may use any channel of the model
+	c = distribute("/v1/chat/completions", "rerank-v2")
+	require.False(t, c.IsAborted())
+	assert.NotEqual(t, jina.Id, c.GetInt(ctxkey.ChannelId))
+}
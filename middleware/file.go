@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"net/http"
+	"strconv"
+)
+
+// fileChannelTypes are the channel types serving the files api
+var fileChannelTypes = []int{channeltype.OpenAI, channeltype.Azure}
+
+// FileAffinity routes requests referencing an uploaded file to the channel which stores it
+func FileAffinity() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		fileId := c.Param("id")
+		if fileId == "" {
+			c.Next()
+			return
+		}
+		file, err := model.GetFileByFileId(fileId, c.GetInt(ctxkey.Id))
+		if err != nil {
+			abortWithMessage(c, http.StatusNotFound, "文件不存在："+fileId)
+			return
+		}
+		c.Set(ctxkey.SpecificChannelId, strconv.Itoa(file.ChannelId))
+		c.Next()
+	}
+}
+
+// FileUploadChannel routes the uploads, which carry no model, to an OpenAI or Azure channel of the group of the user
+func FileUploadChannel() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(ctxkey.SpecificChannelId); ok {
+			c.Next()
+			return
+		}
+		userGroup, _ := model.CacheGetUserGroup(c.GetInt(ctxkey.Id))
+		channel, err := model.GetRandomChannelOfTypes(userGroup, fileChannelTypes)
+		if err != nil {
+			abortWithMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("当前分组 %s 下没有支持文件接口的渠道", userGroup))
+			return
+		}
+		c.Set(ctxkey.SpecificChannelId, strconv.Itoa(channel.Id))
+		c.Next()
+	}
+}
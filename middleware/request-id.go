@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/helper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func RequestId() func(c *gin.Context) {
@@ -13,6 +15,8 @@ func RequestId() func(c *gin.Context) {
 		ctx := context.WithValue(c.Request.Context(), helper.RequestIdKey, id)
 		c.Request = c.Request.WithContext(ctx)
 		c.Header(helper.RequestIdKey, id)
+		// link the trace to the request id, which is shown to the users
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request_id", id))
 		c.Next()
 	}
 }
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/model"
+	"net/http"
+	"strconv"
+)
+
+// ResponseAffinity routes responses api requests continuing a passed through response to the channel which stores it,
+// converted responses are stored locally and can be continued by any channel
+func ResponseAffinity() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		var request struct {
+			PreviousResponseId string `json:"previous_response_id"`
+		}
+		err := common.UnmarshalBodyReusable(c, &request)
+		if err != nil {
+			abortWithMessage(c, http.StatusBadRequest, "无效的请求："+err.Error())
+			return
+		}
+		if request.PreviousResponseId == "" {
+			c.Next()
+			return
+		}
+		response, err := model.GetStoredResponse(request.PreviousResponseId, c.GetInt(ctxkey.Id))
+		if err == nil && !response.IsConverted() {
+			c.Set(ctxkey.SpecificChannelId, strconv.Itoa(response.ChannelId))
+		}
+		c.Next()
+	}
+}
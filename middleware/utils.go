@@ -36,7 +36,7 @@ func getRequestModel(c *gin.Context) (string, error) {
 			modelRequest.Model = c.Param("model")
 		}
 	}
-	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/") {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "dall-e-2"
 		}
@@ -46,6 +46,10 @@ func getRequestModel(c *gin.Context) (string, error) {
 			modelRequest.Model = "whisper-1"
 		}
 	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1beta/models/") {
+		// the model is part of the path, e.g. /v1beta/models/gemini-1.5-pro:generateContent
+		modelRequest.Model, _, _ = strings.Cut(c.Param("model"), ":")
+	}
 	return modelRequest.Model, nil
 }
 
@@ -58,3 +62,17 @@ func isModelInList(modelName string, models string) bool {
 	}
 	return false
 }
+
+// intersectModels returns the models in both lists, an empty list means no restriction
+func intersectModels(models string, otherModels string) string {
+	if models == "" {
+		return otherModels
+	}
+	var intersection []string
+	for _, m := range strings.Split(models, ",") {
+		if isModelInList(m, otherModels) {
+			intersection = append(intersection, m)
+		}
+	}
+	return strings.Join(intersection, ",")
+}
@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
 	"gorm.io/gorm"
 	"sort"
 	"strings"
@@ -17,6 +19,8 @@ type Ability struct {
 }
 
 func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority bool) (*Channel, error) {
+	// aliases are served by the channels of the concrete model
+	model, channelIds := ResolveModelAlias(model)
 	ability := Ability{}
 	groupCol := "`group`"
 	trueVal := "1"
@@ -31,8 +35,20 @@ func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority b
 		channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
 	} else {
 		maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+		if len(channelIds) > 0 {
+			maxPrioritySubQuery = maxPrioritySubQuery.Where("channel_id in ?", channelIds)
+		}
 		channelQuery = DB.Where(groupCol+" = ? and model = ? and enabled = "+trueVal+" and priority = (?)", group, model, maxPrioritySubQuery)
 	}
+	if len(channelIds) > 0 {
+		channelQuery = channelQuery.Where("channel_id in ?", channelIds)
+	}
+	// the query may be run twice, when the randomly picked channel is saturated
+	channelQuery = channelQuery.Session(&gorm.Session{})
+	if GetChannelSelection(group) != ChannelSelectionRandom || config.CircuitBreakerEnabled {
+		// the other strategies and the circuit breaker need to know all the candidates
+		return selectSatisfiedChannel(channelQuery, group, model)
+	}
 	if common.UsingSQLite || common.UsingPostgreSQL {
 		err = channelQuery.Order("RANDOM()").First(&ability).Error
 	} else {
@@ -44,9 +60,34 @@ func GetRandomSatisfiedChannel(group string, model string, ignoreFirstPriority b
 	channel := Channel{}
 	channel.Id = ability.ChannelId
 	err = DB.First(&channel, "id = ?", ability.ChannelId).Error
+	if err == nil && concurrency.IsSaturated(channel.Id, channel.GetMaxConcurrency()) {
+		// look for an idle channel among the others
+		return selectSatisfiedChannel(channelQuery, group, model)
+	}
 	return &channel, err
 }
 
+func selectSatisfiedChannel(channelQuery *gorm.DB, group string, model string) (*Channel, error) {
+	var channelIds []int
+	err := channelQuery.Model(&Ability{}).Pluck("channel_id", &channelIds).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(channelIds) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var channels []*Channel
+	err = DB.Where("id in ?", channelIds).Order("id").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	channels = filterAvailableChannels(model, channels)
+	if len(channels) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return selectChannel(group, model, channels), nil
+}
+
 func (channel *Channel) AddAbilities() error {
 	models_ := strings.Split(channel.Models, ",")
 	groups_ := strings.Split(channel.Group, ",")
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// ModelAlias is a virtual model name which resolves to a concrete model,
+// the requests are served by the given channels only when they are set
+type ModelAlias struct {
+	Model    string `json:"model"`
+	Channels []int  `json:"channels,omitempty"`
+}
+
+var (
+	modelAliases     = make(map[string]ModelAlias)
+	modelAliasesLock sync.RWMutex
+)
+
+func ModelAliases2JSONString() string {
+	modelAliasesLock.RLock()
+	defer modelAliasesLock.RUnlock()
+	jsonBytes, err := json.Marshal(modelAliases)
+	if err != nil {
+		logger.SysError("error marshalling model aliases: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateModelAliasesByJSONString(jsonStr string) error {
+	newModelAliases := make(map[string]ModelAlias)
+	err := json.Unmarshal([]byte(jsonStr), &newModelAliases)
+	if err != nil {
+		return err
+	}
+	for name, alias := range newModelAliases {
+		if alias.Model == "" {
+			return fmt.Errorf("the model of alias %s is empty", name)
+		}
+		// aliases do not chain, so that an alias always resolves in one step
+		if _, ok := newModelAliases[alias.Model]; ok {
+			return fmt.Errorf("alias %s resolves to another alias %s", name, alias.Model)
+		}
+	}
+	modelAliasesLock.Lock()
+	modelAliases = newModelAliases
+	modelAliasesLock.Unlock()
+	return nil
+}
+
+// ResolveModelAlias returns the concrete model of the name along with the channels serving it,
+// names which are not aliases resolve to themselves and can be served by any channel
+func ResolveModelAlias(name string) (string, []int) {
+	modelAliasesLock.RLock()
+	defer modelAliasesLock.RUnlock()
+	alias, ok := modelAliases[name]
+	if !ok {
+		return name, nil
+	}
+	return alias.Model, alias.Channels
+}
+
+// GetModelAliasesOf returns the aliases which resolve to any of the models in order
+func GetModelAliasesOf(models []string) []string {
+	modelSet := make(map[string]bool, len(models))
+	for _, m := range models {
+		modelSet[m] = true
+	}
+	modelAliasesLock.RLock()
+	defer modelAliasesLock.RUnlock()
+	var aliases []string
+	for name, alias := range modelAliases {
+		if modelSet[alias.Model] {
+			aliases = append(aliases, name)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+func filterChannelsByIds(channels []*Channel, channelIds []int) []*Channel {
+	if len(channelIds) == 0 {
+		return channels
+	}
+	idSet := make(map[int]bool, len(channelIds))
+	for _, id := range channelIds {
+		idSet[id] = true
+	}
+	filtered := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if idSet[channel.Id] {
+			filtered = append(filtered, channel)
+		}
+	}
+	return filtered
+}
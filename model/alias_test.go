@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setModelAliases(t *testing.T, jsonStr string) {
+	old := ModelAliases2JSONString()
+	require.NoError(t, UpdateModelAliasesByJSONString(jsonStr))
+	t.Cleanup(func() { _ = UpdateModelAliasesByJSONString(old) })
+}
+
+func TestUpdateModelAliasesByJSONString(t *testing.T) {
+	setModelAliases(t, `{"smart":{"model":"gpt-4o","channels":[1,2]},"fast":{"model":"gpt-4o-mini"}}`)
+	modelName, channelIds := ResolveModelAlias("smart")
+	assert.Equal(t, "gpt-4o", modelName)
+	assert.Equal(t, []int{1, 2}, channelIds)
+	modelName, channelIds = ResolveModelAlias("fast")
+	assert.Equal(t, "gpt-4o-mini", modelName)
+	assert.Empty(t, channelIds)
+	modelName, channelIds = ResolveModelAlias("gpt-4o")
+	assert.Equal(t, "gpt-4o", modelName)
+	assert.Nil(t, channelIds)
+
+	// an invalid alias leaves the aliases untouched
+	assert.Error(t, UpdateModelAliasesByJSONString(`{"smart":{"model":""}}`))
+	assert.Error(t, UpdateModelAliasesByJSONString(`{"smart":{"model":"fast"},"fast":{"model":"gpt-4o-mini"}}`))
+	assert.Error(t, UpdateModelAliasesByJSONString(`not json`))
+	modelName, _ = ResolveModelAlias("smart")
+	assert.Equal(t, "gpt-4o", modelName)
+}
+
+func TestGetModelAliasesOf(t *testing.T) {
+	setModelAliases(t, `{"smart":{"model":"gpt-4o"},"best":{"model":"gpt-4o"},"fast":{"model":"gpt-4o-mini"},"claude":{"model":"claude-3-5-sonnet"}}`)
+	assert.Equal(t, []string{"best", "fast", "smart"}, GetModelAliasesOf([]string{"gpt-4o", "gpt-4o-mini"}))
+	assert.Equal(t, []string{"claude"}, GetModelAliasesOf([]string{"claude-3-5-sonnet"}))
+	assert.Empty(t, GetModelAliasesOf([]string{"gemini-1.5-pro"}))
+	assert.Empty(t, GetModelAliasesOf(nil))
+}
+
+func TestFilterChannelsByIds(t *testing.T) {
+	channels := []*Channel{{Id: 1}, {Id: 2}, {Id: 3}}
+	assert.Equal(t, channels, filterChannelsByIds(channels, nil))
+	assert.Equal(t, []*Channel{channels[0], channels[2]}, filterChannelsByIds(channels, []int{3, 1, 4}))
+	assert.Empty(t, filterChannelsByIds(channels, []int{4}))
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"context"
+
+	"github.com/songquanpeng/one-api/common/logger"
+	"gorm.io/gorm"
+)
+
+// AuditLog records the bodies of a relayed request, stream responses are reassembled when it is possible.
+// It is stored in the log database along with the logs.
+type AuditLog struct {
+	Id                int    `json:"id"`
+	CreatedAt         int64  `json:"created_at" gorm:"bigint;index"`
+	RequestId         string `json:"request_id" gorm:"type:varchar(64);index;default:''"`
+	UserId            int    `json:"user_id" gorm:"index"`
+	Username          string `json:"username" gorm:"index;default:''"`
+	TokenId           int    `json:"token_id" gorm:"default:0"`
+	TokenName         string `json:"token_name" gorm:"index;default:''"`
+	Group             string `json:"group" gorm:"default:''"`
+	ModelName         string `json:"model_name" gorm:"index;default:''"`
+	ChannelId         int    `json:"channel" gorm:"index"`
+	Path              string `json:"path" gorm:"default:''"`
+	StatusCode        int    `json:"status_code" gorm:"default:0"`
+	IsStream          bool   `json:"is_stream" gorm:"default:false"`
+	Duration          int64  `json:"duration" gorm:"bigint;default:0"` // unit is millisecond
+	RequestBody       string `json:"request_body,omitempty"`
+	ResponseBody      string `json:"response_body,omitempty"`
+	RequestTruncated  bool   `json:"request_truncated" gorm:"default:false"`
+	ResponseTruncated bool   `json:"response_truncated" gorm:"default:false"`
+}
+
+type AuditLogFilter struct {
+	StartTimestamp int64
+	EndTimestamp   int64
+	Username       string
+	TokenName      string
+	ModelName      string
+	RequestId      string
+	Channel        int
+	// Keyword is searched in the bodies
+	Keyword string
+}
+
+func RecordAuditLog(ctx context.Context, log *AuditLog) {
+	err := LOG_DB.Create(log).Error
+	if err != nil {
+		logger.Error(ctx, "failed to record audit log: "+err.Error())
+	}
+}
+
+func (filter *AuditLogFilter) apply(tx *gorm.DB) *gorm.DB {
+	if filter.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", filter.EndTimestamp)
+	}
+	if filter.Username != "" {
+		tx = tx.Where("username = ?", filter.Username)
+	}
+	if filter.TokenName != "" {
+		tx = tx.Where("token_name = ?", filter.TokenName)
+	}
+	if filter.ModelName != "" {
+		tx = tx.Where("model_name = ?", filter.ModelName)
+	}
+	if filter.RequestId != "" {
+		tx = tx.Where("request_id = ?", filter.RequestId)
+	}
+	if filter.Channel != 0 {
+		tx = tx.Where("channel_id = ?", filter.Channel)
+	}
+	if filter.Keyword != "" {
+		tx = tx.Where("request_body LIKE ? or response_body LIKE ?", "%"+filter.Keyword+"%", "%"+filter.Keyword+"%")
+	}
+	return tx
+}
+
+// GetAuditLogs lists the audit logs without the bodies
+func GetAuditLogs(filter *AuditLogFilter, startIdx int, num int) (logs []*AuditLog, err error) {
+	tx := filter.apply(LOG_DB.Model(&AuditLog{}))
+	err = tx.Omit("request_body", "response_body").Order("id desc").Limit(num).Offset(startIdx).Find(&logs).Error
+	return logs, err
+}
+
+func GetAuditLogById(id int) (*AuditLog, error) {
+	log := AuditLog{}
+	err := LOG_DB.First(&log, "id = ?", id).Error
+	return &log, err
+}
+
+// ExportAuditLogs walks through the audit logs matching the filter in batches, the walk stops when fn fails
+func ExportAuditLogs(filter *AuditLogFilter, batchSize int, fn func(logs []*AuditLog) error) error {
+	var logs []*AuditLog
+	return filter.apply(LOG_DB.Model(&AuditLog{})).FindInBatches(&logs, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(logs)
+	}).Error
+}
+
+func DeleteOldAuditLog(targetTimestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
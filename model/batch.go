@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"github.com/songquanpeng/one-api/common/helper"
+	"gorm.io/gorm"
+)
+
+// Batch records a batch created through the batch api,
+// its quota is settled once the upstream batch reaches a final status
+type Batch struct {
+	Id           int    `json:"id"`
+	BatchId      string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"` // id returned by upstream
+	UserId       int    `json:"user_id" gorm:"index"`
+	TokenId      int    `json:"token_id" gorm:"index"`
+	TokenName    string `json:"token_name" gorm:"default:''"`
+	ChannelId    int    `json:"channel_id" gorm:"index"`
+	Endpoint     string `json:"endpoint"`
+	InputFileId  string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId string `json:"output_file_id" gorm:"type:varchar(64)"`
+	Status       string `json:"status" gorm:"type:varchar(32)"` // status reported by upstream
+	Settled      bool   `json:"settled" gorm:"default:false;index"`
+	Quota        int64  `json:"quota" gorm:"bigint;default:0"`
+	PreConsumed  int64  `json:"pre_consumed" gorm:"bigint;default:0"` // the estimate consumed on creation
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	SettledTime  int64  `json:"settled_time" gorm:"bigint"`
+
+	// OrganizationId is the organization whose quota the batch draws, 0 means the quota of the user
+	OrganizationId int `json:"organization_id" gorm:"index;default:0"`
+}
+
+func GetUserBatches(userId int, startIdx int, num int) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("user_id = ?", userId).Order("id desc").Limit(num).Offset(startIdx).Find(&batches).Error
+	return batches, err
+}
+
+func GetUnsettledBatches() ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("settled = ?", false).Find(&batches).Error
+	return batches, err
+}
+
+func GetBatchByBatchId(batchId string, userId int) (*Batch, error) {
+	if batchId == "" {
+		return nil, errors.New("batch id 为空！")
+	}
+	batch := Batch{}
+	err := DB.First(&batch, "batch_id = ? and user_id = ?", batchId, userId).Error
+	return &batch, err
+}
+
+func (batch *Batch) Insert() error {
+	if batch.CreatedTime == 0 {
+		batch.CreatedTime = helper.GetTimestamp()
+	}
+	return DB.Create(batch).Error
+}
+
+func (batch *Batch) UpdateStatus(status string, outputFileId string) error {
+	batch.Status = status
+	if outputFileId != "" {
+		batch.OutputFileId = outputFileId
+	}
+	return DB.Model(batch).Select("status", "output_file_id").Updates(batch).Error
+}
+
+// Settle marks the batch as settled and charges the quota beyond the pre-consumed estimate in one transaction,
+// so that a batch is either settled and charged or neither. It returns false if the batch has been settled by others
+func (batch *Batch) Settle(quota int64) (bool, error) {
+	delta := quota - batch.PreConsumed
+	// the token may have been deleted, the user is charged anyway
+	token, tokenErr := GetTokenById(batch.TokenId)
+	settled := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Batch{}).Where("id = ? and settled = ?", batch.Id, false).Updates(map[string]any{
+			"settled":      true,
+			"quota":        quota,
+			"settled_time": helper.GetTimestamp(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		settled = true
+		if delta == 0 {
+			return nil
+		}
+		if tokenErr == nil && token.OrganizationId != 0 {
+			return consumeOrganizationQuota(tx, token.OrganizationId, token.UserId, delta)
+		}
+		err := tx.Model(&User{}).Where("id = ?", batch.UserId).Update("quota", gorm.Expr("quota - ?", delta)).Error
+		if err != nil || tokenErr != nil || token.UnlimitedQuota {
+			return err
+		}
+		return tx.Model(&Token{}).Where("id = ?", token.Id).Updates(map[string]any{
+			"remain_quota":  gorm.Expr("remain_quota - ?", delta),
+			"used_quota":    gorm.Expr("used_quota + ?", delta),
+			"accessed_time": helper.GetTimestamp(),
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if settled {
+		batch.Settled = true
+		batch.Quota = quota
+		if delta != 0 {
+			go recordBudgetConsumption(batch.UserId, batch.TokenId, delta)
+		}
+	}
+	return settled, nil
+}
@@ -0,0 +1,481 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/message"
+)
+
+const (
+	BudgetStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	BudgetStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	BudgetScopeUser  = "user"
+	BudgetScopeToken = "token"
+	BudgetScopeGroup = "group"
+)
+
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodWeekly  = "weekly"
+	BudgetPeriodMonthly = "monthly"
+)
+
+const (
+	BudgetNotifyByEmail         = "email"
+	BudgetNotifyByMessagePusher = "message_pusher"
+	BudgetNotifyByWebhook       = "webhook" // the budget.threshold event only
+)
+
+// Budget limits the spending of a user, a token or a group within a day, a week or a month.
+// The consumption is counted in the period starting at PeriodStart, and starts over once a new period begins
+type Budget struct {
+	Id              int    `json:"id"`
+	UserId          int    `json:"user_id" gorm:"index"` // the user the budget belongs to, 0 for the group budgets
+	Scope           string `json:"scope" gorm:"type:varchar(16);index:idx_budget_target"`
+	TargetId        int    `json:"target_id" gorm:"index:idx_budget_target"` // the id of the user or the token
+	Group           string `json:"group" gorm:"column:group_name;type:varchar(32);default:'';index"`
+	Period          string `json:"period" gorm:"type:varchar(16)"`
+	Quota           int64  `json:"quota" gorm:"bigint"`
+	HardCap         bool   `json:"hard_cap"`                                             // reject the requests once the budget is used up
+	AlertThresholds string `json:"alert_thresholds" gorm:"type:varchar(255);default:''"` // percentages like 50,80,100
+	NotifyBy        string `json:"notify_by" gorm:"type:varchar(32);default:'email'"`    // email, message_pusher or webhook
+	Managed         bool   `json:"managed"`                                              // set by the admins, the users cannot change it
+	Status          int    `json:"status" gorm:"default:1"`
+	PeriodStart     int64  `json:"period_start" gorm:"bigint;default:0"`
+	UsedQuota       int64  `json:"used_quota" gorm:"bigint;default:0"`
+	AlertedPercent  int    `json:"alerted_percent" gorm:"default:0"` // the highest threshold alerted in the period
+	CreatedTime     int64  `json:"created_time" gorm:"bigint"`
+}
+
+// budgetPeriodStart returns the start of the period containing now, the weeks start on monday
+func budgetPeriodStart(period string, now time.Time) time.Time {
+	year, month, day := now.Date()
+	switch period {
+	case BudgetPeriodWeekly:
+		return time.Date(year, month, day-(int(now.Weekday())+6)%7, 0, 0, 0, 0, now.Location())
+	case BudgetPeriodMonthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	}
+}
+
+func budgetPeriodEnd(period string, start time.Time) time.Time {
+	switch period {
+	case BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case BudgetPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func budgetPeriodName(period string) string {
+	switch period {
+	case BudgetPeriodWeekly:
+		return "本周"
+	case BudgetPeriodMonthly:
+		return "本月"
+	default:
+		return "今日"
+	}
+}
+
+func (budget *Budget) scopeName() string {
+	switch budget.Scope {
+	case BudgetScopeToken:
+		return fmt.Sprintf("令牌 #%d ", budget.TargetId)
+	case BudgetScopeGroup:
+		return fmt.Sprintf("分组 %s ", budget.Group)
+	default:
+		return "用户"
+	}
+}
+
+// alertThresholds returns the percentages to alert at in ascending order
+func (budget *Budget) alertThresholds() []int {
+	var thresholds []int
+	for _, value := range strings.Split(budget.AlertThresholds, ",") {
+		percent, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && percent > 0 {
+			thresholds = append(thresholds, percent)
+		}
+	}
+	sort.Ints(thresholds)
+	return thresholds
+}
+
+// currentUsedQuota returns the consumption of the current period, the counter of a past period does not count
+func (budget *Budget) currentUsedQuota(now time.Time) int64 {
+	if budget.PeriodStart != budgetPeriodStart(budget.Period, now).Unix() {
+		return 0
+	}
+	return budget.UsedQuota
+}
+
+func (budget *Budget) Validate() error {
+	switch budget.Scope {
+	case BudgetScopeUser, BudgetScopeToken:
+		if budget.TargetId == 0 {
+			return errors.New("预算对象不能为空")
+		}
+		budget.Group = ""
+	case BudgetScopeGroup:
+		if budget.Group == "" {
+			return errors.New("预算分组不能为空")
+		}
+		budget.TargetId = 0
+		budget.UserId = 0
+	default:
+		return errors.New("预算范围只能为 user、token 或 group")
+	}
+	if budget.Period != BudgetPeriodDaily && budget.Period != BudgetPeriodWeekly && budget.Period != BudgetPeriodMonthly {
+		return errors.New("预算周期只能为 daily、weekly 或 monthly")
+	}
+	if budget.Quota <= 0 {
+		return errors.New("预算额度必须大于 0")
+	}
+	thresholds := budget.alertThresholds()
+	values := make([]string, 0, len(thresholds))
+	for _, percent := range thresholds {
+		values = append(values, strconv.Itoa(percent))
+	}
+	budget.AlertThresholds = strings.Join(values, ",")
+	if !budget.HardCap && len(thresholds) == 0 {
+		return errors.New("请设置提醒阈值或开启硬性上限")
+	}
+	switch budget.NotifyBy {
+	case "":
+		budget.NotifyBy = BudgetNotifyByEmail
+	case BudgetNotifyByEmail, BudgetNotifyByMessagePusher, BudgetNotifyByWebhook:
+	default:
+		return errors.New("通知方式只能为 email、message_pusher 或 webhook")
+	}
+	if budget.Status != BudgetStatusDisabled {
+		budget.Status = BudgetStatusEnabled
+	}
+	return nil
+}
+
+// GetBudgets returns the budgets of the user, or all the budgets when the user id is 0
+func GetBudgets(userId int, startIdx int, num int) (budgets []*Budget, err error) {
+	tx := DB.Order("id desc")
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if err = tx.Limit(num).Offset(startIdx).Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, budget := range budgets {
+		budget.UsedQuota = budget.currentUsedQuota(now)
+	}
+	return budgets, nil
+}
+
+func GetBudgetById(id int) (*Budget, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	budget := Budget{Id: id}
+	err := DB.First(&budget, "id = ?", id).Error
+	return &budget, err
+}
+
+func (budget *Budget) Insert() error {
+	budget.CreatedTime = helper.GetTimestamp()
+	budget.PeriodStart = budgetPeriodStart(budget.Period, time.Now()).Unix()
+	budget.UsedQuota = 0
+	budget.AlertedPercent = 0
+	err := DB.Create(budget).Error
+	invalidateBudgetCache()
+	return err
+}
+
+// Update Make sure your budget's fields is completed, because this will update zero values.
+// The counter is kept, unless the period changes
+func (budget *Budget) Update() error {
+	fields := []string{"user_id", "scope", "target_id", "group_name", "period", "quota", "hard_cap", "alert_thresholds", "notify_by", "managed", "status"}
+	origin, err := GetBudgetById(budget.Id)
+	if err != nil {
+		return err
+	}
+	if origin.Period != budget.Period {
+		budget.PeriodStart = budgetPeriodStart(budget.Period, time.Now()).Unix()
+		budget.UsedQuota = 0
+		budget.AlertedPercent = 0
+		fields = append(fields, "period_start", "used_quota", "alerted_percent")
+	}
+	err = DB.Model(budget).Select(fields).Updates(budget).Error
+	invalidateBudgetCache()
+	return err
+}
+
+func DeleteBudgetById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Delete(&Budget{Id: id}).Error
+	invalidateBudgetCache()
+	return err
+}
+
+// enabledBudgets caches the enabled budgets, so that the requests are checked without querying the database.
+// The counters are reloaded every BudgetSyncFrequency seconds, the consumption on this node is counted in at once
+var enabledBudgets []*Budget
+var budgetCacheLock sync.RWMutex
+
+// pendingBudgetQuota buffers the consumption of the group budgets, which are shared by many users,
+// it is written by flushBudgetConsumption instead of updating the same row on every request
+var pendingBudgetQuota = make(map[int]int64)
+var pendingBudgetQuotaLock sync.Mutex
+
+func InitBudgetCache() {
+	var budgets []*Budget
+	if err := DB.Where("status = ?", BudgetStatusEnabled).Find(&budgets).Error; err != nil {
+		logger.SysError("failed to load budgets: " + err.Error())
+		return
+	}
+	if budgets == nil {
+		// an empty cache is still loaded
+		budgets = []*Budget{}
+	}
+	budgetCacheLock.Lock()
+	enabledBudgets = budgets
+	budgetCacheLock.Unlock()
+}
+
+// invalidateBudgetCache reloads the budgets after they are changed on this node
+func invalidateBudgetCache() {
+	budgetCacheLock.Lock()
+	enabledBudgets = nil
+	budgetCacheLock.Unlock()
+}
+
+// SyncBudgets writes the buffered consumption and reloads the budgets
+func SyncBudgets(frequency int) {
+	for {
+		time.Sleep(time.Duration(frequency) * time.Second)
+		flushBudgetConsumption()
+		InitBudgetCache()
+	}
+}
+
+func (budget *Budget) covers(userId int, tokenId int, group string) bool {
+	switch budget.Scope {
+	case BudgetScopeUser:
+		return budget.TargetId == userId
+	case BudgetScopeToken:
+		return budget.TargetId == tokenId
+	case BudgetScopeGroup:
+		return budget.Group == group
+	}
+	return false
+}
+
+// getMatchedBudgets returns copies of the enabled budgets covering the requests of the user with the token
+func getMatchedBudgets(userId int, tokenId int, group string, hardCapOnly bool) []Budget {
+	budgetCacheLock.RLock()
+	loaded := enabledBudgets != nil
+	budgetCacheLock.RUnlock()
+	if !loaded {
+		InitBudgetCache()
+	}
+	budgetCacheLock.RLock()
+	defer budgetCacheLock.RUnlock()
+	var budgets []Budget
+	for _, budget := range enabledBudgets {
+		if (!hardCapOnly || budget.HardCap) && budget.covers(userId, tokenId, group) {
+			budgets = append(budgets, *budget)
+		}
+	}
+	return budgets
+}
+
+// countCachedBudgetConsumption counts the consumption into the cached counter, until the next reload brings
+// the consumption of all the nodes
+func countCachedBudgetConsumption(id int, periodStart int64, quota int64) {
+	budgetCacheLock.Lock()
+	defer budgetCacheLock.Unlock()
+	for _, budget := range enabledBudgets {
+		if budget.Id != id {
+			continue
+		}
+		if budget.PeriodStart != periodStart {
+			budget.PeriodStart = periodStart
+			budget.UsedQuota = 0
+		}
+		budget.UsedQuota += quota
+	}
+}
+
+// CheckBudgets returns an error once any hard capped budget covering the request is used up in its period
+func CheckBudgets(userId int, tokenId int, group string) error {
+	now := time.Now()
+	for _, budget := range getMatchedBudgets(userId, tokenId, group, true) {
+		used := budget.currentUsedQuota(now)
+		if used < budget.Quota {
+			continue
+		}
+		resetAt := budgetPeriodEnd(budget.Period, budgetPeriodStart(budget.Period, now))
+		return fmt.Errorf("%s%s预算已用尽（已使用 %s，预算 %s），将于 %s 重置",
+			budget.scopeName(), budgetPeriodName(budget.Period), common.LogQuota(used), common.LogQuota(budget.Quota),
+			resetAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// recordBudgetConsumption counts the consumption into the budgets covering the request, a negative quota refunds.
+// The consumption of the group budgets is buffered and written by SyncBudgets
+func recordBudgetConsumption(userId int, tokenId int, quota int64) {
+	if quota == 0 {
+		return
+	}
+	group, err := CacheGetUserGroup(userId)
+	if err != nil {
+		logger.SysError("failed to get user group: " + err.Error())
+		return
+	}
+	now := time.Now()
+	for _, budget := range getMatchedBudgets(userId, tokenId, group, false) {
+		countCachedBudgetConsumption(budget.Id, budgetPeriodStart(budget.Period, now).Unix(), quota)
+		if budget.Scope == BudgetScopeGroup {
+			pendingBudgetQuotaLock.Lock()
+			pendingBudgetQuota[budget.Id] += quota
+			pendingBudgetQuotaLock.Unlock()
+			continue
+		}
+		updateBudgetUsedQuota(&budget, quota, now)
+	}
+}
+
+// flushBudgetConsumption writes the buffered consumption of the group budgets
+func flushBudgetConsumption() {
+	pendingBudgetQuotaLock.Lock()
+	pending := pendingBudgetQuota
+	pendingBudgetQuota = make(map[int]int64)
+	pendingBudgetQuotaLock.Unlock()
+	now := time.Now()
+	for id, quota := range pending {
+		if quota == 0 {
+			continue
+		}
+		budget, err := GetBudgetById(id)
+		if err != nil {
+			continue
+		}
+		updateBudgetUsedQuota(budget, quota, now)
+	}
+}
+
+// updateBudgetUsedQuota counts the consumption into the budget, the counter of a past period starts over with it
+func updateBudgetUsedQuota(budget *Budget, quota int64, now time.Time) {
+	periodStart := budgetPeriodStart(budget.Period, now).Unix()
+	// mysql assigns the columns from left to right, so period_start is updated last
+	err := DB.Exec("UPDATE budgets SET "+
+		"used_quota = CASE WHEN period_start = ? THEN used_quota + ? ELSE ? END, "+
+		"alerted_percent = CASE WHEN period_start = ? THEN alerted_percent ELSE 0 END, "+
+		"period_start = ? WHERE id = ?",
+		periodStart, quota, quota, periodStart, periodStart, budget.Id).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to record consumption of budget #%d: %s", budget.Id, err.Error()))
+		return
+	}
+	if quota > 0 && budget.AlertThresholds != "" {
+		checkBudgetAlert(budget.Id, periodStart)
+	}
+}
+
+// checkBudgetAlert notifies the highest threshold the consumption has crossed, each threshold is notified once a period
+func checkBudgetAlert(id int, periodStart int64) {
+	budget, err := GetBudgetById(id)
+	if err != nil || budget.PeriodStart != periodStart {
+		return
+	}
+	percent := 0
+	for _, threshold := range budget.alertThresholds() {
+		if budget.UsedQuota*100 >= budget.Quota*int64(threshold) {
+			percent = threshold
+		}
+	}
+	if percent <= budget.AlertedPercent {
+		return
+	}
+	// the conditional update makes sure only one node sends the alert
+	result := DB.Model(&Budget{}).Where("id = ? and period_start = ? and alerted_percent < ?", id, periodStart, percent).
+		Update("alerted_percent", percent)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	if err = sendBudgetAlert(budget, percent); err != nil {
+		logger.SysError(fmt.Sprintf("failed to send alert of budget #%d: %s", budget.Id, err.Error()))
+	}
+}
+
+type budgetAlert struct {
+	BudgetId  int    `json:"budget_id"`
+	UserId    int    `json:"user_id,omitempty"`
+	Scope     string `json:"scope"`
+	TargetId  int    `json:"target_id,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Period    string `json:"period"`
+	Percent   int    `json:"percent"`
+	UsedQuota int64  `json:"used_quota"`
+	Quota     int64  `json:"quota"`
+	HardCap   bool   `json:"hard_cap"`
+}
+
+// sendBudgetAlert triggers the budget.threshold event, and notifies by email or message pusher unless the
+// budget is notified by webhook only
+func sendBudgetAlert(budget *Budget, percent int) error {
+	EmitWebhookEvent(WebhookEventBudgetThreshold, budgetAlert{
+		BudgetId:  budget.Id,
+		UserId:    budget.UserId,
+		Scope:     budget.Scope,
+		TargetId:  budget.TargetId,
+		Group:     budget.Group,
+		Period:    budget.Period,
+		Percent:   percent,
+		UsedQuota: budget.UsedQuota,
+		Quota:     budget.Quota,
+		HardCap:   budget.HardCap,
+	})
+	subject := fmt.Sprintf("%s%s预算已使用 %d%%", budget.scopeName(), budgetPeriodName(budget.Period), percent)
+	content := fmt.Sprintf("%s%s预算已使用 %s，预算为 %s。", budget.scopeName(), budgetPeriodName(budget.Period),
+		common.LogQuota(budget.UsedQuota), common.LogQuota(budget.Quota))
+	if budget.HardCap {
+		content += "预算用尽后请求将被拒绝，直到下一周期开始。"
+	}
+	switch budget.NotifyBy {
+	case BudgetNotifyByWebhook:
+		return nil
+	case BudgetNotifyByMessagePusher:
+		return message.SendMessage(subject, content, content)
+	default:
+		email := config.RootUserEmail
+		if budget.UserId != 0 {
+			var err error
+			if email, err = GetUserEmail(budget.UserId); err != nil {
+				return err
+			}
+		}
+		if email == "" {
+			return errors.New("no email to send the alert to")
+		}
+		return message.SendEmail(subject, email, content)
+	}
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudgetPeriodStart(t *testing.T) {
+	monday := time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local)
+	// the weeks start on monday, sunday is the last day of the week
+	assert.Equal(t, monday, budgetPeriodStart(BudgetPeriodWeekly, monday))
+	assert.Equal(t, monday, budgetPeriodStart(BudgetPeriodWeekly, time.Date(2024, 1, 21, 23, 59, 59, 0, time.Local)))
+	assert.Equal(t, monday.AddDate(0, 0, 7), budgetPeriodStart(BudgetPeriodWeekly, time.Date(2024, 1, 22, 0, 0, 0, 0, time.Local)))
+	// a week across the end of a month and a year
+	assert.Equal(t, time.Date(2024, 12, 30, 0, 0, 0, 0, time.Local), budgetPeriodStart(BudgetPeriodWeekly, time.Date(2025, 1, 5, 12, 0, 0, 0, time.Local)))
+	assert.Equal(t, time.Date(2024, 12, 30, 0, 0, 0, 0, time.Local).AddDate(0, 0, 7), budgetPeriodEnd(BudgetPeriodWeekly, time.Date(2024, 12, 30, 0, 0, 0, 0, time.Local)))
+
+	now := time.Date(2024, 2, 29, 18, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local), budgetPeriodStart(BudgetPeriodDaily, now))
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), budgetPeriodStart(BudgetPeriodMonthly, now))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), budgetPeriodEnd(BudgetPeriodMonthly, budgetPeriodStart(BudgetPeriodMonthly, now)))
+}
+
+func TestBudgetValidate(t *testing.T) {
+	budget := &Budget{Scope: BudgetScopeGroup, Group: "default", TargetId: 3, UserId: 3, Period: BudgetPeriodWeekly, Quota: 1000, AlertThresholds: " 80, x,50,0"}
+	require.NoError(t, budget.Validate())
+	// the thresholds are sorted and the fields unrelated to the scope are cleared
+	assert.Equal(t, "50,80", budget.AlertThresholds)
+	assert.Zero(t, budget.TargetId)
+	assert.Zero(t, budget.UserId)
+	assert.Equal(t, BudgetNotifyByEmail, budget.NotifyBy)
+	assert.Equal(t, BudgetStatusEnabled, budget.Status)
+
+	budget = &Budget{Scope: BudgetScopeToken, TargetId: 1, Group: "default", Period: BudgetPeriodDaily, Quota: 1000, HardCap: true, NotifyBy: BudgetNotifyByWebhook}
+	require.NoError(t, budget.Validate())
+	assert.Empty(t, budget.Group)
+
+	for _, invalid := range []*Budget{
+		{Scope: "team", TargetId: 1, Period: BudgetPeriodDaily, Quota: 1000, HardCap: true},
+		{Scope: BudgetScopeUser, Period: BudgetPeriodDaily, Quota: 1000, HardCap: true},
+		{Scope: BudgetScopeGroup, Period: BudgetPeriodDaily, Quota: 1000, HardCap: true},
+		{Scope: BudgetScopeUser, TargetId: 1, Period: "yearly", Quota: 1000, HardCap: true},
+		{Scope: BudgetScopeUser, TargetId: 1, Period: BudgetPeriodDaily, HardCap: true},
+		{Scope: BudgetScopeUser, TargetId: 1, Period: BudgetPeriodDaily, Quota: 1000},
+		{Scope: BudgetScopeUser, TargetId: 1, Period: BudgetPeriodDaily, Quota: 1000, HardCap: true, NotifyBy: "sms"},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestGroupBudget(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "member", Quota: 1000, Group: "default", Status: UserStatusEnabled}).Error)
+	budget := &Budget{Scope: BudgetScopeGroup, Group: "default", Period: BudgetPeriodDaily, Quota: 100, HardCap: true}
+	require.NoError(t, budget.Validate())
+	require.NoError(t, budget.Insert())
+	t.Cleanup(func() {
+		pendingBudgetQuotaLock.Lock()
+		delete(pendingBudgetQuota, budget.Id)
+		pendingBudgetQuotaLock.Unlock()
+	})
+	require.NoError(t, CheckBudgets(1, 1, "default"))
+
+	// the consumption is checked at once, and written to the database by the next sync
+	recordBudgetConsumption(1, 1, 100)
+	assert.Error(t, CheckBudgets(1, 1, "default"))
+	assert.NoError(t, CheckBudgets(1, 1, "vip"))
+	budget, err := GetBudgetById(budget.Id)
+	require.NoError(t, err)
+	assert.Zero(t, budget.UsedQuota)
+
+	flushBudgetConsumption()
+	budget, err = GetBudgetById(budget.Id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), budget.UsedQuota)
+	InitBudgetCache()
+	assert.Error(t, CheckBudgets(1, 1, "default"))
+
+	// the budget changed is reloaded
+	budget.Quota = 200
+	require.NoError(t, budget.Update())
+	assert.NoError(t, CheckBudgets(1, 1, "default"))
+}
@@ -8,8 +8,6 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
-	"github.com/songquanpeng/one-api/common/random"
-	"math/rand"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,6 +21,7 @@ var (
 	UserId2QuotaCacheSeconds  = config.SyncFrequency
 	UserId2StatusCacheSeconds = config.SyncFrequency
 	GroupModelsCacheSeconds   = config.SyncFrequency
+	UserId2PlanCacheSeconds   = config.SyncFrequency
 )
 
 func CacheGetTokenByKey(key string) (*Token, error) {
@@ -73,6 +72,24 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
+func CacheGetUserPlanModels(id int) (models string, err error) {
+	if !common.RedisEnabled {
+		return GetUserPlanModels(id)
+	}
+	models, err = common.RedisGet(fmt.Sprintf("user_plan_models:%d", id))
+	if err != nil {
+		models, err = GetUserPlanModels(id)
+		if err != nil {
+			return "", err
+		}
+		err = common.RedisSet(fmt.Sprintf("user_plan_models:%d", id), models, time.Duration(UserId2PlanCacheSeconds)*time.Second)
+		if err != nil {
+			logger.SysError("Redis set user plan models error: " + err.Error())
+		}
+	}
+	return models, nil
+}
+
 func fetchAndUpdateUserQuota(ctx context.Context, id int) (quota int64, err error) {
 	quota, err = GetUserQuota(id)
 	if err != nil {
@@ -230,7 +247,8 @@ func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPrior
 	}
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
-	channels := group2model2channels[group][model]
+	model, channelIds := ResolveModelAlias(model)
+	channels := filterAvailableChannels(model, filterChannelsByIds(group2model2channels[group][model], channelIds))
 	if len(channels) == 0 {
 		return nil, errors.New("channel not found")
 	}
@@ -245,11 +263,58 @@ func CacheGetRandomSatisfiedChannel(group string, model string, ignoreFirstPrior
 			}
 		}
 	}
-	idx := rand.Intn(endIdx)
+	candidates := channels[:endIdx]
 	if ignoreFirstPriority {
 		if endIdx < len(channels) { // which means there are more than one priority
-			idx = random.RandRange(endIdx, len(channels))
+			candidates = channels[endIdx:]
+		}
+	}
+	return selectChannel(group, model, candidates), nil
+}
+
+// CacheGetSatisfiedChannelOfTypes picks a channel of the model among the given channel types, regardless of the priority,
+// it serves the retries of the apis which only some of the channel types implement
+func CacheGetSatisfiedChannelOfTypes(group string, model string, types []int) (*Channel, error) {
+	model, channelIds := ResolveModelAlias(model)
+	var channels []*Channel
+	if config.MemoryCacheEnabled {
+		channelSyncLock.RLock()
+		channels = filterChannelsByIds(group2model2channels[group][model], channelIds)
+		channelSyncLock.RUnlock()
+	} else {
+		groupCol := "`group`"
+		trueVal := "1"
+		if common.UsingPostgreSQL {
+			groupCol = `"group"`
+			trueVal = "true"
+		}
+		query := DB.Model(&Ability{}).Where(groupCol+" = ? and model = ? and enabled = "+trueVal, group, model)
+		if len(channelIds) > 0 {
+			query = query.Where("channel_id in ?", channelIds)
+		}
+		var abilityChannelIds []int
+		if err := query.Pluck("channel_id", &abilityChannelIds).Error; err != nil {
+			return nil, err
+		}
+		if len(abilityChannelIds) > 0 {
+			if err := DB.Where("id in ?", abilityChannelIds).Order("id").Find(&channels).Error; err != nil {
+				return nil, err
+			}
 		}
 	}
-	return channels[idx], nil
+	typeSet := make(map[int]bool, len(types))
+	for _, channelType := range types {
+		typeSet[channelType] = true
+	}
+	candidates := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if typeSet[channel.Type] {
+			candidates = append(candidates, channel)
+		}
+	}
+	candidates = filterAvailableChannels(model, candidates)
+	if len(candidates) == 0 {
+		return nil, errors.New("channel not found")
+	}
+	return selectChannel(group, model, candidates), nil
 }
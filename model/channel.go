@@ -7,6 +7,7 @@ import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/helper"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
 	"gorm.io/gorm"
 )
 
@@ -17,6 +18,12 @@ const (
 	ChannelStatusAutoDisabled     = 3
 )
 
+const (
+	ChannelBalanceStateNormal    = 0
+	ChannelBalanceStateLow       = 1 // the low balance is notified
+	ChannelBalanceStateExhausted = 2 // the channel is disabled as the balance is used up
+)
+
 type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" gorm:"default:0"`
@@ -37,6 +44,13 @@ type Channel struct {
 	ModelMapping       *string `json:"model_mapping" gorm:"type:varchar(1024);default:''"`
 	Priority           *int64  `json:"priority" gorm:"bigint;default:0"`
 	Config             string  `json:"config"`
+	MaxConcurrency     *int    `json:"max_concurrency" gorm:"default:0"` // 0 means unlimited
+	InFlight           int     `json:"in_flight" gorm:"-"`
+	Queued             int     `json:"queued" gorm:"-"`
+
+	// the balance is alerted once it falls below the threshold, 0 means no alert
+	BalanceThreshold *float64 `json:"balance_threshold" gorm:"default:0"`
+	BalanceState     int      `json:"balance_state" gorm:"default:0"`
 }
 
 type ChannelConfig struct {
@@ -103,6 +117,25 @@ func (channel *Channel) GetPriority() int64 {
 	return *channel.Priority
 }
 
+func (channel *Channel) GetWeight() uint {
+	if channel.Weight == nil {
+		return 0
+	}
+	return *channel.Weight
+}
+
+func (channel *Channel) GetMaxConcurrency() int {
+	if channel.MaxConcurrency == nil {
+		return 0
+	}
+	return *channel.MaxConcurrency
+}
+
+// LoadInFlight fills in the requests in flight and queued of the channel on this node
+func (channel *Channel) LoadInFlight() {
+	channel.InFlight, channel.Queued = concurrency.InFlight(channel.Id)
+}
+
 func (channel *Channel) GetBaseURL() string {
 	if channel.BaseURL == nil {
 		return ""
@@ -164,6 +197,20 @@ func (channel *Channel) UpdateBalance(balance float64) {
 	}
 }
 
+func (channel *Channel) GetBalanceThreshold() float64 {
+	if channel.BalanceThreshold == nil {
+		return 0
+	}
+	return *channel.BalanceThreshold
+}
+
+func UpdateChannelBalanceState(id int, state int) {
+	err := DB.Model(&Channel{}).Where("id = ?", id).Update("balance_state", state).Error
+	if err != nil {
+		logger.SysError("failed to update channel balance state: " + err.Error())
+	}
+}
+
 func (channel *Channel) Delete() error {
 	var err error
 	err = DB.Delete(channel).Error
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// File records a file uploaded through the files api,
+// so that later requests referencing it reach the same upstream channel
+type File struct {
+	Id          int    `json:"id"`
+	FileId      string `json:"file_id" gorm:"type:varchar(64);uniqueIndex"` // id returned by upstream
+	UserId      int    `json:"user_id" gorm:"index"`
+	TokenId     int    `json:"token_id" gorm:"index"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	Filename    string `json:"filename"`
+	Purpose     string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes       int64  `json:"bytes" gorm:"bigint"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func GetUserFiles(userId int, purpose string, startIdx int, num int) ([]*File, error) {
+	var files []*File
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	err := query.Order("id desc").Limit(num).Offset(startIdx).Find(&files).Error
+	return files, err
+}
+
+func GetFileByFileId(fileId string, userId int) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id 为空！")
+	}
+	file := File{}
+	err := DB.First(&file, "file_id = ? and user_id = ?", fileId, userId).Error
+	return &file, err
+}
+
+func (file *File) Insert() error {
+	if file.CreatedTime == 0 {
+		file.CreatedTime = helper.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+func (file *File) Delete() error {
+	return DB.Delete(file).Error
+}
+
+// GetRandomChannelOfTypes returns a random enabled channel of the group among the given types,
+// it serves the requests carrying no model, e.g. the uploads of the files api
+func GetRandomChannelOfTypes(group string, types []int) (*Channel, error) {
+	groupCol := "`group`"
+	trueVal := "1"
+	if common.UsingPostgreSQL {
+		groupCol = `"group"`
+		trueVal = "true"
+	}
+	var channelIds []int
+	err := DB.Model(&Ability{}).Distinct("channel_id").Where(groupCol+" = ? and enabled = "+trueVal, group).Pluck("channel_id", &channelIds).Error
+	if err != nil {
+		return nil, err
+	}
+	var channels []*Channel
+	if len(channelIds) > 0 {
+		err = DB.Omit("key").Where("id in ? and type in ? and status = ?", channelIds, types, ChannelStatusEnabled).Find(&channels).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("channel not found")
+	}
+	return channels[rand.Intn(len(channels))], nil
+}
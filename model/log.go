@@ -24,6 +24,14 @@ type Log struct {
 	PromptTokens     int    `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int    `json:"completion_tokens" gorm:"default:0"`
 	ChannelId        int    `json:"channel" gorm:"index"`
+	CacheHit         bool   `json:"cache_hit" gorm:"default:false"`
+	// the prompt tokens read from and written to the prompt cache, they are included in the prompt tokens
+	CachedTokens        int `json:"cached_tokens" gorm:"default:0"`
+	CacheCreationTokens int `json:"cache_creation_tokens" gorm:"default:0"`
+	// RequestModelName is the model requested by the client when it differs from the model name, e.g. a model alias
+	RequestModelName string `json:"request_model_name" gorm:"default:''"`
+	// OrganizationId is the organization whose quota the request drew, 0 means the quota of the user
+	OrganizationId int `json:"organization_id" gorm:"index;default:0"`
 }
 
 const (
@@ -68,12 +76,29 @@ func RecordTopupLog(userId int, content string, quota int) {
 
 func RecordConsumeLog(ctx context.Context, userId int, channelId int, promptTokens int, completionTokens int, modelName string, tokenName string, quota int64, content string) {
 	logger.Info(ctx, fmt.Sprintf("record consume log: userId=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content))
-	if !config.LogConsumeEnabled {
-		return
-	}
-	log := &Log{
+	recordConsumeLog(ctx, newConsumeLog(userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content))
+}
+
+// RecordConsumeLogWithCachedTokens records the consumption along with the prompt tokens read from and written to the prompt cache
+func RecordConsumeLogWithCachedTokens(ctx context.Context, userId int, channelId int, promptTokens int, completionTokens int, cachedTokens int, cacheCreationTokens int, modelName string, tokenName string, quota int64, content string) {
+	logger.Info(ctx, fmt.Sprintf("record consume log: userId=%d, channelId=%d, promptTokens=%d, completionTokens=%d, cachedTokens=%d, cacheCreationTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, channelId, promptTokens, completionTokens, cachedTokens, cacheCreationTokens, modelName, tokenName, quota, content))
+	log := newConsumeLog(userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content)
+	log.CachedTokens = cachedTokens
+	log.CacheCreationTokens = cacheCreationTokens
+	recordConsumeLog(ctx, log)
+}
+
+// RecordCacheHitLog records the consumption of a request served from the response cache
+func RecordCacheHitLog(ctx context.Context, userId int, promptTokens int, completionTokens int, modelName string, tokenName string, quota int64, content string) {
+	logger.Info(ctx, fmt.Sprintf("record cache hit log: userId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s", userId, promptTokens, completionTokens, modelName, tokenName, quota, content))
+	log := newConsumeLog(userId, 0, promptTokens, completionTokens, modelName, tokenName, quota, content)
+	log.CacheHit = true
+	recordConsumeLog(ctx, log)
+}
+
+func newConsumeLog(userId int, channelId int, promptTokens int, completionTokens int, modelName string, tokenName string, quota int64, content string) *Log {
+	return &Log{
 		UserId:           userId,
-		Username:         GetUsernameById(userId),
 		CreatedAt:        helper.GetTimestamp(),
 		Type:             LogTypeConsume,
 		Content:          content,
@@ -84,6 +109,19 @@ func RecordConsumeLog(ctx context.Context, userId int, channelId int, promptToke
 		Quota:            int(quota),
 		ChannelId:        channelId,
 	}
+}
+
+func recordConsumeLog(ctx context.Context, log *Log) {
+	if !config.LogConsumeEnabled {
+		return
+	}
+	log.Username = GetUsernameById(log.UserId)
+	if requestModel, ok := ctx.Value(helper.RequestModelKey).(string); ok && requestModel != log.ModelName {
+		log.RequestModelName = requestModel
+	}
+	if organizationId, ok := ctx.Value(helper.OrganizationIdKey).(int); ok {
+		log.OrganizationId = organizationId
+	}
 	err := LOG_DB.Create(log).Error
 	if err != nil {
 		logger.Error(ctx, "failed to record log: "+err.Error())
@@ -246,3 +284,28 @@ func SearchLogsByDayAndModel(userId, start, end int) (LogStatistics []*LogStatis
 
 	return LogStatistics, err
 }
+
+type OrganizationUsage struct {
+	UserId           int    `json:"user_id" gorm:"column:user_id"`
+	Username         string `json:"username" gorm:"column:username"`
+	ModelName        string `json:"model_name" gorm:"column:model_name"`
+	RequestCount     int    `json:"request_count" gorm:"column:request_count"`
+	Quota            int64  `json:"quota" gorm:"column:quota"`
+	PromptTokens     int    `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens" gorm:"column:completion_tokens"`
+}
+
+// GetOrganizationUsage sums the consumption of the organization by member and model
+func GetOrganizationUsage(organizationId int, startTimestamp int64, endTimestamp int64) (usages []*OrganizationUsage, err error) {
+	tx := LOG_DB.Table("logs").Select("user_id, username, model_name, count(1) as request_count, sum(quota) as quota, "+
+		"sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens").
+		Where("organization_id = ? and type = ?", organizationId, LogTypeConsume)
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	err = tx.Group("user_id, username, model_name").Order("quota desc").Scan(&usages).Error
+	return usages, err
+}
@@ -160,6 +160,42 @@ func migrateDB() error {
 	if err = DB.AutoMigrate(&Channel{}); err != nil {
 		return err
 	}
+	if err = DB.AutoMigrate(&File{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Batch{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&StoredResponse{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Plan{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Subscription{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&TopUpOrder{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Organization{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&OrganizationMember{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Budget{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&Webhook{}); err != nil {
+		return err
+	}
+	if err = DB.AutoMigrate(&WebhookDelivery{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -197,6 +233,9 @@ func migrateLOGDB() error {
 	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
 		return err
 	}
+	if err = LOG_DB.AutoMigrate(&AuditLog{}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -3,7 +3,10 @@ package model
 import (
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/audit"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/fallback"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
 	"strconv"
 	"strings"
 	"time"
@@ -69,11 +72,34 @@ func InitOptionMap() {
 	config.OptionMap["PreConsumedQuota"] = strconv.FormatInt(config.PreConsumedQuota, 10)
 	config.OptionMap["ModelRatio"] = billingratio.ModelRatio2JSONString()
 	config.OptionMap["GroupRatio"] = billingratio.GroupRatio2JSONString()
+	config.OptionMap["ChannelSelection"] = ChannelSelection2JSONString()
+	config.OptionMap["GroupRateLimit"] = ratelimit.GroupRateLimit2JSONString()
+	config.OptionMap["ModelFallback"] = fallback.GroupModelFallback2JSONString()
+	config.OptionMap["ModelAlias"] = ModelAliases2JSONString()
 	config.OptionMap["CompletionRatio"] = billingratio.CompletionRatio2JSONString()
+	config.OptionMap["CacheReadRatio"] = billingratio.CacheReadRatio2JSONString()
+	config.OptionMap["CacheWriteRatio"] = billingratio.CacheWriteRatio2JSONString()
 	config.OptionMap["TopUpLink"] = config.TopUpLink
 	config.OptionMap["ChatLink"] = config.ChatLink
 	config.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(config.QuotaPerUnit, 'f', -1, 64)
 	config.OptionMap["RetryTimes"] = strconv.Itoa(config.RetryTimes)
+	config.OptionMap["BatchDiscountRatio"] = strconv.FormatFloat(config.BatchDiscountRatio, 'f', -1, 64)
+	config.OptionMap["ResponseCacheEnabled"] = strconv.FormatBool(config.ResponseCacheEnabled)
+	config.OptionMap["ResponseCacheModels"] = config.ResponseCacheModels
+	config.OptionMap["ResponseCacheTTL"] = strconv.Itoa(config.ResponseCacheTTL)
+	config.OptionMap["ResponseCacheBillingRatio"] = strconv.FormatFloat(config.ResponseCacheBillingRatio, 'f', -1, 64)
+	config.OptionMap["AuditLogEnabled"] = strconv.FormatBool(config.AuditLogEnabled)
+	config.OptionMap["AuditLogGroups"] = config.AuditLogGroups
+	config.OptionMap["AuditLogMaxBodySize"] = strconv.Itoa(config.AuditLogMaxBodySize)
+	config.OptionMap["AuditLogRetentionDays"] = strconv.Itoa(config.AuditLogRetentionDays)
+	config.OptionMap["AuditLogRedactionRules"] = audit.RedactionRules2JSONString()
+	config.OptionMap["TopUpPrice"] = strconv.FormatFloat(config.TopUpPrice, 'f', -1, 64)
+	config.OptionMap["TopUpMinAmount"] = strconv.Itoa(config.TopUpMinAmount)
+	config.OptionMap["PaymentCurrency"] = config.PaymentCurrency
+	config.OptionMap["StripeApiSecret"] = ""
+	config.OptionMap["StripeWebhookSecret"] = ""
+	config.OptionMap["PaymentGatewayAddress"] = config.PaymentGatewayAddress
+	config.OptionMap["PaymentWebhookSecret"] = ""
 	config.OptionMap["Theme"] = config.Theme
 	config.OptionMapRWMutex.Unlock()
 	loadOptionsFromDatabase()
@@ -153,6 +179,10 @@ func updateOptionMap(key string, value string) (err error) {
 			config.DisplayInCurrencyEnabled = boolValue
 		case "DisplayTokenStatEnabled":
 			config.DisplayTokenStatEnabled = boolValue
+		case "ResponseCacheEnabled":
+			config.ResponseCacheEnabled = boolValue
+		case "AuditLogEnabled":
+			config.AuditLogEnabled = boolValue
 		}
 	}
 	switch key {
@@ -227,8 +257,20 @@ func updateOptionMap(key string, value string) (err error) {
 		err = billingratio.UpdateModelRatioByJSONString(value)
 	case "GroupRatio":
 		err = billingratio.UpdateGroupRatioByJSONString(value)
+	case "ChannelSelection":
+		err = UpdateChannelSelectionByJSONString(value)
+	case "GroupRateLimit":
+		err = ratelimit.UpdateGroupRateLimitByJSONString(value)
+	case "ModelFallback":
+		err = fallback.UpdateGroupModelFallbackByJSONString(value)
+	case "ModelAlias":
+		err = UpdateModelAliasesByJSONString(value)
 	case "CompletionRatio":
 		err = billingratio.UpdateCompletionRatioByJSONString(value)
+	case "CacheReadRatio":
+		err = billingratio.UpdateCacheReadRatioByJSONString(value)
+	case "CacheWriteRatio":
+		err = billingratio.UpdateCacheWriteRatioByJSONString(value)
 	case "TopUpLink":
 		config.TopUpLink = value
 	case "ChatLink":
@@ -237,6 +279,36 @@ func updateOptionMap(key string, value string) (err error) {
 		config.ChannelDisableThreshold, _ = strconv.ParseFloat(value, 64)
 	case "QuotaPerUnit":
 		config.QuotaPerUnit, _ = strconv.ParseFloat(value, 64)
+	case "BatchDiscountRatio":
+		config.BatchDiscountRatio, _ = strconv.ParseFloat(value, 64)
+	case "ResponseCacheModels":
+		config.ResponseCacheModels = value
+	case "ResponseCacheTTL":
+		config.ResponseCacheTTL, _ = strconv.Atoi(value)
+	case "ResponseCacheBillingRatio":
+		config.ResponseCacheBillingRatio, _ = strconv.ParseFloat(value, 64)
+	case "AuditLogGroups":
+		config.AuditLogGroups = value
+	case "AuditLogMaxBodySize":
+		config.AuditLogMaxBodySize, _ = strconv.Atoi(value)
+	case "AuditLogRetentionDays":
+		config.AuditLogRetentionDays, _ = strconv.Atoi(value)
+	case "AuditLogRedactionRules":
+		err = audit.UpdateRedactionRulesByJSONString(value)
+	case "TopUpPrice":
+		config.TopUpPrice, _ = strconv.ParseFloat(value, 64)
+	case "TopUpMinAmount":
+		config.TopUpMinAmount, _ = strconv.Atoi(value)
+	case "PaymentCurrency":
+		config.PaymentCurrency = value
+	case "StripeApiSecret":
+		config.StripeApiSecret = value
+	case "StripeWebhookSecret":
+		config.StripeWebhookSecret = value
+	case "PaymentGatewayAddress":
+		config.PaymentGatewayAddress = value
+	case "PaymentWebhookSecret":
+		config.PaymentWebhookSecret = value
 	case "Theme":
 		config.Theme = value
 	}
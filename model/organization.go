@@ -0,0 +1,238 @@
+package model
+
+import (
+	"context"
+	"errors"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"gorm.io/gorm"
+)
+
+const (
+	OrganizationStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	OrganizationStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	OrganizationRoleMember = 1
+	OrganizationRoleAdmin  = 10
+	OrganizationRoleOwner  = 100
+)
+
+// Organization owns a quota pool shared by its members, the tokens of the organization draw from it
+// instead of the quota of the members who created them
+type Organization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"uniqueIndex;type:varchar(64)"`
+	Status      int    `json:"status" gorm:"default:1"`
+	Quota       int64  `json:"quota" gorm:"bigint;default:0"`
+	UsedQuota   int64  `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// OrganizationMember is the membership of a user, the member may spend the quota of the organization up to the limit
+type OrganizationMember struct {
+	Id             int    `json:"id"`
+	OrganizationId int    `json:"organization_id" gorm:"uniqueIndex:idx_organization_user"`
+	UserId         int    `json:"user_id" gorm:"uniqueIndex:idx_organization_user;index"`
+	Username       string `json:"username" gorm:"-:all"` // only for api response
+	Role           int    `json:"role" gorm:"default:1"`
+	QuotaLimit     int64  `json:"quota_limit" gorm:"bigint;default:0"` // 0 means the member may spend the whole quota
+	UsedQuota      int64  `json:"used_quota" gorm:"bigint;default:0"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+}
+
+func GetAllOrganizations(startIdx int, num int) (organizations []*Organization, err error) {
+	err = DB.Order("id desc").Limit(num).Offset(startIdx).Find(&organizations).Error
+	return organizations, err
+}
+
+func GetOrganizationById(id int) (*Organization, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	organization := Organization{Id: id}
+	err := DB.First(&organization, "id = ?", id).Error
+	return &organization, err
+}
+
+// CreateOrganization creates the organization along with its owner
+func CreateOrganization(organization *Organization, ownerId int) error {
+	organization.CreatedTime = helper.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationId: organization.Id,
+			UserId:         ownerId,
+			Role:           OrganizationRoleOwner,
+			CreatedTime:    organization.CreatedTime,
+		}).Error
+	})
+}
+
+// Update Make sure your organization's fields is completed, because this will update zero values
+func (organization *Organization) Update() error {
+	return DB.Model(organization).Select("name", "status", "quota").Updates(organization).Error
+}
+
+// DeleteOrganizationById deletes the organization along with its members, its tokens are disabled
+func DeleteOrganizationById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).Where("organization_id = ?", id).Update("status", TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Organization{Id: id}).Error
+	})
+}
+
+// GetUserOrganizations returns the organizations the user belongs to along with the memberships
+func GetUserOrganizations(userId int) (organizations []*Organization, members []*OrganizationMember, err error) {
+	if err = DB.Where("user_id = ?", userId).Order("organization_id").Find(&members).Error; err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.OrganizationId)
+	}
+	err = DB.Where("id in ?", ids).Order("id").Find(&organizations).Error
+	return organizations, members, err
+}
+
+func GetOrganizationMember(organizationId int, userId int) (*OrganizationMember, error) {
+	member := &OrganizationMember{}
+	err := DB.First(member, "organization_id = ? and user_id = ?", organizationId, userId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("用户不是该组织的成员")
+	}
+	return member, err
+}
+
+func GetOrganizationMembers(organizationId int) (members []*OrganizationMember, err error) {
+	if err = DB.Where("organization_id = ?", organizationId).Order("role desc, id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		member.Username = GetUsernameById(member.UserId)
+	}
+	return members, nil
+}
+
+func (member *OrganizationMember) Insert() error {
+	member.CreatedTime = helper.GetTimestamp()
+	return DB.Create(member).Error
+}
+
+// Update Make sure your member's fields is completed, because this will update zero values
+func (member *OrganizationMember) Update() error {
+	return DB.Model(member).Select("role", "quota_limit", "used_quota").Updates(member).Error
+}
+
+// Delete removes the member, the tokens the member created for the organization are disabled
+func (member *OrganizationMember) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Token{}).Where("organization_id = ? and user_id = ?", member.OrganizationId, member.UserId).Update("status", TokenStatusDisabled).Error; err != nil {
+			return err
+		}
+		return tx.Delete(member).Error
+	})
+}
+
+// GetOrganizationTokens returns the tokens of the organization, the keys are left out
+func GetOrganizationTokens(organizationId int) (tokens []*Token, err error) {
+	err = DB.Omit("key").Where("organization_id = ?", organizationId).Order("id desc").Find(&tokens).Error
+	return tokens, err
+}
+
+// GetOrganizationMemberQuota returns the quota the member may still spend,
+// which is the quota of the organization bounded by the limit of the member
+func GetOrganizationMemberQuota(organizationId int, userId int) (int64, error) {
+	organization, err := GetOrganizationById(organizationId)
+	if err != nil {
+		return 0, err
+	}
+	if organization.Status != OrganizationStatusEnabled {
+		return 0, errors.New("该组织已被禁用")
+	}
+	member, err := GetOrganizationMember(organizationId, userId)
+	if err != nil {
+		return 0, err
+	}
+	quota := organization.Quota
+	if member.QuotaLimit > 0 && member.QuotaLimit-member.UsedQuota < quota {
+		quota = member.QuotaLimit - member.UsedQuota
+	}
+	return quota, nil
+}
+
+// CacheGetQuotaOf returns the quota a request may draw, from the organization for the organization tokens,
+// and from the user otherwise
+func CacheGetQuotaOf(ctx context.Context, userId int, organizationId int) (int64, error) {
+	if organizationId == 0 {
+		return CacheGetUserQuota(ctx, userId)
+	}
+	return GetOrganizationMemberQuota(organizationId, userId)
+}
+
+// CacheDecreaseQuotaOf decreases the cached quota a request draws, the quota of the organizations is not cached
+func CacheDecreaseQuotaOf(userId int, organizationId int, quota int64) error {
+	if organizationId != 0 {
+		return nil
+	}
+	return CacheDecreaseUserQuota(userId, quota)
+}
+
+// UpdateUsedQuotaAndRequestCountOf counts the quota a request consumed to the user, the requests drawing the quota
+// of an organization are not counted, their used quota is counted to the organization and the member as it is charged
+func UpdateUsedQuotaAndRequestCountOf(userId int, organizationId int, quota int64) {
+	if organizationId != 0 {
+		return
+	}
+	UpdateUserUsedQuotaAndRequestCount(userId, quota)
+}
+
+// reserveOrganizationQuota charges the quota to the organization and the member only if both of them can afford it,
+// the conditions are checked by the updates themselves, so that concurrent requests cannot overdraw the limits
+func reserveOrganizationQuota(organizationId int, userId int, quota int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Organization{}).Where("id = ? and status = ? and quota >= ?", organizationId, OrganizationStatusEnabled, quota).Updates(map[string]interface{}{
+			"quota":      gorm.Expr("quota - ?", quota),
+			"used_quota": gorm.Expr("used_quota + ?", quota),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("组织额度不足或组织已被禁用")
+		}
+		result = tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ? and (quota_limit = 0 or quota_limit - used_quota >= ?)", organizationId, userId, quota).
+			Update("used_quota", gorm.Expr("used_quota + ?", quota))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("成员额度不足")
+		}
+		return nil
+	})
+}
+
+// consumeOrganizationQuota charges the quota to the organization and the member, a negative quota refunds
+func consumeOrganizationQuota(tx *gorm.DB, organizationId int, userId int, quota int64) error {
+	err := tx.Model(&Organization{}).Where("id = ?", organizationId).Updates(map[string]interface{}{
+		"quota":      gorm.Expr("quota - ?", quota),
+		"used_quota": gorm.Expr("used_quota + ?", quota),
+	}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organizationId, userId).
+		Update("used_quota", gorm.Expr("used_quota + ?", quota)).Error
+}
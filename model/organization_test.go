@@ -0,0 +1,101 @@
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOrganization(t *testing.T, quota int64, quotaLimit int64) *Organization {
+	setupTestDB(t)
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "owner", Quota: 100, Status: UserStatusEnabled}).Error)
+	organization := &Organization{Name: "acme", Status: OrganizationStatusEnabled, Quota: quota}
+	require.NoError(t, CreateOrganization(organization, 1))
+	require.NoError(t, DB.Model(&OrganizationMember{}).Where("organization_id = ? and user_id = ?", organization.Id, 1).Update("quota_limit", quotaLimit).Error)
+	require.NoError(t, DB.Create(&Token{Id: 1, UserId: 1, Key: "organization-token", Name: "t", Status: TokenStatusEnabled, UnlimitedQuota: true, OrganizationId: organization.Id}).Error)
+	return organization
+}
+
+func getOrganizationUsage(t *testing.T, organizationId int) (int64, int64, int64) {
+	organization, err := GetOrganizationById(organizationId)
+	require.NoError(t, err)
+	member, err := GetOrganizationMember(organizationId, 1)
+	require.NoError(t, err)
+	return organization.Quota, organization.UsedQuota, member.UsedQuota
+}
+
+func TestGetOrganizationMemberQuota(t *testing.T) {
+	organization := setupOrganization(t, 1000, 300)
+	quota, err := GetOrganizationMemberQuota(organization.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), quota)
+
+	require.NoError(t, DB.Model(&OrganizationMember{}).Where("user_id = ?", 1).Update("quota_limit", 0).Error)
+	quota, err = GetOrganizationMemberQuota(organization.Id, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), quota)
+
+	_, err = GetOrganizationMemberQuota(organization.Id, 2)
+	assert.Error(t, err)
+	organization.Status = OrganizationStatusDisabled
+	require.NoError(t, organization.Update())
+	_, err = GetOrganizationMemberQuota(organization.Id, 1)
+	assert.Error(t, err)
+}
+
+func TestPreConsumeOrganizationTokenQuota(t *testing.T) {
+	organization := setupOrganization(t, 1000, 300)
+	require.NoError(t, PreConsumeTokenQuota(1, 200))
+	// the limit of the member is checked along with the charge
+	assert.Error(t, PreConsumeTokenQuota(1, 200))
+	quota, used, memberUsed := getOrganizationUsage(t, organization.Id)
+	assert.Equal(t, int64(800), quota)
+	assert.Equal(t, int64(200), used)
+	assert.Equal(t, int64(200), memberUsed)
+
+	// the refund of the post consumption gives the quota back to both
+	require.NoError(t, PostConsumeTokenQuota(1, -50))
+	quota, used, memberUsed = getOrganizationUsage(t, organization.Id)
+	assert.Equal(t, int64(850), quota)
+	assert.Equal(t, int64(150), used)
+	assert.Equal(t, int64(150), memberUsed)
+
+	// the quota of the user is left untouched
+	user, err := GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), user.Quota)
+	assert.Zero(t, user.UsedQuota)
+}
+
+func TestPreConsumeOrganizationTokenQuotaConcurrently(t *testing.T) {
+	organization := setupOrganization(t, 10000, 500)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = PreConsumeTokenQuota(1, 100)
+		}()
+	}
+	wg.Wait()
+	// the member never spends beyond the limit
+	_, _, memberUsed := getOrganizationUsage(t, organization.Id)
+	assert.Equal(t, int64(500), memberUsed)
+}
+
+func TestUpdateUsedQuotaAndRequestCountOf(t *testing.T) {
+	organization := setupOrganization(t, 1000, 0)
+	UpdateUsedQuotaAndRequestCountOf(1, organization.Id, 100)
+	user, err := GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Zero(t, user.UsedQuota)
+	assert.Zero(t, user.RequestCount)
+
+	UpdateUsedQuotaAndRequestCountOf(1, 0, 100)
+	user, err = GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), user.UsedQuota)
+	assert.Equal(t, 1, user.RequestCount)
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	PlanStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	PlanStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	PlanPeriodDaily   = "daily"
+	PlanPeriodMonthly = "monthly"
+)
+
+// Plan is a subscription plan, the subscribers are granted the quota of the plan every period,
+// and the quota left from the previous period is taken back when the period resets
+type Plan struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"uniqueIndex;type:varchar(64)"`
+	Description string `json:"description" gorm:"type:text"`
+	Status      int    `json:"status" gorm:"default:1"`
+	Quota       int64  `json:"quota" gorm:"bigint;default:0"`                    // the allowance of a period
+	Period      string `json:"period" gorm:"type:varchar(16);default:'monthly'"` // daily, monthly
+	Duration    int    `json:"duration" gorm:"default:0"`                        // days the subscription lasts, 0 means never expires
+	Models      string `json:"models" gorm:"type:text"`                          // the models the subscribers may use, empty means no restriction
+	Group       string `json:"group" gorm:"type:varchar(32);default:''"`         // the group the subscribers are moved to, empty means unchanged
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func GetAllPlans(startIdx int, num int) ([]*Plan, error) {
+	var plans []*Plan
+	err := DB.Order("id desc").Limit(num).Offset(startIdx).Find(&plans).Error
+	return plans, err
+}
+
+func GetPlanById(id int) (*Plan, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	plan := Plan{Id: id}
+	err := DB.First(&plan, "id = ?", id).Error
+	return &plan, err
+}
+
+// Validate checks the plan and normalizes its model list
+func (plan *Plan) Validate() error {
+	if plan.Name == "" || len(plan.Name) > 64 {
+		return errors.New("套餐名称长度必须在1-64之间")
+	}
+	if plan.Quota < 0 {
+		return errors.New("套餐额度不能为负数")
+	}
+	if plan.Period != PlanPeriodDaily && plan.Period != PlanPeriodMonthly {
+		return errors.New("套餐周期只能为 daily 或 monthly")
+	}
+	if plan.Duration < 0 {
+		return errors.New("套餐时长不能为负数")
+	}
+	var models []string
+	for _, m := range strings.Split(plan.Models, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	plan.Models = strings.Join(models, ",")
+	return nil
+}
+
+func (plan *Plan) Insert() error {
+	return DB.Create(plan).Error
+}
+
+// Update Make sure your plan's fields is completed, because this will update zero values
+func (plan *Plan) Update() error {
+	return DB.Model(plan).Select("name", "description", "status", "quota", "period", "duration", "models", "group").Updates(plan).Error
+}
+
+func DeletePlanById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	var count int64
+	if err := DB.Model(&Subscription{}).Where("plan_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该套餐仍有订阅用户，无法删除")
+	}
+	return DB.Delete(&Plan{Id: id}).Error
+}
+
+// nextResetTime returns the end of the period starting at the given time
+func (plan *Plan) nextResetTime(from int64) int64 {
+	t := time.Unix(from, 0)
+	if plan.Period == PlanPeriodDaily {
+		return t.AddDate(0, 0, 1).Unix()
+	}
+	return t.AddDate(0, 1, 0).Unix()
+}
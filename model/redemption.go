@@ -83,6 +83,12 @@ func Redeem(key string, userId int) (quota int64, err error) {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
 	RecordLog(userId, LogTypeTopup, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
+	go EmitWebhookEvent(WebhookEventRedemptionUsed, map[string]interface{}{
+		"user_id":       userId,
+		"redemption_id": redemption.Id,
+		"name":          redemption.Name,
+		"quota":         redemption.Quota,
+	})
 	return redemption.Quota, nil
 }
 
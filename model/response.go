@@ -0,0 +1,39 @@
+package model
+
+import (
+	"errors"
+	"github.com/songquanpeng/one-api/common/helper"
+)
+
+// StoredResponse records a response created through the responses api, so that it can be referenced by previous_response_id.
+// Responses passed through to the upstream are kept there, only the channel is recorded;
+// for converted responses the whole conversation is stored in chat completions format.
+type StoredResponse struct {
+	Id          int    `json:"id"`
+	ResponseId  string `json:"response_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	ChannelId   int    `json:"channel_id"`
+	Messages    string `json:"messages"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func GetStoredResponse(responseId string, userId int) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id 为空！")
+	}
+	response := StoredResponse{}
+	err := DB.First(&response, "response_id = ? and user_id = ?", responseId, userId).Error
+	return &response, err
+}
+
+// IsConverted reports whether the conversation is stored locally instead of by the upstream
+func (response *StoredResponse) IsConverted() bool {
+	return response.Messages != ""
+}
+
+func (response *StoredResponse) Insert() error {
+	if response.CreatedTime == 0 {
+		response.CreatedTime = helper.GetTimestamp()
+	}
+	return DB.Create(response).Error
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/monitor/breaker"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
+)
+
+const (
+	// ChannelSelectionRandom picks a channel uniformly at random, it is the default strategy
+	ChannelSelectionRandom = "random"
+	// ChannelSelectionWeighted picks a channel at random in proportion to its weight
+	ChannelSelectionWeighted = "weighted"
+	// ChannelSelectionLatency picks the channel with the lowest recent latency
+	ChannelSelectionLatency = "latency"
+	// ChannelSelectionRoundRobin picks the channels in turn
+	ChannelSelectionRoundRobin = "round_robin"
+)
+
+// latencySmoothingFactor is the weight of the newest timing in the moving average of the latency
+const latencySmoothingFactor = 0.3
+
+// latencyExplorationRate is the chance that the latency strategy picks a channel at random instead of the fastest one,
+// so that the latency of the slower channels keeps being measured and a recovered channel gets picked again
+var latencyExplorationRate = 0.1
+
+var (
+	// group2ChannelSelection maps the groups to their channel selection strategies
+	group2ChannelSelection     = make(map[string]string)
+	group2ChannelSelectionLock sync.RWMutex
+
+	channelId2latency     = make(map[int]float64) // in milliseconds
+	channelId2latencyLock sync.RWMutex
+
+	roundRobinCounters sync.Map // group:model -> *uint64
+)
+
+func ChannelSelection2JSONString() string {
+	group2ChannelSelectionLock.RLock()
+	defer group2ChannelSelectionLock.RUnlock()
+	jsonBytes, err := json.Marshal(group2ChannelSelection)
+	if err != nil {
+		logger.SysError("error marshalling channel selection: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateChannelSelectionByJSONString(jsonStr string) error {
+	newGroup2ChannelSelection := make(map[string]string)
+	err := json.Unmarshal([]byte(jsonStr), &newGroup2ChannelSelection)
+	if err != nil {
+		return err
+	}
+	for group, strategy := range newGroup2ChannelSelection {
+		switch strategy {
+		case ChannelSelectionRandom, ChannelSelectionWeighted, ChannelSelectionLatency, ChannelSelectionRoundRobin:
+		default:
+			return fmt.Errorf("invalid channel selection strategy of group %s: %s", group, strategy)
+		}
+	}
+	group2ChannelSelectionLock.Lock()
+	group2ChannelSelection = newGroup2ChannelSelection
+	group2ChannelSelectionLock.Unlock()
+	return nil
+}
+
+func GetChannelSelection(group string) string {
+	group2ChannelSelectionLock.RLock()
+	defer group2ChannelSelectionLock.RUnlock()
+	if strategy, ok := group2ChannelSelection[group]; ok {
+		return strategy
+	}
+	return ChannelSelectionRandom
+}
+
+// RecordChannelLatency feeds the timing of a successful relay to the moving average of the channel latency,
+// the time to the first byte is recorded for streams, so that long outputs do not make a channel look slow
+func RecordChannelLatency(channelId int, latency time.Duration) {
+	milliseconds := float64(latency.Milliseconds())
+	channelId2latencyLock.Lock()
+	defer channelId2latencyLock.Unlock()
+	if average, ok := channelId2latency[channelId]; ok {
+		milliseconds = average + latencySmoothingFactor*(milliseconds-average)
+	}
+	channelId2latency[channelId] = milliseconds
+}
+
+// getChannelLatency returns the recent latency of the channel, the response time of the last test is used
+// until the channel has relayed any request
+func getChannelLatency(channel *Channel) float64 {
+	channelId2latencyLock.RLock()
+	defer channelId2latencyLock.RUnlock()
+	if latency, ok := channelId2latency[channel.Id]; ok {
+		return latency
+	}
+	return float64(channel.ResponseTime)
+}
+
+// filterAvailableChannels leaves out the channels whose circuit breakers are open,
+// all of them are kept if none is available, since trying a broken channel is better than failing at once
+func filterAvailableChannels(model string, channels []*Channel) []*Channel {
+	if !config.CircuitBreakerEnabled {
+		return channels
+	}
+	available := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if breaker.Allow(channel.Id, model) {
+			available = append(available, channel)
+		}
+	}
+	if len(available) == 0 {
+		return channels
+	}
+	return available
+}
+
+// filterIdleChannels drops the channels which reach their max concurrency,
+// all the channels are kept when every one of them is saturated, the request waits in the queue then
+func filterIdleChannels(channels []*Channel) []*Channel {
+	idle := make([]*Channel, 0, len(channels))
+	for _, channel := range channels {
+		if !concurrency.IsSaturated(channel.Id, channel.GetMaxConcurrency()) {
+			idle = append(idle, channel)
+		}
+	}
+	if len(idle) == 0 {
+		return channels
+	}
+	return idle
+}
+
+// selectChannel picks one of the candidate channels with the strategy of the group
+func selectChannel(group string, model string, channels []*Channel) *Channel {
+	channels = filterIdleChannels(channels)
+	if len(channels) == 1 {
+		return channels[0]
+	}
+	switch GetChannelSelection(group) {
+	case ChannelSelectionWeighted:
+		return selectWeightedChannel(channels)
+	case ChannelSelectionLatency:
+		return selectLowestLatencyChannel(channels)
+	case ChannelSelectionRoundRobin:
+		counter, _ := roundRobinCounters.LoadOrStore(group+":"+model, new(uint64))
+		idx := atomic.AddUint64(counter.(*uint64), 1) - 1
+		return channels[idx%uint64(len(channels))]
+	default:
+		return channels[rand.Intn(len(channels))]
+	}
+}
+
+// selectWeightedChannel picks a channel in proportion to its weight,
+// channels without weight are only picked when none of the candidates has weight
+func selectWeightedChannel(channels []*Channel) *Channel {
+	var totalWeight uint
+	for _, channel := range channels {
+		totalWeight += channel.GetWeight()
+	}
+	if totalWeight == 0 {
+		return channels[rand.Intn(len(channels))]
+	}
+	target := uint(rand.Int63n(int64(totalWeight)))
+	for _, channel := range channels {
+		weight := channel.GetWeight()
+		if target < weight {
+			return channel
+		}
+		target -= weight
+	}
+	return channels[len(channels)-1]
+}
+
+// selectLowestLatencyChannel picks the channel with the lowest latency, ties are broken at random,
+// channels which have never been measured are tried first so that their latency gets known,
+// and any channel is explored now and then, see latencyExplorationRate
+func selectLowestLatencyChannel(channels []*Channel) *Channel {
+	if rand.Float64() < latencyExplorationRate {
+		return channels[rand.Intn(len(channels))]
+	}
+	var candidates []*Channel
+	lowestLatency := 0.0
+	for _, channel := range channels {
+		latency := getChannelLatency(channel)
+		if len(candidates) == 0 || latency < lowestLatency {
+			candidates = []*Channel{channel}
+			lowestLatency = latency
+		} else if latency == lowestLatency {
+			candidates = append(candidates, channel)
+		}
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/monitor/breaker"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setChannelSelection(t *testing.T, jsonStr string) {
+	old := ChannelSelection2JSONString()
+	require.NoError(t, UpdateChannelSelectionByJSONString(jsonStr))
+	t.Cleanup(func() { _ = UpdateChannelSelectionByJSONString(old) })
+}
+
+func setLatencyExplorationRate(t *testing.T, rate float64) {
+	old := latencyExplorationRate
+	latencyExplorationRate = rate
+	t.Cleanup(func() { latencyExplorationRate = old })
+}
+
+func pickCounts(times int, pick func() *Channel) map[int]int {
+	counts := make(map[int]int)
+	for i := 0; i < times; i++ {
+		counts[pick().Id]++
+	}
+	return counts
+}
+
+func TestUpdateChannelSelectionByJSONString(t *testing.T) {
+	setChannelSelection(t, `{"vip":"latency","default":"round_robin"}`)
+	assert.Equal(t, ChannelSelectionLatency, GetChannelSelection("vip"))
+	assert.Equal(t, ChannelSelectionRoundRobin, GetChannelSelection("default"))
+	assert.Equal(t, ChannelSelectionRandom, GetChannelSelection("other"))
+
+	// an invalid strategy leaves the strategies untouched
+	assert.Error(t, UpdateChannelSelectionByJSONString(`{"vip":"fastest"}`))
+	assert.Error(t, UpdateChannelSelectionByJSONString(`not json`))
+	assert.Equal(t, ChannelSelectionLatency, GetChannelSelection("vip"))
+}
+
+func TestRecordChannelLatency(t *testing.T) {
+	channel := &Channel{Id: 9001, ResponseTime: 500}
+	t.Cleanup(func() {
+		channelId2latencyLock.Lock()
+		delete(channelId2latency, channel.Id)
+		channelId2latencyLock.Unlock()
+	})
+	// the response time of the last test is used until the channel is measured
+	assert.Equal(t, 500.0, getChannelLatency(channel))
+	RecordChannelLatency(channel.Id, 100*time.Millisecond)
+	assert.Equal(t, 100.0, getChannelLatency(channel))
+	RecordChannelLatency(channel.Id, 200*time.Millisecond)
+	assert.InDelta(t, 130.0, getChannelLatency(channel), 0.001)
+}
+
+func TestSelectLowestLatencyChannel(t *testing.T) {
+	fast := &Channel{Id: 9011, ResponseTime: 100}
+	slow := &Channel{Id: 9012, ResponseTime: 900}
+	tied := &Channel{Id: 9013, ResponseTime: 100}
+
+	setLatencyExplorationRate(t, 0)
+	counts := pickCounts(200, func() *Channel { return selectLowestLatencyChannel([]*Channel{slow, fast}) })
+	assert.Equal(t, 200, counts[fast.Id])
+	counts = pickCounts(200, func() *Channel { return selectLowestLatencyChannel([]*Channel{slow, fast, tied}) })
+	assert.Zero(t, counts[slow.Id])
+	assert.Positive(t, counts[fast.Id])
+	assert.Positive(t, counts[tied.Id])
+
+	// the slow channel is explored now and then
+	setLatencyExplorationRate(t, 1)
+	counts = pickCounts(200, func() *Channel { return selectLowestLatencyChannel([]*Channel{slow, fast}) })
+	assert.Positive(t, counts[slow.Id])
+}
+
+func TestSelectWeightedChannel(t *testing.T) {
+	weight := func(w uint) *uint { return &w }
+	heavy := &Channel{Id: 9021, Weight: weight(9)}
+	light := &Channel{Id: 9022, Weight: weight(1)}
+	none := &Channel{Id: 9023}
+
+	counts := pickCounts(2000, func() *Channel { return selectWeightedChannel([]*Channel{heavy, light, none}) })
+	assert.Zero(t, counts[none.Id])
+	assert.Greater(t, counts[heavy.Id], counts[light.Id]*3)
+	assert.Positive(t, counts[light.Id])
+
+	// the channels are picked evenly when none has weight
+	other := &Channel{Id: 9024}
+	counts = pickCounts(200, func() *Channel { return selectWeightedChannel([]*Channel{none, other}) })
+	assert.Positive(t, counts[none.Id])
+	assert.Positive(t, counts[other.Id])
+}
+
+func TestSelectChannelRoundRobin(t *testing.T) {
+	setChannelSelection(t, `{"rr-test":"round_robin"}`)
+	channels := []*Channel{{Id: 9031}, {Id: 9032}, {Id: 9033}}
+	var picked []int
+	for i := 0; i < 6; i++ {
+		picked = append(picked, selectChannel("rr-test", "gpt-4o", channels).Id)
+	}
+	assert.Equal(t, picked[:3], picked[3:])
+	assert.ElementsMatch(t, []int{9031, 9032, 9033}, picked[:3])
+}
+
+func TestSelectChannelSkipsSaturated(t *testing.T) {
+	one := 1
+	busy := &Channel{Id: 9041, MaxConcurrency: &one}
+	idle := &Channel{Id: 9042, MaxConcurrency: &one}
+	release, err := concurrency.Acquire(context.Background(), busy.Id, one)
+	require.NoError(t, err)
+	defer release()
+
+	counts := pickCounts(50, func() *Channel { return selectChannel("default", "gpt-4o", []*Channel{busy, idle}) })
+	assert.Equal(t, 50, counts[idle.Id])
+	// every channel is kept when all of them are saturated
+	assert.Equal(t, busy, selectChannel("default", "gpt-4o", []*Channel{busy}))
+}
+
+func TestFilterAvailableChannels(t *testing.T) {
+	enabled := config.CircuitBreakerEnabled
+	config.CircuitBreakerEnabled = true
+	t.Cleanup(func() {
+		config.CircuitBreakerEnabled = enabled
+		breaker.Reset(9051)
+	})
+	broken := &Channel{Id: 9051}
+	healthy := &Channel{Id: 9052}
+	for i := 0; i < 100 && breaker.Allow(broken.Id, "gpt-4o"); i++ {
+		breaker.Record(broken.Id, "gpt-4o", false)
+	}
+	require.False(t, breaker.Allow(broken.Id, "gpt-4o"))
+
+	assert.Equal(t, []*Channel{healthy}, filterAvailableChannels("gpt-4o", []*Channel{broken, healthy}))
+	// a broken channel is better than none
+	assert.Equal(t, []*Channel{broken}, filterAvailableChannels("gpt-4o", []*Channel{broken}))
+}
@@ -0,0 +1,272 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Subscription binds a user to a plan, a user subscribes to one plan at most.
+// the quota of the plan is granted into the quota of the user, the part of it which is not used
+// by the end of the period is measured by the growth of the used quota and taken back
+type Subscription struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"uniqueIndex"`
+	PlanId        int    `json:"plan_id" gorm:"index"`
+	StartTime     int64  `json:"start_time" gorm:"bigint"`
+	ExpiredTime   int64  `json:"expired_time" gorm:"bigint;default:-1"` // -1 means never expired
+	ResetTime     int64  `json:"reset_time" gorm:"bigint;index"`        // the end of the current period
+	PeriodQuota   int64  `json:"period_quota" gorm:"bigint;default:0"`  // the quota granted in the current period
+	BaseUsedQuota int64  `json:"-" gorm:"bigint;default:0"`             // the used quota of the user when the current period began
+	PreviousGroup string `json:"-" gorm:"type:varchar(32);default:''"`  // the group of the user before subscribing
+	RemainQuota   int64  `json:"remain_quota" gorm:"-:all"`             // only for api response
+	Plan          *Plan  `json:"plan,omitempty" gorm:"-:all"`           // only for api response
+}
+
+// remainQuota returns the quota of the current period which is not used yet, it never exceeds the quota of the user
+func (subscription *Subscription) remainQuota(user *User) int64 {
+	remain := subscription.PeriodQuota - (user.UsedQuota - subscription.BaseUsedQuota)
+	if remain > user.Quota {
+		remain = user.Quota
+	}
+	if remain < 0 {
+		remain = 0
+	}
+	return remain
+}
+
+// GetSubscriptionByUserId returns the subscription of the user along with its plan, nil if the user subscribes to none
+func GetSubscriptionByUserId(userId int) (*Subscription, error) {
+	var subscriptions []*Subscription
+	if err := DB.Where("user_id = ?", userId).Limit(1).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+	subscription := subscriptions[0]
+	user := User{}
+	if err := DB.Select("id", "quota", "used_quota").First(&user, "id = ?", userId).Error; err != nil {
+		return nil, err
+	}
+	addPendingQuota(&user)
+	subscription.RemainQuota = subscription.remainQuota(&user)
+	plan, err := GetPlanById(subscription.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Plan = plan
+	return subscription, nil
+}
+
+// GetUserPlanModels returns the models the plan of the user allows, empty if there is no restriction
+func GetUserPlanModels(userId int) (models string, err error) {
+	err = DB.Model(&Plan{}).Joins("JOIN subscriptions ON subscriptions.plan_id = plans.id").
+		Where("subscriptions.user_id = ?", userId).Select("plans.models").Find(&models).Error
+	return models, err
+}
+
+// addPendingQuota adds the quota changes waiting for the next batch update to the user,
+// so that the used quota of the period is measured in full when batch update is enabled
+func addPendingQuota(user *User) {
+	if !config.BatchUpdateEnabled {
+		return
+	}
+	user.Quota += getPendingRecord(BatchUpdateTypeUserQuota, user.Id)
+	user.UsedQuota += getPendingRecord(BatchUpdateTypeUsedQuota, user.Id)
+}
+
+func lockUser(tx *gorm.DB, userId int) (*User, error) {
+	user := &User{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "quota", "used_quota", "group").First(user, "id = ?", userId).Error
+	if err != nil {
+		return nil, err
+	}
+	addPendingQuota(user)
+	return user, nil
+}
+
+// settleSubscription takes back the quota of the current period which is not used
+func settleSubscription(tx *gorm.DB, subscription *Subscription, user *User) (int64, error) {
+	remain := subscription.remainQuota(user)
+	if remain == 0 {
+		return 0, nil
+	}
+	err := tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", gorm.Expr("quota - ?", remain)).Error
+	return remain, err
+}
+
+func setUserGroup(tx *gorm.DB, userId int, group string) error {
+	return tx.Model(&User{}).Where("id = ?", userId).Update("group", group).Error
+}
+
+func invalidateSubscriptionCache(userId int) {
+	if !common.RedisEnabled {
+		return
+	}
+	for _, key := range []string{"user_quota:%d", "user_group:%d", "user_plan_models:%d"} {
+		if err := common.RedisDel(fmt.Sprintf(key, userId)); err != nil {
+			logger.SysError("Redis del subscription cache error: " + err.Error())
+		}
+	}
+}
+
+// Subscribe subscribes the user to the plan and grants the quota of the first period,
+// the subscription the user has is replaced along with the quota it has left
+func Subscribe(userId int, planId int) error {
+	plan, err := GetPlanById(planId)
+	if err != nil {
+		return errors.New("无效的套餐")
+	}
+	if plan.Status != PlanStatusEnabled {
+		return errors.New("该套餐已被禁用")
+	}
+	now := helper.GetTimestamp()
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		subscription := &Subscription{UserId: userId, PreviousGroup: user.Group}
+		var existing []*Subscription
+		if err = tx.Where("user_id = ?", userId).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			subscription = existing[0]
+			remain, err := settleSubscription(tx, subscription, user)
+			if err != nil {
+				return err
+			}
+			user.Quota -= remain
+		}
+		subscription.PlanId = plan.Id
+		subscription.StartTime = now
+		subscription.ExpiredTime = -1
+		if plan.Duration > 0 {
+			subscription.ExpiredTime = now + int64(plan.Duration)*24*60*60
+		}
+		subscription.ResetTime = plan.nextResetTime(now)
+		subscription.PeriodQuota = plan.Quota
+		subscription.BaseUsedQuota = user.UsedQuota
+		if err = tx.Save(subscription).Error; err != nil {
+			return err
+		}
+		if plan.Group != "" {
+			if err = setUserGroup(tx, userId, plan.Group); err != nil {
+				return err
+			}
+		} else if user.Group != subscription.PreviousGroup {
+			if err = setUserGroup(tx, userId, subscription.PreviousGroup); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", plan.Quota)).Error
+	})
+	if err != nil {
+		return errors.New("订阅失败，" + err.Error())
+	}
+	invalidateSubscriptionCache(userId)
+	RecordLog(userId, LogTypeTopup, fmt.Sprintf("订阅套餐 %s，获得额度 %s", plan.Name, common.LogQuota(plan.Quota)))
+	return nil
+}
+
+// Unsubscribe ends the subscription of the user, the quota left from the current period is taken back
+// and the user is moved back to the group before subscribing
+func Unsubscribe(userId int) error {
+	var remain int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userId)
+		if err != nil {
+			return err
+		}
+		var subscriptions []*Subscription
+		if err = tx.Where("user_id = ?", userId).Limit(1).Find(&subscriptions).Error; err != nil {
+			return err
+		}
+		if len(subscriptions) == 0 {
+			return errors.New("用户未订阅任何套餐")
+		}
+		subscription := subscriptions[0]
+		if remain, err = settleSubscription(tx, subscription, user); err != nil {
+			return err
+		}
+		if subscription.PreviousGroup != "" && subscription.PreviousGroup != user.Group {
+			if err = setUserGroup(tx, userId, subscription.PreviousGroup); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(subscription).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateSubscriptionCache(userId)
+	RecordLog(userId, LogTypeSystem, fmt.Sprintf("套餐订阅结束，收回未使用的周期额度 %s", common.LogQuota(remain)))
+	return nil
+}
+
+// renewSubscription starts the next period of the subscription, the quota left from the previous period is replaced
+// by the quota of the plan. the periods missed while the server is down are skipped
+func renewSubscription(subscription *Subscription, now int64) error {
+	plan, err := GetPlanById(subscription.PlanId)
+	if err != nil {
+		return err
+	}
+	var remain int64
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, subscription.UserId)
+		if err != nil {
+			return err
+		}
+		if remain, err = settleSubscription(tx, subscription, user); err != nil {
+			return err
+		}
+		for subscription.ResetTime <= now {
+			subscription.ResetTime = plan.nextResetTime(subscription.ResetTime)
+		}
+		subscription.PeriodQuota = plan.Quota
+		subscription.BaseUsedQuota = user.UsedQuota
+		if err = tx.Model(subscription).Select("reset_time", "period_quota", "base_used_quota").Updates(subscription).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ?", subscription.UserId).Update("quota", gorm.Expr("quota + ?", plan.Quota)).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateSubscriptionCache(subscription.UserId)
+	RecordLog(subscription.UserId, LogTypeTopup, fmt.Sprintf("套餐 %s 额度重置，收回 %s，获得 %s", plan.Name, common.LogQuota(remain), common.LogQuota(plan.Quota)))
+	return nil
+}
+
+// ResetSubscriptions ends the expired subscriptions and renews those whose period is over,
+// it returns the number of the subscriptions handled
+func ResetSubscriptions() (int, error) {
+	now := helper.GetTimestamp()
+	var subscriptions []*Subscription
+	err := DB.Where("reset_time <= ? or (expired_time != -1 and expired_time <= ?)", now, now).Find(&subscriptions).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, subscription := range subscriptions {
+		if subscription.ExpiredTime != -1 && subscription.ExpiredTime <= now {
+			err = Unsubscribe(subscription.UserId)
+		} else {
+			err = renewSubscription(subscription, now)
+		}
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to reset the subscription of user %d: %s", subscription.UserId, err.Error()))
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
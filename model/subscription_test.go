@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB opens an in-memory database for the test, it is left in place afterwards, and redis is
+// left disabled, as the updates run in the background may still be using them
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}, &Token{}, &Log{}, &Plan{}, &Subscription{}, &TopUpOrder{}, &Webhook{}, &WebhookDelivery{}, &Organization{}, &OrganizationMember{}, &Budget{}))
+	DB, LOG_DB = db, db
+	invalidateBudgetCache()
+	common.RedisEnabled = false
+	usingSQLite := common.UsingSQLite
+	common.UsingSQLite = true
+	t.Cleanup(func() {
+		common.UsingSQLite = usingSQLite
+	})
+}
+
+func TestPlanNextResetTime(t *testing.T) {
+	daily := &Plan{Period: PlanPeriodDaily}
+	monthly := &Plan{Period: PlanPeriodMonthly}
+	from := time.Date(2024, 1, 15, 8, 30, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2024, 1, 16, 8, 30, 0, 0, time.Local).Unix(), daily.nextResetTime(from.Unix()))
+	assert.Equal(t, time.Date(2024, 2, 15, 8, 30, 0, 0, time.Local).Unix(), monthly.nextResetTime(from.Unix()))
+
+	// the periods across the end of a month and a year
+	from = time.Date(2023, 12, 31, 23, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2024, 1, 1, 23, 0, 0, 0, time.Local).Unix(), daily.nextResetTime(from.Unix()))
+	assert.Equal(t, time.Date(2024, 1, 31, 23, 0, 0, 0, time.Local).Unix(), monthly.nextResetTime(from.Unix()))
+	// a month without the day overflows into the next one
+	from = time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local).Unix(), monthly.nextResetTime(from.Unix()))
+}
+
+func TestSubscriptionRemainQuota(t *testing.T) {
+	subscription := &Subscription{PeriodQuota: 1000, BaseUsedQuota: 500}
+	assert.Equal(t, int64(700), subscription.remainQuota(&User{Quota: 5000, UsedQuota: 800}))
+	// the quota of the period is used up
+	assert.Equal(t, int64(0), subscription.remainQuota(&User{Quota: 5000, UsedQuota: 2000}))
+	// the quota of the user is less than the quota left from the period
+	assert.Equal(t, int64(300), subscription.remainQuota(&User{Quota: 300, UsedQuota: 500}))
+	assert.Equal(t, int64(0), subscription.remainQuota(&User{Quota: -10, UsedQuota: 500}))
+}
+
+func TestRenewSubscription(t *testing.T) {
+	setupTestDB(t)
+	plan := &Plan{Name: "pro", Status: PlanStatusEnabled, Quota: 1000, Period: PlanPeriodDaily}
+	require.NoError(t, plan.Insert())
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "subscriber", Quota: 200, UsedQuota: 100, Group: "default", Status: UserStatusEnabled}).Error)
+	require.NoError(t, Subscribe(1, plan.Id))
+
+	subscription, err := GetSubscriptionByUserId(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), subscription.RemainQuota)
+	assert.Equal(t, int64(100), subscription.BaseUsedQuota)
+
+	// 300 of the period is used, 100 of it is still waiting for the batch update
+	require.NoError(t, DB.Model(&User{}).Where("id = ?", 1).Updates(map[string]any{"quota": 1200 - 200, "used_quota": 100 + 200}).Error)
+	batchUpdateEnabled := config.BatchUpdateEnabled
+	config.BatchUpdateEnabled = true
+	t.Cleanup(func() {
+		config.BatchUpdateEnabled = batchUpdateEnabled
+		for _, type_ := range []int{BatchUpdateTypeUserQuota, BatchUpdateTypeUsedQuota} {
+			batchUpdateLocks[type_].Lock()
+			delete(batchUpdateStores[type_], 1)
+			batchUpdateLocks[type_].Unlock()
+		}
+	})
+	addNewRecord(BatchUpdateTypeUserQuota, 1, -100)
+	addNewRecord(BatchUpdateTypeUsedQuota, 1, 100)
+	subscription, err = GetSubscriptionByUserId(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), subscription.RemainQuota)
+
+	// the 700 left is taken back and the quota of the next period is granted
+	now := subscription.ResetTime + 1
+	require.NoError(t, renewSubscription(subscription, now))
+	user, err := GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000-700+1000), user.Quota)
+	assert.Equal(t, int64(400), subscription.BaseUsedQuota)
+	assert.Greater(t, subscription.ResetTime, now)
+
+	require.NoError(t, Unsubscribe(1))
+	user, err = GetUserById(1, true)
+	require.NoError(t, err)
+	// the whole quota of the new period is taken back
+	assert.Equal(t, int64(1300-1000), user.Quota)
+}
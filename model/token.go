@@ -32,6 +32,12 @@ type Token struct {
 	UsedQuota      int64   `json:"used_quota" gorm:"bigint;default:0"` // used quota
 	Models         *string `json:"models" gorm:"type:text"`            // allowed models
 	Subnet         *string `json:"subnet" gorm:"default:''"`           // allowed subnet
+	ResponseCache  bool    `json:"response_cache" gorm:"default:false"`
+	RpmLimit       int64   `json:"rpm_limit" gorm:"bigint;default:0"`      // requests per minute, 0 means unlimited
+	TpmLimit       int64   `json:"tpm_limit" gorm:"bigint;default:0"`      // tokens per minute, 0 means unlimited
+	AuditLog       bool    `json:"audit_log" gorm:"default:false"`         // keep the bodies of the requests in the audit log
+	ModelFallback  *string `json:"model_fallback" gorm:"type:text"`        // fallback chains overriding those of the group
+	OrganizationId int     `json:"organization_id" gorm:"index;default:0"` // the organization whose quota the token draws, 0 means the user
 }
 
 func GetAllUserTokens(userId int, startIdx int, num int, order string) ([]*Token, error) {
@@ -124,13 +130,16 @@ func GetTokenById(id int) (*Token, error) {
 func (t *Token) Insert() error {
 	var err error
 	err = DB.Create(t).Error
+	if err == nil {
+		go EmitWebhookEvent(WebhookEventTokenCreated, t.webhookEventData())
+	}
 	return err
 }
 
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (t *Token) Update() error {
 	var err error
-	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
+	err = DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet", "response_cache", "rpm_limit", "tpm_limit", "audit_log", "model_fallback").Updates(t).Error
 	return err
 }
 
@@ -142,9 +151,22 @@ func (t *Token) SelectUpdate() error {
 func (t *Token) Delete() error {
 	var err error
 	err = DB.Delete(t).Error
+	if err == nil {
+		go EmitWebhookEvent(WebhookEventTokenDeleted, t.webhookEventData())
+	}
 	return err
 }
 
+// webhookEventData returns the data of the webhook events of the token, the key is left out
+func (t *Token) webhookEventData() map[string]interface{} {
+	return map[string]interface{}{
+		"token_id":        t.Id,
+		"user_id":         t.UserId,
+		"name":            t.Name,
+		"organization_id": t.OrganizationId,
+	}
+}
+
 func (t *Token) GetModels() string {
 	if t == nil {
 		return ""
@@ -212,6 +234,28 @@ func decreaseTokenQuota(id int, quota int64) (err error) {
 	return err
 }
 
+// quotaExhaustedEvent is the data of the user.quota_exhausted event, the organization is set when it is
+// the quota of the organization running out
+type quotaExhaustedEvent struct {
+	UserId         int   `json:"user_id"`
+	OrganizationId int   `json:"organization_id,omitempty"`
+	Quota          int64 `json:"quota"`
+}
+
+// notifyOrganizationQuotaExhausted emits the event when the charge takes the quota of the organization to zero
+func notifyOrganizationQuotaExhausted(token *Token, quota int64) {
+	if quota <= 0 {
+		return
+	}
+	organization, err := GetOrganizationById(token.OrganizationId)
+	if err != nil {
+		return
+	}
+	if organization.Quota <= 0 && organization.Quota+quota > 0 {
+		EmitWebhookEvent(WebhookEventUserQuotaExhausted, quotaExhaustedEvent{UserId: token.UserId, OrganizationId: token.OrganizationId, Quota: organization.Quota})
+	}
+}
+
 func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -223,6 +267,13 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	if !token.UnlimitedQuota && token.RemainQuota < quota {
 		return errors.New("令牌额度不足")
 	}
+	if token.OrganizationId != 0 {
+		if err = preConsumeOrganizationTokenQuota(token, quota); err == nil {
+			go recordBudgetConsumption(token.UserId, token.Id, quota)
+			go notifyOrganizationQuotaExhausted(token, quota)
+		}
+		return err
+	}
 	userQuota, err := GetUserQuota(token.UserId)
 	if err != nil {
 		return err
@@ -241,6 +292,7 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 			prompt := "您的额度即将用尽"
 			if noMoreQuota {
 				prompt = "您的额度已用尽"
+				EmitWebhookEvent(WebhookEventUserQuotaExhausted, quotaExhaustedEvent{UserId: token.UserId, Quota: userQuota - quota})
 			}
 			if email != "" {
 				topUpLink := fmt.Sprintf("%s/topup", config.ServerAddress)
@@ -259,19 +311,47 @@ func PreConsumeTokenQuota(tokenId int, quota int64) (err error) {
 		}
 	}
 	err = DecreaseUserQuota(token.UserId, quota)
+	if err == nil {
+		go recordBudgetConsumption(token.UserId, token.Id, quota)
+	}
 	return err
 }
 
+func preConsumeOrganizationTokenQuota(token *Token, quota int64) error {
+	if err := reserveOrganizationQuota(token.OrganizationId, token.UserId, quota); err != nil {
+		return err
+	}
+	if !token.UnlimitedQuota {
+		if err := DecreaseTokenQuota(token.Id, quota); err != nil {
+			if refundErr := consumeOrganizationQuota(DB, token.OrganizationId, token.UserId, -quota); refundErr != nil {
+				logger.SysError("failed to refund organization quota: " + refundErr.Error())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func PostConsumeTokenQuota(tokenId int, quota int64) (err error) {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
 		return err
 	}
-	if quota > 0 {
+	if token.OrganizationId != 0 {
+		if err = consumeOrganizationQuota(DB, token.OrganizationId, token.UserId, quota); err == nil {
+			go notifyOrganizationQuotaExhausted(token, quota)
+		}
+	} else if quota > 0 {
+		if userQuota, quotaErr := GetUserQuota(token.UserId); quotaErr == nil && userQuota > 0 && userQuota-quota <= 0 {
+			go EmitWebhookEvent(WebhookEventUserQuotaExhausted, quotaExhaustedEvent{UserId: token.UserId, Quota: userQuota - quota})
+		}
 		err = DecreaseUserQuota(token.UserId, quota)
 	} else {
 		err = IncreaseUserQuota(token.UserId, -quota)
 	}
+	if err == nil {
+		go recordBudgetConsumption(token.UserId, token.Id, quota)
+	}
 	if !token.UnlimitedQuota {
 		if quota > 0 {
 			err = DecreaseTokenQuota(tokenId, quota)
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	TopUpOrderStatusPending = 1 // don't use 0, 0 is the default value!
+	TopUpOrderStatusPaid    = 2
+	TopUpOrderStatusFailed  = 3
+)
+
+// TopUpOrder is an online top-up, the quota is credited once the provider notifies the payment
+type TopUpOrder struct {
+	Id              int     `json:"id"`
+	UserId          int     `json:"user_id" gorm:"index"`
+	Username        string  `json:"username" gorm:"index;default:''"`
+	TradeNo         string  `json:"trade_no" gorm:"type:varchar(64);uniqueIndex"`
+	Provider        string  `json:"provider" gorm:"type:varchar(32)"`
+	ProviderTradeNo string  `json:"provider_trade_no" gorm:"type:varchar(255);default:''"`
+	Amount          int     `json:"amount"` // units of quota, i.e. QuotaPerUnit
+	Quota           int64   `json:"quota" gorm:"bigint"`
+	Money           float64 `json:"money"`
+	Currency        string  `json:"currency" gorm:"type:varchar(16)"`
+	Status          int     `json:"status" gorm:"default:1"`
+	CreatedTime     int64   `json:"created_time" gorm:"bigint;index"`
+	PaidTime        int64   `json:"paid_time" gorm:"bigint"`
+}
+
+func (order *TopUpOrder) Insert() error {
+	return DB.Create(order).Error
+}
+
+// GetTopUpOrders returns the orders of the user in reverse order, the orders of all users if the user id is 0
+func GetTopUpOrders(userId int, status int, startIdx int, num int) (orders []*TopUpOrder, err error) {
+	tx := DB.Order("id desc")
+	if userId != 0 {
+		tx = tx.Where("user_id = ?", userId)
+	}
+	if status != 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	err = tx.Limit(num).Offset(startIdx).Find(&orders).Error
+	return orders, err
+}
+
+func GetTopUpOrderByTradeNo(tradeNo string) (*TopUpOrder, error) {
+	if tradeNo == "" {
+		return nil, errors.New("订单号为空")
+	}
+	order := &TopUpOrder{}
+	err := DB.First(order, "trade_no = ?", tradeNo).Error
+	return order, err
+}
+
+// CompleteTopUpOrder marks the pending order as paid and credits its quota to the user in a transaction. the notifications
+// are retried by the providers, only the one which moves the order out of pending credits, the others are no-ops
+func CompleteTopUpOrder(order *TopUpOrder, providerTradeNo string) (bool, error) {
+	credited := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&TopUpOrder{}).Where("id = ? and status = ?", order.Id, TopUpOrderStatusPending).Updates(map[string]interface{}{
+			"status":            TopUpOrderStatusPaid,
+			"provider_trade_no": providerTradeNo,
+			"paid_time":         helper.GetTimestamp(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		credited = true
+		return tx.Model(&User{}).Where("id = ?", order.UserId).Update("quota", gorm.Expr("quota + ?", order.Quota)).Error
+	})
+	if err != nil {
+		// the order is left pending, so that the retried notification credits it
+		logger.SysError(fmt.Sprintf("failed to complete top-up order %s: %s", order.TradeNo, err.Error()))
+		return false, err
+	}
+	if !credited {
+		return false, nil
+	}
+	RecordTopupLog(order.UserId, fmt.Sprintf("通过 %s 在线充值 %s，支付 %.2f %s，订单号 %s", order.Provider, common.LogQuota(order.Quota), order.Money, order.Currency, order.TradeNo), int(order.Quota))
+	go EmitWebhookEvent(WebhookEventTopUpCompleted, map[string]interface{}{
+		"user_id":  order.UserId,
+		"trade_no": order.TradeNo,
+		"provider": order.Provider,
+		"quota":    order.Quota,
+		"money":    order.Money,
+		"currency": order.Currency,
+	})
+	return true, nil
+}
+
+// FailTopUpOrder marks the pending order as failed
+func FailTopUpOrder(order *TopUpOrder) error {
+	return DB.Model(&TopUpOrder{}).Where("id = ? and status = ?", order.Id, TopUpOrderStatusPending).Update("status", TopUpOrderStatusFailed).Error
+}
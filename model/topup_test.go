@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteTopUpOrder(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "payer", Quota: 100, Status: UserStatusEnabled}).Error)
+	order := &TopUpOrder{UserId: 1, TradeNo: "T1", Provider: "stripe", Quota: 5000, Money: 10, Currency: "USD", Status: TopUpOrderStatusPending}
+	require.NoError(t, order.Insert())
+
+	credited, err := CompleteTopUpOrder(order, "cs_1")
+	require.NoError(t, err)
+	assert.True(t, credited)
+	// the retried notification credits nothing
+	credited, err = CompleteTopUpOrder(order, "cs_1")
+	require.NoError(t, err)
+	assert.False(t, credited)
+
+	user, err := GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5100), user.Quota)
+	order, err = GetTopUpOrderByTradeNo("T1")
+	require.NoError(t, err)
+	assert.Equal(t, TopUpOrderStatusPaid, order.Status)
+	assert.Equal(t, "cs_1", order.ProviderTradeNo)
+}
+
+func TestCompleteTopUpOrderRollback(t *testing.T) {
+	setupTestDB(t)
+	order := &TopUpOrder{UserId: 1, TradeNo: "T2", Provider: "stripe", Quota: 5000, Status: TopUpOrderStatusPending}
+	require.NoError(t, order.Insert())
+	// the order is left pending when the quota cannot be credited
+	require.NoError(t, DB.Migrator().DropTable(&User{}))
+	_, err := CompleteTopUpOrder(order, "cs_2")
+	assert.Error(t, err)
+	order, err = GetTopUpOrderByTradeNo("T2")
+	require.NoError(t, err)
+	assert.Equal(t, TopUpOrderStatusPending, order.Status)
+}
@@ -19,6 +19,8 @@ const (
 var batchUpdateStores []map[int]int64
 var batchUpdateLocks []sync.Mutex
 
+var batchUpdateTypeNames = []string{"user_quota", "token_quota", "used_quota", "channel_used_quota", "request_count"}
+
 func init() {
 	for i := 0; i < BatchUpdateTypeCount; i++ {
 		batchUpdateStores = append(batchUpdateStores, make(map[int]int64))
@@ -45,6 +47,24 @@ func addNewRecord(type_ int, id int, value int64) {
 	}
 }
 
+// getPendingRecord returns the value of the record waiting for the next batch update
+func getPendingRecord(type_ int, id int) int64 {
+	batchUpdateLocks[type_].Lock()
+	defer batchUpdateLocks[type_].Unlock()
+	return batchUpdateStores[type_][id]
+}
+
+// GetBatchUpdateQueueDepth returns the records waiting for the next batch update by type
+func GetBatchUpdateQueueDepth() map[string]int {
+	depth := make(map[string]int)
+	for i := 0; i < BatchUpdateTypeCount; i++ {
+		batchUpdateLocks[i].Lock()
+		depth[batchUpdateTypeNames[i]] = len(batchUpdateStores[i])
+		batchUpdateLocks[i].Unlock()
+	}
+	return depth
+}
+
 func batchUpdate() {
 	logger.SysLog("batch update started")
 	for i := 0; i < BatchUpdateTypeCount; i++ {
@@ -0,0 +1,285 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/webhook"
+)
+
+const (
+	WebhookStatusEnabled  = 1 // don't use 0, 0 is the default value!
+	WebhookStatusDisabled = 2 // also don't use 0
+)
+
+const (
+	WebhookDeliveryStatusPending = 1
+	WebhookDeliveryStatusSuccess = 2
+	WebhookDeliveryStatusFailed  = 3
+)
+
+const (
+	WebhookEventAll                = "*"
+	WebhookEventChannelDisabled    = "channel.disabled"
+	WebhookEventChannelEnabled     = "channel.enabled"
+	WebhookEventChannelBalanceLow  = "channel.balance_low"
+	WebhookEventUserQuotaExhausted = "user.quota_exhausted"
+	WebhookEventTopUpCompleted     = "topup.completed"
+	WebhookEventTokenCreated       = "token.created"
+	WebhookEventTokenDeleted       = "token.deleted"
+	WebhookEventRedemptionUsed     = "redemption.used"
+	WebhookEventBudgetThreshold    = "budget.threshold"
+)
+
+var WebhookEvents = []string{
+	WebhookEventChannelDisabled,
+	WebhookEventChannelEnabled,
+	WebhookEventChannelBalanceLow,
+	WebhookEventUserQuotaExhausted,
+	WebhookEventTopUpCompleted,
+	WebhookEventTokenCreated,
+	WebhookEventTokenDeleted,
+	WebhookEventRedemptionUsed,
+	WebhookEventBudgetThreshold,
+}
+
+// a delivery is leased for a while before it is attempted, so that the retrying node leaves it alone meanwhile
+const webhookDeliveryLease = time.Minute
+
+// Webhook is an endpoint of the admins subscribing to the events of the system
+type Webhook struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	URL         string `json:"url" gorm:"column:url;type:varchar(512)"`
+	Secret      string `json:"secret,omitempty" gorm:"type:varchar(128)"`
+	Events      string `json:"events" gorm:"type:varchar(1024)"` // comma separated, * subscribes to all the events
+	Status      int    `json:"status" gorm:"default:1"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+// WebhookDelivery is an event sent to an endpoint, it is retried with backoff until it succeeds or runs out of attempts
+type WebhookDelivery struct {
+	Id              int    `json:"id"`
+	WebhookId       int    `json:"webhook_id" gorm:"index"`
+	Event           string `json:"event" gorm:"type:varchar(64);index"`
+	Payload         string `json:"payload" gorm:"type:text"`
+	Status          int    `json:"status" gorm:"index:idx_webhook_delivery_pending"`
+	Attempts        int    `json:"attempts" gorm:"default:0"`
+	ResponseCode    int    `json:"response_code" gorm:"default:0"`
+	Error           string `json:"error" gorm:"type:varchar(512);default:''"`
+	NextAttemptTime int64  `json:"next_attempt_time" gorm:"bigint;index:idx_webhook_delivery_pending"`
+	CreatedTime     int64  `json:"created_time" gorm:"bigint;index"`
+	DeliveredTime   int64  `json:"delivered_time" gorm:"bigint;default:0"`
+}
+
+func isWebhookEvent(event string) bool {
+	for _, known := range WebhookEvents {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (hook *Webhook) Validate() error {
+	if hook.Name == "" || len(hook.Name) > 64 {
+		return errors.New("名称长度必须在1-64之间")
+	}
+	if err := webhook.ValidateURL(hook.URL); err != nil {
+		if errors.Is(err, webhook.ErrForbiddenAddress) {
+			return errors.New("Webhook 地址不能为内网、回环或链路本地地址")
+		}
+		return errors.New("Webhook 地址必须为 https:// 开头的有效地址")
+	}
+	events := make([]string, 0)
+	for _, event := range strings.Split(hook.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event == "" {
+			continue
+		}
+		if event != WebhookEventAll && !isWebhookEvent(event) {
+			return fmt.Errorf("不支持的事件：%s", event)
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return errors.New("请至少订阅一个事件")
+	}
+	hook.Events = strings.Join(events, ",")
+	if hook.Status != WebhookStatusDisabled {
+		hook.Status = WebhookStatusEnabled
+	}
+	return nil
+}
+
+func (hook *Webhook) subscribes(event string) bool {
+	for _, subscribed := range strings.Split(hook.Events, ",") {
+		if subscribed == WebhookEventAll || subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAllWebhooks returns the endpoints, the secrets are left out
+func GetAllWebhooks() (hooks []*Webhook, err error) {
+	err = DB.Omit("secret").Order("id desc").Find(&hooks).Error
+	return hooks, err
+}
+
+func GetWebhookById(id int) (*Webhook, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	hook := Webhook{Id: id}
+	err := DB.First(&hook, "id = ?", id).Error
+	return &hook, err
+}
+
+func (hook *Webhook) Insert() error {
+	hook.CreatedTime = helper.GetTimestamp()
+	return DB.Create(hook).Error
+}
+
+// Update Make sure your webhook's fields is completed, because this will update zero values
+func (hook *Webhook) Update() error {
+	return DB.Model(hook).Select("name", "url", "secret", "events", "status").Updates(hook).Error
+}
+
+// DeleteWebhookById deletes the endpoint, the pending deliveries are given up
+func DeleteWebhookById(id int) error {
+	if id == 0 {
+		return errors.New("id 为空！")
+	}
+	err := DB.Model(&WebhookDelivery{}).Where("webhook_id = ? and status = ?", id, WebhookDeliveryStatusPending).
+		Updates(map[string]interface{}{"status": WebhookDeliveryStatusFailed, "error": "webhook is deleted"}).Error
+	if err != nil {
+		return err
+	}
+	return DB.Delete(&Webhook{Id: id}).Error
+}
+
+func GetWebhookDeliveries(webhookId int, event string, status int, startIdx int, num int) (deliveries []*WebhookDelivery, err error) {
+	tx := DB.Order("id desc")
+	if webhookId != 0 {
+		tx = tx.Where("webhook_id = ?", webhookId)
+	}
+	if event != "" {
+		tx = tx.Where("event = ?", event)
+	}
+	if status != 0 {
+		tx = tx.Where("status = ?", status)
+	}
+	err = tx.Limit(num).Offset(startIdx).Find(&deliveries).Error
+	return deliveries, err
+}
+
+type webhookPayload struct {
+	Event       string      `json:"event"`
+	CreatedTime int64       `json:"created_time"`
+	Data        interface{} `json:"data"`
+}
+
+// EmitWebhookEvent queues the event for the endpoints subscribing to it and tries to deliver it at once,
+// the deliveries failing are retried by RetryWebhookDeliveries
+func EmitWebhookEvent(event string, data interface{}) {
+	var hooks []*Webhook
+	if err := DB.Where("status = ?", WebhookStatusEnabled).Find(&hooks).Error; err != nil {
+		logger.SysError("failed to get webhooks: " + err.Error())
+		return
+	}
+	now := helper.GetTimestamp()
+	var payload []byte
+	for _, hook := range hooks {
+		if !hook.subscribes(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(webhookPayload{Event: event, CreatedTime: now, Data: data}); err != nil {
+				logger.SysError(fmt.Sprintf("failed to marshal webhook event %s: %s", event, err.Error()))
+				return
+			}
+		}
+		delivery := &WebhookDelivery{
+			WebhookId:       hook.Id,
+			Event:           event,
+			Payload:         string(payload),
+			Status:          WebhookDeliveryStatusPending,
+			NextAttemptTime: now + int64(webhookDeliveryLease.Seconds()),
+			CreatedTime:     now,
+		}
+		if err := DB.Create(delivery).Error; err != nil {
+			logger.SysError(fmt.Sprintf("failed to queue webhook event %s: %s", event, err.Error()))
+			continue
+		}
+		go attemptWebhookDelivery(hook, delivery)
+	}
+}
+
+func attemptWebhookDelivery(hook *Webhook, delivery *WebhookDelivery) {
+	delivery.Attempts++
+	code, err := webhook.Send(context.Background(), hook.URL, hook.Secret, delivery.Event, delivery.Id, []byte(delivery.Payload))
+	delivery.ResponseCode = code
+	if err == nil {
+		delivery.Status = WebhookDeliveryStatusSuccess
+		delivery.Error = ""
+		delivery.DeliveredTime = helper.GetTimestamp()
+	} else {
+		delivery.Error = err.Error()
+		if len(delivery.Error) > 512 {
+			delivery.Error = delivery.Error[:512]
+		}
+		if delivery.Attempts >= webhook.MaxAttempts {
+			delivery.Status = WebhookDeliveryStatusFailed
+		} else {
+			delivery.NextAttemptTime = time.Now().Add(webhook.Backoff(delivery.Attempts)).Unix()
+		}
+	}
+	err = DB.Model(delivery).Select("attempts", "response_code", "status", "error", "delivered_time", "next_attempt_time").
+		Updates(delivery).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to update webhook delivery #%d: %s", delivery.Id, err.Error()))
+	}
+}
+
+// RetryWebhookDeliveries attempts the pending deliveries which are due, and returns how many are attempted
+func RetryWebhookDeliveries() (int, error) {
+	now := helper.GetTimestamp()
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? and next_attempt_time <= ?", WebhookDeliveryStatusPending, now).
+		Order("id").Limit(100).Find(&deliveries).Error
+	if err != nil {
+		return 0, err
+	}
+	hooks := make(map[int]*Webhook)
+	count := 0
+	for _, delivery := range deliveries {
+		// the lease is taken by a conditional update, so that a delivery is not attempted twice at once
+		result := DB.Model(&WebhookDelivery{}).Where("id = ? and next_attempt_time = ?", delivery.Id, delivery.NextAttemptTime).
+			Update("next_attempt_time", now+int64(webhookDeliveryLease.Seconds()))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		hook, ok := hooks[delivery.WebhookId]
+		if !ok {
+			if hook, err = GetWebhookById(delivery.WebhookId); err != nil {
+				hook = nil
+			}
+			hooks[delivery.WebhookId] = hook
+		}
+		if hook == nil || hook.Status != WebhookStatusEnabled {
+			DB.Model(delivery).Updates(map[string]interface{}{"status": WebhookDeliveryStatusFailed, "error": "webhook is disabled"})
+			continue
+		}
+		attemptWebhookDelivery(hook, delivery)
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupWebhook registers an endpoint subscribing to the events, which responds with the status
+func setupWebhook(t *testing.T, events string, status *int32) (*Webhook, *int32) {
+	allowed := config.WebhookAllowPrivateAddress
+	config.WebhookAllowPrivateAddress = true
+	t.Cleanup(func() { config.WebhookAllowPrivateAddress = allowed })
+	hits := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+	}))
+	t.Cleanup(server.Close)
+	hook := &Webhook{Name: "test", URL: server.URL, Secret: "secret", Events: events, Status: WebhookStatusEnabled}
+	require.NoError(t, hook.Insert())
+	return hook, hits
+}
+
+func queueWebhookDelivery(t *testing.T, hook *Webhook, nextAttemptTime int64) *WebhookDelivery {
+	delivery := &WebhookDelivery{WebhookId: hook.Id, Event: WebhookEventChannelDisabled, Payload: `{}`,
+		Status: WebhookDeliveryStatusPending, NextAttemptTime: nextAttemptTime, CreatedTime: helper.GetTimestamp()}
+	require.NoError(t, DB.Create(delivery).Error)
+	return delivery
+}
+
+func getWebhookDelivery(t *testing.T, id int) *WebhookDelivery {
+	delivery := &WebhookDelivery{}
+	require.NoError(t, DB.First(delivery, "id = ?", id).Error)
+	return delivery
+}
+
+func TestWebhookValidate(t *testing.T) {
+	allowed := config.WebhookAllowPrivateAddress
+	config.WebhookAllowPrivateAddress = false
+	t.Cleanup(func() { config.WebhookAllowPrivateAddress = allowed })
+
+	hook := &Webhook{Name: "ops", URL: "https://example.com/hook", Events: " channel.disabled, ,budget.threshold"}
+	require.NoError(t, hook.Validate())
+	assert.Equal(t, "channel.disabled,budget.threshold", hook.Events)
+	assert.Equal(t, WebhookStatusEnabled, hook.Status)
+	hook = &Webhook{Name: "ops", URL: "https://example.com/hook", Events: WebhookEventAll, Status: WebhookStatusDisabled}
+	require.NoError(t, hook.Validate())
+	assert.Equal(t, WebhookStatusDisabled, hook.Status)
+
+	for _, invalid := range []*Webhook{
+		{URL: "https://example.com/hook", Events: WebhookEventAll},
+		{Name: "ops", URL: "http://example.com/hook", Events: WebhookEventAll},
+		{Name: "ops", URL: "https://127.0.0.1/hook", Events: WebhookEventAll},
+		{Name: "ops", URL: "https://169.254.169.254/latest", Events: WebhookEventAll},
+		{Name: "ops", URL: "https://example.com/hook", Events: "channel.deleted"},
+		{Name: "ops", URL: "https://example.com/hook", Events: " , "},
+	} {
+		assert.Error(t, invalid.Validate())
+	}
+}
+
+func TestWebhookSubscribes(t *testing.T) {
+	hook := &Webhook{Events: "channel.disabled,token.created"}
+	assert.True(t, hook.subscribes(WebhookEventChannelDisabled))
+	assert.True(t, hook.subscribes(WebhookEventTokenCreated))
+	assert.False(t, hook.subscribes(WebhookEventChannelEnabled))
+	hook.Events = WebhookEventAll
+	assert.True(t, hook.subscribes(WebhookEventBudgetThreshold))
+}
+
+func TestAttemptWebhookDelivery(t *testing.T) {
+	setupTestDB(t)
+	status := int32(http.StatusInternalServerError)
+	hook, hits := setupWebhook(t, WebhookEventChannelDisabled, &status)
+	delivery := queueWebhookDelivery(t, hook, 0)
+
+	// the failed attempts are retried with backoff
+	attemptWebhookDelivery(hook, delivery)
+	delivery = getWebhookDelivery(t, delivery.Id)
+	assert.Equal(t, WebhookDeliveryStatusPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+	assert.NotEmpty(t, delivery.Error)
+	assert.InDelta(t, time.Now().Add(webhook.Backoff(1)).Unix(), delivery.NextAttemptTime, 2)
+
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	attemptWebhookDelivery(hook, delivery)
+	delivery = getWebhookDelivery(t, delivery.Id)
+	assert.Equal(t, WebhookDeliveryStatusSuccess, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Empty(t, delivery.Error)
+	assert.NotZero(t, delivery.DeliveredTime)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+
+	// the delivery is given up after the last attempt
+	atomic.StoreInt32(&status, http.StatusBadGateway)
+	delivery = queueWebhookDelivery(t, hook, 0)
+	delivery.Attempts = webhook.MaxAttempts - 1
+	attemptWebhookDelivery(hook, delivery)
+	delivery = getWebhookDelivery(t, delivery.Id)
+	assert.Equal(t, WebhookDeliveryStatusFailed, delivery.Status)
+	assert.Equal(t, webhook.MaxAttempts, delivery.Attempts)
+}
+
+func TestRetryWebhookDeliveries(t *testing.T) {
+	setupTestDB(t)
+	status := int32(http.StatusInternalServerError)
+	hook, hits := setupWebhook(t, WebhookEventChannelDisabled, &status)
+	now := helper.GetTimestamp()
+	due := queueWebhookDelivery(t, hook, now-1)
+	notDue := queueWebhookDelivery(t, hook, now+3600)
+
+	count, err := RetryWebhookDeliveries()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	due = getWebhookDelivery(t, due.Id)
+	assert.Equal(t, 1, due.Attempts)
+	assert.Greater(t, due.NextAttemptTime, now)
+	assert.Zero(t, getWebhookDelivery(t, notDue.Id).Attempts)
+	// the failed delivery waits for its backoff
+	count, err = RetryWebhookDeliveries()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	// the deliveries of a disabled endpoint are given up
+	hook.Status = WebhookStatusDisabled
+	require.NoError(t, hook.Update())
+	require.NoError(t, DB.Model(due).Update("next_attempt_time", now-1).Error)
+	count, err = RetryWebhookDeliveries()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	assert.Equal(t, WebhookDeliveryStatusFailed, getWebhookDelivery(t, due.Id).Status)
+}
+
+func TestRetryWebhookDeliveriesConcurrently(t *testing.T) {
+	setupTestDB(t)
+	status := int32(http.StatusOK)
+	hook, hits := setupWebhook(t, WebhookEventChannelDisabled, &status)
+	delivery := queueWebhookDelivery(t, hook, helper.GetTimestamp()-1)
+
+	var wg sync.WaitGroup
+	var attempted int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, _ := RetryWebhookDeliveries()
+			atomic.AddInt32(&attempted, int32(count))
+		}()
+	}
+	wg.Wait()
+	// the lease lets only one of the nodes attempt the delivery
+	assert.LessOrEqual(t, atomic.LoadInt32(hits), int32(1))
+	assert.Equal(t, atomic.LoadInt32(hits), atomic.LoadInt32(&attempted))
+	if atomic.LoadInt32(hits) == 1 {
+		assert.Equal(t, 1, getWebhookDelivery(t, delivery.Id).Attempts)
+	}
+}
+
+func TestQuotaExhaustedEvent(t *testing.T) {
+	setupTestDB(t)
+	status := int32(http.StatusOK)
+	setupWebhook(t, WebhookEventUserQuotaExhausted, &status)
+	countEvents := func() int64 {
+		var count int64
+		DB.Model(&WebhookDelivery{}).Where("event = ?", WebhookEventUserQuotaExhausted).Count(&count)
+		return count
+	}
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "member", Quota: 100, Group: "default", Status: UserStatusEnabled}).Error)
+	require.NoError(t, DB.Create(&Token{Id: 1, UserId: 1, Key: "user-token", Name: "t", Status: TokenStatusEnabled, UnlimitedQuota: true}).Error)
+
+	// the quota running out when the request is settled
+	require.NoError(t, PreConsumeTokenQuota(1, 50))
+	require.NoError(t, PostConsumeTokenQuota(1, 20))
+	assert.Zero(t, countEvents())
+	require.NoError(t, PostConsumeTokenQuota(1, 40))
+	assert.Eventually(t, func() bool { return countEvents() == 1 }, time.Second, 10*time.Millisecond)
+
+	// the quota of the organization running out
+	organization := &Organization{Name: "acme", Status: OrganizationStatusEnabled, Quota: 100}
+	require.NoError(t, CreateOrganization(organization, 1))
+	require.NoError(t, DB.Create(&Token{Id: 2, UserId: 1, Key: "organization-token", Name: "t", Status: TokenStatusEnabled, UnlimitedQuota: true, OrganizationId: organization.Id}).Error)
+	require.NoError(t, PreConsumeTokenQuota(2, 100))
+	assert.Eventually(t, func() bool { return countEvents() == 2 }, time.Second, 10*time.Millisecond)
+}
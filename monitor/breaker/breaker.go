@@ -0,0 +1,180 @@
+package breaker
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the breakers are kept per channel and per channel & model, a request is only let through when both of them allow it
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+type Breaker struct {
+	ChannelId int `json:"channel_id"`
+	// Model is empty for the breaker of the whole channel
+	Model               string `json:"model"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	ProbeSuccesses      int    `json:"probe_successes"`
+	// Trips is the times the breaker has opened without closing in between, which decides the cool-down
+	Trips     int   `json:"trips"`
+	OpenedAt  int64 `json:"opened_at"`
+	OpenUntil int64 `json:"open_until"`
+}
+
+type breakerKey struct {
+	channelId int
+	model     string
+}
+
+var (
+	breakers = make(map[breakerKey]*Breaker)
+	lock     sync.Mutex
+)
+
+// refresh turns an open breaker half-open once its cool-down is over
+func (b *Breaker) refresh(now time.Time) string {
+	if b.State == StateOpen && now.Unix() >= b.OpenUntil {
+		b.State = StateHalfOpen
+		b.ProbeSuccesses = 0
+		logger.SysLog(fmt.Sprintf("circuit breaker of channel #%d %s is half-open", b.ChannelId, b.Model))
+	}
+	return b.State
+}
+
+func (b *Breaker) open(now time.Time) {
+	b.Trips++
+	cooldown := config.CircuitBreakerCooldown
+	for i := 1; i < b.Trips && cooldown < config.CircuitBreakerMaxCooldown; i++ {
+		cooldown *= 2
+	}
+	if cooldown > config.CircuitBreakerMaxCooldown {
+		cooldown = config.CircuitBreakerMaxCooldown
+	}
+	b.State = StateOpen
+	b.ProbeSuccesses = 0
+	b.OpenedAt = now.Unix()
+	b.OpenUntil = now.Unix() + int64(cooldown)
+	logger.SysLog(fmt.Sprintf("circuit breaker of channel #%d %s is open for %d seconds", b.ChannelId, b.Model, cooldown))
+}
+
+func allow(key breakerKey, now time.Time) bool {
+	b, ok := breakers[key]
+	if !ok {
+		return true
+	}
+	switch b.refresh(now) {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		// only a trickle of requests probes the channel
+		return rand.Float64() < config.CircuitBreakerProbeRatio
+	default:
+		return true
+	}
+}
+
+// Allow reports whether the channel should serve a request of the model
+func Allow(channelId int, model string) bool {
+	if !config.CircuitBreakerEnabled {
+		return true
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	return allow(breakerKey{channelId: channelId}, now) && allow(breakerKey{channelId: channelId, model: model}, now)
+}
+
+func record(key breakerKey, success bool, now time.Time) {
+	b, ok := breakers[key]
+	if !ok {
+		if success {
+			return
+		}
+		b = &Breaker{
+			ChannelId: key.channelId,
+			Model:     key.model,
+			State:     StateClosed,
+		}
+		breakers[key] = b
+	}
+	state := b.refresh(now)
+	if success {
+		switch state {
+		case StateClosed:
+			// healthy breakers are forgotten
+			delete(breakers, key)
+		case StateHalfOpen:
+			b.ProbeSuccesses++
+			if b.ProbeSuccesses >= config.CircuitBreakerProbeSuccesses {
+				delete(breakers, key)
+				logger.SysLog(fmt.Sprintf("circuit breaker of channel #%d %s is closed", b.ChannelId, b.Model))
+			}
+		}
+		return
+	}
+	switch state {
+	case StateClosed:
+		b.ConsecutiveFailures++
+		if b.ConsecutiveFailures >= config.CircuitBreakerFailureThreshold {
+			b.open(now)
+		}
+	case StateHalfOpen:
+		b.ConsecutiveFailures++
+		b.open(now)
+	}
+}
+
+// Record feeds the outcome of a request to the breakers of the channel
+func Record(channelId int, model string, success bool) {
+	if !config.CircuitBreakerEnabled {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	record(breakerKey{channelId: channelId}, success, now)
+	if model != "" {
+		record(breakerKey{channelId: channelId, model: model}, success, now)
+	}
+}
+
+// GetAll returns the breakers which are not healthy
+func GetAll() []Breaker {
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	result := make([]Breaker, 0, len(breakers))
+	for _, b := range breakers {
+		b.refresh(now)
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// Reset closes all the breakers of the channel
+func Reset(channelId int) {
+	lock.Lock()
+	defer lock.Unlock()
+	for key := range breakers {
+		if key.channelId == channelId {
+			delete(breakers, key)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package breaker_test
+
+import (
+	"testing"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/monitor/breaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	enabled, threshold, cooldown := config.CircuitBreakerEnabled, config.CircuitBreakerFailureThreshold, config.CircuitBreakerCooldown
+	probeRatio, probeSuccesses := config.CircuitBreakerProbeRatio, config.CircuitBreakerProbeSuccesses
+	t.Cleanup(func() {
+		config.CircuitBreakerEnabled, config.CircuitBreakerFailureThreshold, config.CircuitBreakerCooldown = enabled, threshold, cooldown
+		config.CircuitBreakerProbeRatio, config.CircuitBreakerProbeSuccesses = probeRatio, probeSuccesses
+		breaker.Reset(1)
+	})
+	config.CircuitBreakerEnabled = true
+	config.CircuitBreakerFailureThreshold = 2
+	config.CircuitBreakerCooldown = 0
+	config.CircuitBreakerProbeRatio = 1
+	config.CircuitBreakerProbeSuccesses = 1
+
+	breaker.Record(1, "gpt-4o", false)
+	assert.True(t, breaker.Allow(1, "gpt-4o"))
+	breaker.Record(1, "gpt-4o", false)
+	breakers := breaker.GetAll()
+	assert.Len(t, breakers, 2)
+	// the cool-down is over at once, so the breakers are half-open
+	assert.Equal(t, breaker.StateHalfOpen, breakers[0].State)
+	assert.Equal(t, 1, breakers[0].Trips)
+	assert.True(t, breaker.Allow(1, "gpt-4o"))
+
+	// a failed probe opens the breaker again
+	breaker.Record(1, "gpt-4o", false)
+	assert.Equal(t, 2, breaker.GetAll()[0].Trips)
+
+	breaker.Record(1, "gpt-4o", true)
+	assert.Empty(t, breaker.GetAll())
+}
@@ -26,22 +26,40 @@ func notifyRootUser(subject string, content string) {
 	}
 }
 
-// DisableChannel disable & notify
+// channelEvent is the data of the webhook events of the channels
+type channelEvent struct {
+	ChannelId   int      `json:"channel_id"`
+	ChannelName string   `json:"channel_name,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	Balance     *float64 `json:"balance,omitempty"`
+	Threshold   float64  `json:"threshold,omitempty"`
+}
+
+// DisableChannel disable & notify, the balance state is reset so that the channel is not enabled again
+// as its balance recovers, the caller disabling it for the balance sets the state afterwards
 func DisableChannel(channelId int, channelName string, reason string) {
 	model.UpdateChannelStatusById(channelId, model.ChannelStatusAutoDisabled)
+	model.UpdateChannelBalanceState(channelId, model.ChannelBalanceStateNormal)
 	logger.SysLog(fmt.Sprintf("channel #%d has been disabled: %s", channelId, reason))
 	subject := fmt.Sprintf("渠道「%s」（#%d）已被禁用", channelName, channelId)
 	content := fmt.Sprintf("渠道「%s」（#%d）已被禁用，原因：%s", channelName, channelId, reason)
 	notifyRootUser(subject, content)
+	model.EmitWebhookEvent(model.WebhookEventChannelDisabled, channelEvent{ChannelId: channelId, ChannelName: channelName, Reason: reason})
 }
 
 func MetricDisableChannel(channelId int, successRate float64) {
 	model.UpdateChannelStatusById(channelId, model.ChannelStatusAutoDisabled)
+	model.UpdateChannelBalanceState(channelId, model.ChannelBalanceStateNormal)
 	logger.SysLog(fmt.Sprintf("channel #%d has been disabled due to low success rate: %.2f", channelId, successRate*100))
-	subject := fmt.Sprintf("渠道 #%d 已被禁用", channelId)
+	channelName := ""
+	if channel, err := model.GetChannelById(channelId, false); err == nil {
+		channelName = channel.Name
+	}
+	subject := fmt.Sprintf("渠道「%s」（#%d）已被禁用", channelName, channelId)
 	content := fmt.Sprintf("该渠道（#%d）在最近 %d 次调用中成功率为 %.2f%%，低于阈值 %.2f%%，因此被系统自动禁用。",
 		channelId, config.MetricQueueSize, successRate*100, config.MetricSuccessRateThreshold*100)
 	notifyRootUser(subject, content)
+	model.EmitWebhookEvent(model.WebhookEventChannelDisabled, channelEvent{ChannelId: channelId, ChannelName: channelName, Reason: content})
 }
 
 // EnableChannel enable & notify
@@ -51,4 +69,14 @@ func EnableChannel(channelId int, channelName string) {
 	subject := fmt.Sprintf("渠道「%s」（#%d）已被启用", channelName, channelId)
 	content := fmt.Sprintf("渠道「%s」（#%d）已被启用", channelName, channelId)
 	notifyRootUser(subject, content)
+	model.EmitWebhookEvent(model.WebhookEventChannelEnabled, channelEvent{ChannelId: channelId, ChannelName: channelName})
+}
+
+// ChannelBalanceLow notifies the balance of the channel falls below the threshold
+func ChannelBalanceLow(channelId int, channelName string, balance float64, threshold float64) {
+	logger.SysLog(fmt.Sprintf("channel #%d balance %.2f is below the threshold %.2f", channelId, balance, threshold))
+	subject := fmt.Sprintf("渠道「%s」（#%d）余额不足", channelName, channelId)
+	content := fmt.Sprintf("渠道「%s」（#%d）的余额为 %.2f，低于提醒阈值 %.2f，请及时充值。", channelName, channelId, balance, threshold)
+	notifyRootUser(subject, content)
+	model.EmitWebhookEvent(model.WebhookEventChannelBalanceLow, channelEvent{ChannelId: channelId, ChannelName: channelName, Balance: &balance, Threshold: threshold})
 }
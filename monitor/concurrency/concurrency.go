@@ -0,0 +1,131 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+// the requests in flight are counted per channel, once a channel reaches its max concurrency
+// the following requests wait in a bounded queue and are let through in order as slots are released.
+// the counters are kept in the memory of each node, so with several nodes behind a load balancer
+// every node allows up to the max concurrency on its own
+
+var (
+	ErrQueueFull    = errors.New("channel is saturated and its queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting for the channel")
+)
+
+type limiter struct {
+	inFlight int
+	// waiters are handed the slots in order, the slot is already counted when the channel is closed
+	waiters []chan struct{}
+}
+
+var (
+	limiters = make(map[int]*limiter)
+	lock     sync.Mutex
+)
+
+func getLimiter(channelId int) *limiter {
+	l, ok := limiters[channelId]
+	if !ok {
+		l = &limiter{}
+		limiters[channelId] = l
+	}
+	return l
+}
+
+func removeWaiter(l *limiter, waiter chan struct{}) bool {
+	for i, w := range l.waiters {
+		if w == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire takes a slot of the channel, a max concurrency of 0 means unlimited.
+// the returned function must be called to release the slot
+func Acquire(ctx context.Context, channelId int, maxConcurrency int) (func(), error) {
+	lock.Lock()
+	l := getLimiter(channelId)
+	release := func() {
+		releaseSlot(channelId, maxConcurrency)
+	}
+	if maxConcurrency <= 0 || l.inFlight < maxConcurrency {
+		l.inFlight++
+		lock.Unlock()
+		return release, nil
+	}
+	if len(l.waiters) >= config.ChannelQueueSize {
+		lock.Unlock()
+		return nil, ErrQueueFull
+	}
+	waiter := make(chan struct{})
+	l.waiters = append(l.waiters, waiter)
+	lock.Unlock()
+
+	timer := time.NewTimer(time.Duration(config.ChannelQueueTimeout) * time.Second)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-waiter:
+		return release, nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	lock.Lock()
+	removed := removeWaiter(l, waiter)
+	lock.Unlock()
+	if !removed {
+		// the slot is handed over right before giving up
+		release()
+	}
+	return nil, err
+}
+
+func releaseSlot(channelId int, maxConcurrency int) {
+	lock.Lock()
+	defer lock.Unlock()
+	l := getLimiter(channelId)
+	if len(l.waiters) > 0 && (maxConcurrency <= 0 || l.inFlight <= maxConcurrency) {
+		// hand the slot over to the first waiter
+		waiter := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		close(waiter)
+		return
+	}
+	l.inFlight--
+	if l.inFlight <= 0 && len(l.waiters) == 0 {
+		delete(limiters, channelId)
+	}
+}
+
+// IsSaturated reports whether a new request to the channel has to wait
+func IsSaturated(channelId int, maxConcurrency int) bool {
+	if maxConcurrency <= 0 {
+		return false
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	l, ok := limiters[channelId]
+	return ok && l.inFlight >= maxConcurrency
+}
+
+// InFlight returns the requests in flight and the requests waiting of the channel
+func InFlight(channelId int) (inFlight int, waiting int) {
+	lock.Lock()
+	defer lock.Unlock()
+	l, ok := limiters[channelId]
+	if !ok {
+		return 0, 0
+	}
+	return l.inFlight, len(l.waiters)
+}
@@ -0,0 +1,54 @@
+package concurrency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/monitor/concurrency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	queueSize := config.ChannelQueueSize
+	config.ChannelQueueSize = 1
+	t.Cleanup(func() { config.ChannelQueueSize = queueSize })
+	ctx := context.Background()
+	release, err := concurrency.Acquire(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.True(t, concurrency.IsSaturated(1, 1))
+
+	acquired := make(chan func())
+	go func() {
+		release, err := concurrency.Acquire(ctx, 1, 1)
+		assert.NoError(t, err)
+		acquired <- release
+	}()
+	assert.Eventually(t, func() bool {
+		_, waiting := concurrency.InFlight(1)
+		return waiting == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// the queue is full
+	_, err = concurrency.Acquire(ctx, 1, 1)
+	assert.ErrorIs(t, err, concurrency.ErrQueueFull)
+
+	// the slot is handed over to the waiting request
+	release()
+	secondRelease := <-acquired
+	inFlight, waiting := concurrency.InFlight(1)
+	assert.Equal(t, 1, inFlight)
+	assert.Equal(t, 0, waiting)
+
+	// the waiting request gives up when its context is done
+	cancelCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = concurrency.Acquire(cancelCtx, 1, 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	secondRelease()
+	inFlight, _ = concurrency.InFlight(1)
+	assert.Equal(t, 0, inFlight)
+	assert.False(t, concurrency.IsSaturated(1, 1))
+}
@@ -66,7 +66,8 @@ func init() {
 }
 
 func Emit(channelId int, success bool) {
-	if !config.EnableMetric {
+	// channels heal by themselves with the circuit breaker, there is no need to disable them
+	if !config.EnableMetric || config.CircuitBreakerEnabled {
 		return
 	}
 	go func() {
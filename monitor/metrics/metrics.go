@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// the metrics are exposed in the prometheus text format, it is written here as
+// prometheus/client_golang is not among the dependencies of the module
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds of the histogram buckets in seconds
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// only for histograms
+	bucketCounts []uint64
+	count        uint64
+}
+
+type vec struct {
+	name    string
+	help    string
+	type_   string
+	labels  []string
+	buckets []float64
+	lock    sync.Mutex
+	series  map[string]*series
+}
+
+var (
+	registry     []*vec
+	registryLock sync.Mutex
+)
+
+func newVec(name string, help string, type_ string, buckets []float64, labels []string) *vec {
+	v := &vec{
+		name:    name,
+		help:    help,
+		type_:   type_,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	registryLock.Lock()
+	registry = append(registry, v)
+	registryLock.Unlock()
+	return v
+}
+
+// get returns the series of the label values, the lock must be held
+func (v *vec) get(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if v.type_ == typeHistogram {
+			s.bucketCounts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+type CounterVec struct{ *vec }
+
+func NewCounterVec(name string, help string, labels ...string) CounterVec {
+	return CounterVec{newVec(name, help, typeCounter, nil, labels)}
+}
+
+func (c CounterVec) Add(value float64, labelValues ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.get(labelValues).value += value
+}
+
+func (c CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+type GaugeVec struct{ *vec }
+
+func NewGaugeVec(name string, help string, labels ...string) GaugeVec {
+	return GaugeVec{newVec(name, help, typeGauge, nil, labels)}
+}
+
+func (g GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.get(labelValues).value = value
+}
+
+// Replace swaps all the series for the ones set by update at once, so that the gauges of
+// removed objects are not exposed any more and a scrape never sees a partial update
+func (g GaugeVec) Replace(update func(set func(value float64, labelValues ...string))) {
+	next := &vec{name: g.name, labels: g.labels, series: make(map[string]*series)}
+	update(func(value float64, labelValues ...string) {
+		next.get(labelValues).value = value
+	})
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.series = next.series
+}
+
+type HistogramVec struct{ *vec }
+
+func NewHistogramVec(name string, help string, buckets []float64, labels ...string) HistogramVec {
+	return HistogramVec{newVec(name, help, typeHistogram, buckets, labels)}
+}
+
+func (h HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.get(labelValues)
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (v *vec) write(w io.Writer) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.type_)
+	for _, key := range keys {
+		s := v.series[key]
+		if v.type_ != typeHistogram {
+			fmt.Fprintf(&b, "%s%s %s\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), formatFloat(s.value))
+			continue
+		}
+		for i, upperBound := range v.buckets {
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, s.labelValues, "le", formatFloat(upperBound)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, formatLabels(v.labels, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum%s %s\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), formatFloat(s.value))
+		fmt.Fprintf(&b, "%s_count%s %d\n", v.name, formatLabels(v.labels, s.labelValues, "", ""), s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write exposes all the metrics in the prometheus text format
+func Write(w io.Writer) error {
+	registryLock.Lock()
+	vecs := append([]*vec(nil), registry...)
+	registryLock.Unlock()
+	for _, v := range vecs {
+		if err := v.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	counter := metrics.NewCounterVec("test_requests_total", "Test requests.", "model")
+	counter.Inc(`gpt-"4o"`)
+	counter.Add(2, `gpt-"4o"`)
+	histogram := metrics.NewHistogramVec("test_duration_seconds", "Test duration.", []float64{1, 5}, "model")
+	histogram.Observe(0.5, "gpt-4o")
+	histogram.Observe(3, "gpt-4o")
+
+	var b strings.Builder
+	assert.NoError(t, metrics.Write(&b))
+	output := b.String()
+	assert.Contains(t, output, "# TYPE test_requests_total counter\n")
+	assert.Contains(t, output, `test_requests_total{model="gpt-\"4o\""} 3`+"\n")
+	assert.Contains(t, output, `test_duration_seconds_bucket{model="gpt-4o",le="1"} 1`+"\n")
+	assert.Contains(t, output, `test_duration_seconds_bucket{model="gpt-4o",le="5"} 2`+"\n")
+	assert.Contains(t, output, `test_duration_seconds_bucket{model="gpt-4o",le="+Inf"} 2`+"\n")
+	assert.Contains(t, output, `test_duration_seconds_sum{model="gpt-4o"} 3.5`+"\n")
+	assert.Contains(t, output, `test_duration_seconds_count{model="gpt-4o"} 2`+"\n")
+}
+
+func TestGaugeReplace(t *testing.T) {
+	gauge := metrics.NewGaugeVec("test_channel_status", "Test status.", "channel")
+	gauge.Set(1, "1")
+	gauge.Replace(func(set func(value float64, labelValues ...string)) {
+		set(3, "2")
+	})
+
+	var b strings.Builder
+	assert.NoError(t, metrics.Write(&b))
+	output := b.String()
+	assert.NotContains(t, output, `test_channel_status{channel="1"}`)
+	assert.Contains(t, output, `test_channel_status{channel="2"} 3`+"\n")
+}
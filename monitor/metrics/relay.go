@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/songquanpeng/one-api/common/config"
+)
+
+var (
+	requests = NewCounterVec("one_api_relay_requests_total",
+		"Relay requests by channel, model, group and status code.", "channel", "model", "group", "code")
+	requestDuration = NewHistogramVec("one_api_relay_request_duration_seconds",
+		"Duration of relay requests in seconds.", DefaultBuckets, "channel", "model", "group", "code")
+	firstTokenLatency = NewHistogramVec("one_api_relay_first_token_seconds",
+		"Time to the first chunk of stream responses in seconds.", DefaultBuckets, "channel", "model", "group")
+	tokens = NewCounterVec("one_api_relay_tokens_total",
+		"Tokens consumed by relay requests, the type is prompt or completion.", "channel", "model", "group", "type")
+	quota = NewCounterVec("one_api_relay_quota_total",
+		"Quota consumed by relay requests.", "channel", "model", "group")
+
+	ChannelStatus = NewGaugeVec("one_api_channel_status",
+		"Status of the channels, 1 is enabled, 2 is manually disabled and 3 is automatically disabled.", "channel", "name")
+	ChannelBalance = NewGaugeVec("one_api_channel_balance",
+		"Balance of the channels in USD.", "channel", "name")
+	BatchUpdateQueueDepth = NewGaugeVec("one_api_batch_update_queue_depth",
+		"Records waiting for the next batch update.", "type")
+)
+
+func RecordRequest(channelId int, model string, group string, statusCode int, duration time.Duration) {
+	if !config.MetricsEnabled {
+		return
+	}
+	channel := strconv.Itoa(channelId)
+	code := strconv.Itoa(statusCode)
+	requests.Inc(channel, model, group, code)
+	requestDuration.Observe(duration.Seconds(), channel, model, group, code)
+}
+
+func RecordFirstToken(channelId int, model string, group string, latency time.Duration) {
+	if !config.MetricsEnabled {
+		return
+	}
+	firstTokenLatency.Observe(latency.Seconds(), strconv.Itoa(channelId), model, group)
+}
+
+func RecordConsumption(channelId int, model string, group string, promptTokens int, completionTokens int, consumedQuota int64) {
+	if !config.MetricsEnabled {
+		return
+	}
+	channel := strconv.Itoa(channelId)
+	tokens.Add(float64(promptTokens), channel, model, group, "prompt")
+	tokens.Add(float64(completionTokens), channel, model, group, "completion")
+	quota.Add(float64(consumedQuota), channel, model, group)
+}
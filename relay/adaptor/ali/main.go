@@ -80,6 +80,20 @@ func ConvertImageRequest(request model.ImageRequest) *ImageRequest {
 	return &imageRequest
 }
 
+func ConvertImageEditRequest(request model.ImageRequest) *ImageEditRequest {
+	var imageRequest ImageEditRequest
+	imageRequest.Model = request.Model
+	imageRequest.Input.Function = "description_edit"
+	if request.Mask != "" {
+		imageRequest.Input.Function = "description_edit_with_mask"
+	}
+	imageRequest.Input.Prompt = request.Prompt
+	imageRequest.Input.BaseImageUrl = request.Image
+	imageRequest.Input.MaskImageUrl = request.Mask
+	imageRequest.Parameters.N = request.N
+	return &imageRequest
+}
+
 func EmbeddingHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, *model.Usage) {
 	var aliResponse EmbeddingResponse
 	err := json.NewDecoder(resp.Body).Decode(&aliResponse)
@@ -22,7 +22,7 @@ type Parameters struct {
 	EnableSearch      bool         `json:"enable_search,omitempty"`
 	IncrementalOutput bool         `json:"incremental_output,omitempty"`
 	MaxTokens         int          `json:"max_tokens,omitempty"`
-	Temperature       float64      `json:"temperature,omitempty"`
+	Temperature       *float64     `json:"temperature,omitempty"`
 	ResultFormat      string       `json:"result_format,omitempty"`
 	Tools             []model.Tool `json:"tools,omitempty"`
 }
@@ -48,6 +48,20 @@ type ImageRequest struct {
 	ResponseFormat string `json:"response_format,omitempty"`
 }
 
+// https://help.aliyun.com/zh/model-studio/wanx-image-edit-api-reference
+type ImageEditRequest struct {
+	Model string `json:"model"`
+	Input struct {
+		Function     string `json:"function"`
+		Prompt       string `json:"prompt"`
+		BaseImageUrl string `json:"base_image_url"`
+		MaskImageUrl string `json:"mask_image_url,omitempty"`
+	} `json:"input"`
+	Parameters struct {
+		N int `json:"n,omitempty"`
+	} `json:"parameters,omitempty"`
+}
+
 type TaskResponse struct {
 	StatusCode int    `json:"status_code,omitempty"`
 	RequestId  string `json:"request_id,omitempty"`
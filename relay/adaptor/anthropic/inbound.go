@@ -0,0 +1,449 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// The messages api can also be used by clients to call one-api directly,
+// requests are converted to the openai format so that any channel can serve them,
+// and the openai format responses are converted back.
+// https://docs.anthropic.com/en/api/messages
+
+type InboundContent struct {
+	Type      string       `json:"type"`
+	Text      string       `json:"text,omitempty"`
+	Source    *ImageSource `json:"source,omitempty"`
+	Id        string       `json:"id,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	Input     any          `json:"input,omitempty"`
+	ToolUseId string       `json:"tool_use_id,omitempty"`
+	Content   any          `json:"content,omitempty"` // string or a list of blocks
+}
+
+type InboundMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string or a list of blocks
+}
+
+type InboundTool struct {
+	Type        string         `json:"type,omitempty"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema,omitempty"`
+}
+
+type InboundRequest struct {
+	Model         string           `json:"model"`
+	Messages      []InboundMessage `json:"messages"`
+	System        any              `json:"system,omitempty"` // string or a list of blocks
+	MaxTokens     int              `json:"max_tokens,omitempty"`
+	StopSequences []string         `json:"stop_sequences,omitempty"`
+	Stream        bool             `json:"stream,omitempty"`
+	Temperature   *float64         `json:"temperature,omitempty"`
+	TopP          float64          `json:"top_p,omitempty"`
+	TopK          int              `json:"top_k,omitempty"`
+	Tools         []InboundTool    `json:"tools,omitempty"`
+	ToolChoice    map[string]any   `json:"tool_choice,omitempty"`
+	Metadata      *Metadata        `json:"metadata,omitempty"`
+}
+
+type InboundResponse struct {
+	Id           string    `json:"id"`
+	Type         string    `json:"type"`
+	Role         string    `json:"role"`
+	Model        string    `json:"model"`
+	Content      []Content `json:"content"`
+	StopReason   *string   `json:"stop_reason"`
+	StopSequence *string   `json:"stop_sequence"`
+	Usage        Usage     `json:"usage"`
+}
+
+func parseInboundContent(content any) []InboundContent {
+	if text, ok := content.(string); ok {
+		return []InboundContent{{Type: "text", Text: text}}
+	}
+	var contents []InboundContent
+	jsonContent, _ := json.Marshal(content)
+	_ = json.Unmarshal(jsonContent, &contents)
+	return contents
+}
+
+func inboundContentText(content any) string {
+	var texts []string
+	for _, part := range parseInboundContent(content) {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func inboundImageURL(source *ImageSource) string {
+	if source == nil {
+		return ""
+	}
+	if source.Type == "url" {
+		return source.Url
+	}
+	return fmt.Sprintf("data:%s;base64,%s", source.MediaType, source.Data)
+}
+
+func ConvertInboundRequest(request *InboundRequest) *model.GeneralOpenAIRequest {
+	textRequest := model.GeneralOpenAIRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Stream:      request.Stream,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		TopK:        request.TopK,
+	}
+	if len(request.StopSequences) > 0 {
+		textRequest.Stop = request.StopSequences
+	}
+	if request.Metadata != nil {
+		textRequest.User = request.Metadata.UserId
+	}
+	if system := inboundContentText(request.System); system != "" {
+		textRequest.Messages = append(textRequest.Messages, model.Message{
+			Role:    "system",
+			Content: system,
+		})
+	}
+	for _, message := range request.Messages {
+		textRequest.Messages = append(textRequest.Messages, convertInboundMessage(message)...)
+	}
+	for _, tool := range request.Tools {
+		if tool.InputSchema == nil {
+			continue // server tools can't be served by other channels
+		}
+		textRequest.Tools = append(textRequest.Tools, model.Tool{
+			Type: "function",
+			Function: model.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	if request.ToolChoice != nil && len(textRequest.Tools) > 0 {
+		switch request.ToolChoice["type"] {
+		case "auto":
+			textRequest.ToolChoice = "auto"
+		case "any":
+			textRequest.ToolChoice = "required"
+		case "none":
+			textRequest.ToolChoice = "none"
+		case "tool":
+			textRequest.ToolChoice = map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name": request.ToolChoice["name"],
+				},
+			}
+		}
+	}
+	return &textRequest
+}
+
+func convertInboundMessage(message InboundMessage) []model.Message {
+	var messages []model.Message
+	var contents []any
+	var texts []string
+	var toolCalls []model.Tool
+	for _, part := range parseInboundContent(message.Content) {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+			contents = append(contents, map[string]any{
+				"type": model.ContentTypeText,
+				"text": part.Text,
+			})
+		case "image":
+			contents = append(contents, map[string]any{
+				"type": model.ContentTypeImageURL,
+				"image_url": map[string]any{
+					"url": inboundImageURL(part.Source),
+				},
+			})
+		case "tool_use":
+			arguments, _ := json.Marshal(part.Input)
+			toolCalls = append(toolCalls, model.Tool{
+				Id:   part.Id,
+				Type: "function",
+				Function: model.Function{
+					Name:      part.Name,
+					Arguments: string(arguments),
+				},
+			})
+		case "tool_result":
+			// tool results must directly follow the assistant message which called the tools
+			messages = append(messages, model.Message{
+				Role:       "tool",
+				Content:    inboundContentText(part.Content),
+				ToolCallId: part.ToolUseId,
+			})
+		}
+	}
+	if message.Role == "assistant" {
+		if len(texts) == 0 && len(toolCalls) == 0 {
+			return messages
+		}
+		return append(messages, model.Message{
+			Role:      "assistant",
+			Content:   strings.Join(texts, "\n"),
+			ToolCalls: toolCalls,
+		})
+	}
+	if len(contents) == 0 {
+		return messages
+	}
+	return append(messages, model.Message{
+		Role:    message.Role,
+		Content: contents,
+	})
+}
+
+func stopReasonOpenAI2Claude(reason string) string {
+	switch reason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+func toolInput(arguments any) any {
+	input := make(map[string]any)
+	_ = json.Unmarshal([]byte(conv.AsString(arguments)), &input)
+	return input
+}
+
+// UsageOpenAI2Claude converts the usage to claude, whose input tokens exclude the tokens read from and written to the cache
+func UsageOpenAI2Claude(usage *model.Usage) Usage {
+	cachedTokens := usage.CachedTokens()
+	cacheCreationTokens := usage.CacheCreationTokens()
+	return Usage{
+		InputTokens:              usage.PromptTokens - cachedTokens - cacheCreationTokens,
+		OutputTokens:             usage.CompletionTokens,
+		CacheReadInputTokens:     cachedTokens,
+		CacheCreationInputTokens: cacheCreationTokens,
+	}
+}
+
+func ResponseOpenAI2Claude(response *openai.TextResponse) *InboundResponse {
+	claudeResponse := InboundResponse{
+		Id:      "msg_" + random.GetUUID(),
+		Type:    "message",
+		Role:    "assistant",
+		Model:   response.Model,
+		Content: make([]Content, 0),
+		Usage:   UsageOpenAI2Claude(&response.Usage),
+	}
+	stopReason := "end_turn"
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		if text := choice.Message.StringContent(); text != "" {
+			claudeResponse.Content = append(claudeResponse.Content, Content{
+				Type: "text",
+				Text: text,
+			})
+		}
+		for _, tool := range choice.Message.ToolCalls {
+			claudeResponse.Content = append(claudeResponse.Content, Content{
+				Type:  "tool_use",
+				Id:    tool.Id,
+				Name:  tool.Function.Name,
+				Input: toolInput(tool.Function.Arguments),
+			})
+		}
+		stopReason = stopReasonOpenAI2Claude(choice.FinishReason)
+	}
+	claudeResponse.StopReason = &stopReason
+	return &claudeResponse
+}
+
+func errorTypeByStatusCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		return "api_error"
+	}
+}
+
+// ErrorOpenAI2Claude converts an openai format error response body
+func ErrorOpenAI2Claude(statusCode int, body []byte) []byte {
+	var errorResponse struct {
+		Error model.Error `json:"error"`
+	}
+	_ = json.Unmarshal(body, &errorResponse)
+	message := errorResponse.Error.Message
+	if message == "" {
+		message = string(body)
+	}
+	jsonResponse, _ := json.Marshal(map[string]any{
+		"type": "error",
+		"error": Error{
+			Type:    errorTypeByStatusCode(statusCode),
+			Message: message,
+		},
+	})
+	return jsonResponse
+}
+
+// StreamConverter converts openai format stream chunks to messages api stream events
+type StreamConverter struct {
+	modelName    string
+	promptTokens int
+	started      bool
+	blockIndex   int
+	blockType    string
+	stopReason   string
+	responseText string
+	usage        *model.Usage
+}
+
+func NewStreamConverter(modelName string, promptTokens int) *StreamConverter {
+	return &StreamConverter{
+		modelName:    modelName,
+		promptTokens: promptTokens,
+		blockIndex:   -1,
+	}
+}
+
+func streamEvent(eventType string, data map[string]any) string {
+	data["type"] = eventType
+	jsonData, _ := json.Marshal(data)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, jsonData)
+}
+
+func (s *StreamConverter) start(id string, modelName string) []string {
+	s.started = true
+	if modelName == "" {
+		modelName = s.modelName
+	}
+	return []string{streamEvent("message_start", map[string]any{
+		"message": map[string]any{
+			"id":            "msg_" + strings.TrimPrefix(id, "chatcmpl-"),
+			"type":          "message",
+			"role":          "assistant",
+			"model":         modelName,
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage": Usage{
+				InputTokens: s.promptTokens,
+			},
+		},
+	})}
+}
+
+func (s *StreamConverter) stopBlock() []string {
+	if s.blockType == "" {
+		return nil
+	}
+	s.blockType = ""
+	return []string{streamEvent("content_block_stop", map[string]any{
+		"index": s.blockIndex,
+	})}
+}
+
+func (s *StreamConverter) startBlock(blockType string, block map[string]any) []string {
+	events := s.stopBlock()
+	s.blockIndex++
+	s.blockType = blockType
+	block["type"] = blockType
+	return append(events, streamEvent("content_block_start", map[string]any{
+		"index":         s.blockIndex,
+		"content_block": block,
+	}))
+}
+
+// Convert converts the data of a single openai stream chunk
+func (s *StreamConverter) Convert(data string) []string {
+	var streamResponse openai.ChatCompletionsStreamResponse
+	if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+		return nil
+	}
+	var events []string
+	if !s.started {
+		events = append(events, s.start(streamResponse.Id, streamResponse.Model)...)
+	}
+	if streamResponse.Usage != nil {
+		s.usage = streamResponse.Usage
+	}
+	for _, choice := range streamResponse.Choices {
+		if text := conv.AsString(choice.Delta.Content); text != "" {
+			if s.blockType != "text" {
+				events = append(events, s.startBlock("text", map[string]any{"text": ""})...)
+			}
+			s.responseText += text
+			events = append(events, streamEvent("content_block_delta", map[string]any{
+				"index": s.blockIndex,
+				"delta": map[string]any{"type": "text_delta", "text": text},
+			}))
+		}
+		for _, tool := range choice.Delta.ToolCalls {
+			if tool.Id != "" {
+				events = append(events, s.startBlock("tool_use", map[string]any{
+					"id":    tool.Id,
+					"name":  tool.Function.Name,
+					"input": map[string]any{},
+				})...)
+			}
+			if arguments := conv.AsString(tool.Function.Arguments); arguments != "" && s.blockType == "tool_use" {
+				s.responseText += arguments
+				events = append(events, streamEvent("content_block_delta", map[string]any{
+					"index": s.blockIndex,
+					"delta": map[string]any{"type": "input_json_delta", "partial_json": arguments},
+				}))
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.stopReason = stopReasonOpenAI2Claude(*choice.FinishReason)
+		}
+	}
+	return events
+}
+
+// Finish closes the stream once the openai stream is done
+func (s *StreamConverter) Finish() []string {
+	var events []string
+	if !s.started {
+		events = append(events, s.start(random.GetUUID(), s.modelName)...)
+	}
+	events = append(events, s.stopBlock()...)
+	if s.stopReason == "" {
+		s.stopReason = "end_turn"
+	}
+	var usage Usage
+	if s.usage != nil {
+		usage = UsageOpenAI2Claude(s.usage)
+	} else {
+		usage.InputTokens = s.promptTokens
+		usage.OutputTokens = openai.CountTokenText(s.responseText, s.modelName)
+	}
+	events = append(events, streamEvent("message_delta", map[string]any{
+		"delta": map[string]any{"stop_reason": s.stopReason, "stop_sequence": nil},
+		"usage": usage,
+	}))
+	return append(events, streamEvent("message_stop", map[string]any{}))
+}
@@ -0,0 +1,96 @@
+package anthropic_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/songquanpeng/one-api/relay/adaptor/anthropic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertInboundRequest(t *testing.T) {
+	var request anthropic.InboundRequest
+	err := json.Unmarshal([]byte(`{
+		"model": "claude-3-5-sonnet-20241022",
+		"max_tokens": 1024,
+		"system": [{"type": "text", "text": "You are a weather bot."}],
+		"tools": [{"name": "get_weather", "input_schema": {"type": "object", "properties": {"city": {"type": "string"}}}}],
+		"tool_choice": {"type": "any"},
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}]},
+			{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": "Sunny"}, {"type": "text", "text": "Thanks"}]}
+		]
+	}`), &request)
+	assert.NoError(t, err)
+
+	textRequest := anthropic.ConvertInboundRequest(&request)
+	assert.Equal(t, 1024, textRequest.MaxTokens)
+	assert.Equal(t, "required", textRequest.ToolChoice)
+	assert.Len(t, textRequest.Tools, 1)
+	assert.Equal(t, "get_weather", textRequest.Tools[0].Function.Name)
+
+	assert.Len(t, textRequest.Messages, 5)
+	assert.Equal(t, "system", textRequest.Messages[0].Role)
+	assert.Equal(t, "You are a weather bot.", textRequest.Messages[0].StringContent())
+	assert.Equal(t, "assistant", textRequest.Messages[2].Role)
+	assert.Equal(t, "toolu_1", textRequest.Messages[2].ToolCalls[0].Id)
+	assert.Equal(t, `{"city":"Paris"}`, textRequest.Messages[2].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool", textRequest.Messages[3].Role)
+	assert.Equal(t, "toolu_1", textRequest.Messages[3].ToolCallId)
+	assert.Equal(t, "Sunny", textRequest.Messages[3].Content)
+	assert.Equal(t, "user", textRequest.Messages[4].Role)
+	assert.Nil(t, textRequest.Temperature)
+}
+
+func TestConvertInboundRequestZeroTemperature(t *testing.T) {
+	var request anthropic.InboundRequest
+	err := json.Unmarshal([]byte(`{"model": "claude-3-5-sonnet-20241022", "max_tokens": 16, "temperature": 0, "messages": [{"role": "user", "content": "Hi"}]}`), &request)
+	assert.NoError(t, err)
+
+	textRequest := anthropic.ConvertInboundRequest(&request)
+	if assert.NotNil(t, textRequest.Temperature) {
+		assert.Equal(t, 0.0, *textRequest.Temperature)
+	}
+	body, err := json.Marshal(textRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"temperature":0`)
+}
+
+func TestStreamConverter(t *testing.T) {
+	converter := anthropic.NewStreamConverter("gpt-4o", 10)
+	var events []string
+	events = append(events, converter.Convert(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`)...)
+	events = append(events, converter.Convert(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`)...)
+	events = append(events, converter.Convert(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"function":{"arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`)...)
+	events = append(events, converter.Finish()...)
+
+	var eventTypes []string
+	for _, event := range events {
+		eventTypes = append(eventTypes, strings.TrimPrefix(strings.SplitN(event, "\n", 2)[0], "event: "))
+	}
+	assert.Equal(t, []string{
+		"message_start",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"message_delta", "message_stop",
+	}, eventTypes)
+	assert.Contains(t, events[7], `"stop_reason":"tool_use"`)
+	assert.Contains(t, events[7], `"output_tokens":5`)
+}
+
+func TestUsageConversion(t *testing.T) {
+	claudeUsage := anthropic.Usage{
+		InputTokens:              10,
+		OutputTokens:             5,
+		CacheReadInputTokens:     100,
+		CacheCreationInputTokens: 20,
+	}
+	usage := anthropic.UsageClaude2OpenAI(&claudeUsage)
+	assert.Equal(t, 130, usage.PromptTokens)
+	assert.Equal(t, 135, usage.TotalTokens)
+	assert.Equal(t, 100, usage.CachedTokens())
+	assert.Equal(t, 20, usage.CacheCreationTokens())
+	assert.Equal(t, claudeUsage, anthropic.UsageOpenAI2Claude(&usage))
+}
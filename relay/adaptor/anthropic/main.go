@@ -207,6 +207,33 @@ func StreamResponseClaude2OpenAI(claudeResponse *StreamResponse) (*openai.ChatCo
 	return &openaiResponse, response
 }
 
+// UsageClaude2OpenAI converts the usage of claude, the prompt tokens of openai include the tokens read from and written to the cache
+func UsageClaude2OpenAI(claudeUsage *Usage) model.Usage {
+	usage := model.Usage{
+		PromptTokens:     claudeUsage.InputTokens + claudeUsage.CacheReadInputTokens + claudeUsage.CacheCreationInputTokens,
+		CompletionTokens: claudeUsage.OutputTokens,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	if claudeUsage.CacheReadInputTokens > 0 || claudeUsage.CacheCreationInputTokens > 0 {
+		usage.PromptTokensDetails = &model.PromptTokensDetails{
+			CachedTokens:        claudeUsage.CacheReadInputTokens,
+			CacheCreationTokens: claudeUsage.CacheCreationInputTokens,
+		}
+	}
+	return usage
+}
+
+// AccumulateUsage adds the usage reported by a stream event of claude to the total usage
+func AccumulateUsage(usage *model.Usage, claudeUsage *Usage) {
+	streamUsage := UsageClaude2OpenAI(claudeUsage)
+	usage.PromptTokens += streamUsage.PromptTokens
+	usage.CompletionTokens += streamUsage.CompletionTokens
+	usage.TotalTokens += streamUsage.TotalTokens
+	if streamUsage.PromptTokensDetails != nil {
+		usage.PromptTokensDetails = streamUsage.PromptTokensDetails
+	}
+}
+
 func ResponseClaude2OpenAI(claudeResponse *Response) *openai.TextResponse {
 	var responseText string
 	if len(claudeResponse.Content) > 0 {
@@ -286,8 +313,7 @@ func StreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusC
 
 		response, meta := StreamResponseClaude2OpenAI(&claudeResponse)
 		if meta != nil {
-			usage.PromptTokens += meta.Usage.InputTokens
-			usage.CompletionTokens += meta.Usage.OutputTokens
+			AccumulateUsage(&usage, &meta.Usage)
 			if len(meta.Id) > 0 { // only message_start has an id, otherwise it's a finish_reason event.
 				modelName = meta.Model
 				id = fmt.Sprintf("chatcmpl-%s", meta.Id)
@@ -362,11 +388,7 @@ func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName st
 	}
 	fullTextResponse := ResponseClaude2OpenAI(&claudeResponse)
 	fullTextResponse.Model = modelName
-	usage := model.Usage{
-		PromptTokens:     claudeResponse.Usage.InputTokens,
-		CompletionTokens: claudeResponse.Usage.OutputTokens,
-		TotalTokens:      claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
-	}
+	usage := UsageClaude2OpenAI(&claudeResponse.Usage)
 	fullTextResponse.Usage = usage
 	jsonResponse, err := json.Marshal(fullTextResponse)
 	if err != nil {
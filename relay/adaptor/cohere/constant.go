@@ -6,9 +6,15 @@ var ModelList = []string{
 	"command-r", "command-r-plus",
 }
 
+// rerank models don't support the web search connector
+var rerankModelList = []string{
+	"rerank-v3.5", "rerank-english-v3.0", "rerank-multilingual-v3.0",
+}
+
 func init() {
 	num := len(ModelList)
 	for i := 0; i < num; i++ {
 		ModelList = append(ModelList, ModelList[i]+"-internet")
 	}
+	ModelList = append(ModelList, rerankModelList...)
 }
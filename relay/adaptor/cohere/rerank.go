@@ -0,0 +1,66 @@
+package cohere
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://docs.cohere.com/reference/rerank
+
+type RerankBilledUnits struct {
+	SearchUnits int `json:"search_units"`
+}
+
+type RerankMeta struct {
+	BilledUnits *RerankBilledUnits `json:"billed_units,omitempty"`
+}
+
+type RerankResponse struct {
+	Id      string               `json:"id"`
+	Results []model.RerankResult `json:"results"`
+	Meta    RerankMeta           `json:"meta"`
+}
+
+func ResponseCohere2Rerank(response *RerankResponse, modelName string) *model.RerankResponse {
+	return &model.RerankResponse{
+		Id:      response.Id,
+		Model:   modelName,
+		Results: response.Results,
+	}
+}
+
+func RerankHandler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*model.ErrorWithStatusCode, *model.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return openai.ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var cohereResponse RerankResponse
+	err = json.Unmarshal(responseBody, &cohereResponse)
+	if err != nil {
+		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	// cohere bills by search units, the usage is counted locally instead
+	usage := &model.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}
+	rerankResponse := ResponseCohere2Rerank(&cohereResponse, modelName)
+	rerankResponse.Usage = usage
+	jsonResponse, err := json.Marshal(rerankResponse)
+	if err != nil {
+		return openai.ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+	return nil, usage
+}
@@ -0,0 +1,459 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// The generateContent api can also be used by clients to call one-api directly,
+// requests are converted to the openai format so that any channel can serve them,
+// and the openai format responses are converted back.
+// https://ai.google.dev/api/generate-content
+
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileUri  string `json:"fileUri"`
+}
+
+type FunctionResponse struct {
+	Name     string `json:"name"`
+	Response any    `json:"response"`
+}
+
+type InboundPart struct {
+	Text             string            `json:"text,omitempty"`
+	InlineData       *InlineData       `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type InboundContent struct {
+	Role  string        `json:"role,omitempty"`
+	Parts []InboundPart `json:"parts"`
+}
+
+type InboundFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type InboundTool struct {
+	FunctionDeclarations []InboundFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+type InboundToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode,omitempty"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+type InboundGenerationConfig struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             float64  `json:"topP,omitempty"`
+	TopK             float64  `json:"topK,omitempty"`
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	CandidateCount   int      `json:"candidateCount,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+}
+
+type InboundRequest struct {
+	Contents          []InboundContent         `json:"contents"`
+	SystemInstruction *InboundContent          `json:"systemInstruction,omitempty"`
+	Tools             []InboundTool            `json:"tools,omitempty"`
+	ToolConfig        *InboundToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *InboundGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type InboundCandidate struct {
+	Content      InboundContent `json:"content"`
+	FinishReason string         `json:"finishReason,omitempty"`
+	Index        int            `json:"index"`
+}
+
+type InboundResponse struct {
+	Candidates    []InboundCandidate `json:"candidates"`
+	UsageMetadata *UsageMetadata     `json:"usageMetadata,omitempty"`
+	ModelVersion  string             `json:"modelVersion,omitempty"`
+}
+
+// lowerSchemaTypes converts the upper case types of gemini schemas to json schema types
+func lowerSchemaTypes(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			if typ, ok := value.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typ)
+				continue
+			}
+			result[key] = lowerSchemaTypes(value)
+		}
+		return result
+	case []any:
+		result := make([]any, 0, len(v))
+		for _, value := range v {
+			result = append(result, lowerSchemaTypes(value))
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+func inboundPartsText(parts []InboundPart) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func ConvertInboundRequest(request *InboundRequest, modelName string) *model.GeneralOpenAIRequest {
+	textRequest := model.GeneralOpenAIRequest{
+		Model: modelName,
+	}
+	if config := request.GenerationConfig; config != nil {
+		textRequest.Temperature = config.Temperature
+		textRequest.TopP = config.TopP
+		textRequest.TopK = int(config.TopK)
+		textRequest.MaxTokens = config.MaxOutputTokens
+		textRequest.N = config.CandidateCount
+		if len(config.StopSequences) > 0 {
+			textRequest.Stop = config.StopSequences
+		}
+		if config.ResponseMimeType == "application/json" {
+			textRequest.ResponseFormat = &model.ResponseFormat{Type: "json_object"}
+		}
+	}
+	if request.SystemInstruction != nil {
+		if system := inboundPartsText(request.SystemInstruction.Parts); system != "" {
+			textRequest.Messages = append(textRequest.Messages, model.Message{
+				Role:    "system",
+				Content: system,
+			})
+		}
+	}
+	// gemini has no tool call ids, function responses are matched with calls by name
+	toolCallIds := make(map[string][]string)
+	toolCallCount := 0
+	for _, content := range request.Contents {
+		var contents []any
+		var toolCalls []model.Tool
+		for _, part := range content.Parts {
+			switch {
+			case part.Text != "":
+				contents = append(contents, map[string]any{
+					"type": model.ContentTypeText,
+					"text": part.Text,
+				})
+			case part.InlineData != nil:
+				contents = append(contents, map[string]any{
+					"type": model.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": fmt.Sprintf("data:%s;base64,%s", part.InlineData.MimeType, part.InlineData.Data),
+					},
+				})
+			case part.FileData != nil:
+				contents = append(contents, map[string]any{
+					"type": model.ContentTypeImageURL,
+					"image_url": map[string]any{
+						"url": part.FileData.FileUri,
+					},
+				})
+			case part.FunctionCall != nil:
+				toolCallCount++
+				id := fmt.Sprintf("call_%d", toolCallCount)
+				toolCallIds[part.FunctionCall.FunctionName] = append(toolCallIds[part.FunctionCall.FunctionName], id)
+				arguments, _ := json.Marshal(part.FunctionCall.Arguments)
+				toolCalls = append(toolCalls, model.Tool{
+					Id:   id,
+					Type: "function",
+					Function: model.Function{
+						Name:      part.FunctionCall.FunctionName,
+						Arguments: string(arguments),
+					},
+				})
+			case part.FunctionResponse != nil:
+				var id string
+				if ids := toolCallIds[part.FunctionResponse.Name]; len(ids) > 0 {
+					id = ids[0]
+					toolCallIds[part.FunctionResponse.Name] = ids[1:]
+				}
+				response, _ := json.Marshal(part.FunctionResponse.Response)
+				textRequest.Messages = append(textRequest.Messages, model.Message{
+					Role:       "tool",
+					Content:    string(response),
+					ToolCallId: id,
+				})
+			}
+		}
+		if content.Role == "model" {
+			if len(contents) == 0 && len(toolCalls) == 0 {
+				continue
+			}
+			textRequest.Messages = append(textRequest.Messages, model.Message{
+				Role:      "assistant",
+				Content:   inboundPartsText(content.Parts),
+				ToolCalls: toolCalls,
+			})
+			continue
+		}
+		if len(contents) > 0 {
+			textRequest.Messages = append(textRequest.Messages, model.Message{
+				Role:    "user",
+				Content: contents,
+			})
+		}
+	}
+	for _, tool := range request.Tools {
+		for _, function := range tool.FunctionDeclarations {
+			textRequest.Tools = append(textRequest.Tools, model.Tool{
+				Type: "function",
+				Function: model.Function{
+					Name:        function.Name,
+					Description: function.Description,
+					Parameters:  lowerSchemaTypes(function.Parameters),
+				},
+			})
+		}
+	}
+	if request.ToolConfig != nil && len(textRequest.Tools) > 0 {
+		config := request.ToolConfig.FunctionCallingConfig
+		switch config.Mode {
+		case "AUTO":
+			textRequest.ToolChoice = "auto"
+		case "NONE":
+			textRequest.ToolChoice = "none"
+		case "ANY":
+			textRequest.ToolChoice = "required"
+			if len(config.AllowedFunctionNames) == 1 {
+				textRequest.ToolChoice = map[string]any{
+					"type": "function",
+					"function": map[string]any{
+						"name": config.AllowedFunctionNames[0],
+					},
+				}
+			}
+		}
+	}
+	return &textRequest
+}
+
+func finishReasonOpenAI2Gemini(reason string) string {
+	switch reason {
+	case "length":
+		return "MAX_TOKENS"
+	case "content_filter":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
+
+func functionCallPart(tool model.Tool) InboundPart {
+	arguments := make(map[string]any)
+	_ = json.Unmarshal([]byte(conv.AsString(tool.Function.Arguments)), &arguments)
+	return InboundPart{
+		FunctionCall: &FunctionCall{
+			FunctionName: tool.Function.Name,
+			Arguments:    arguments,
+		},
+	}
+}
+
+func ResponseOpenAI2Gemini(response *openai.TextResponse) *InboundResponse {
+	geminiResponse := InboundResponse{
+		Candidates:   make([]InboundCandidate, 0, len(response.Choices)),
+		ModelVersion: response.Model,
+		UsageMetadata: &UsageMetadata{
+			PromptTokenCount:     response.PromptTokens,
+			CandidatesTokenCount: response.CompletionTokens,
+			TotalTokenCount:      response.TotalTokens,
+		},
+	}
+	for _, choice := range response.Choices {
+		candidate := InboundCandidate{
+			Content: InboundContent{
+				Role:  "model",
+				Parts: make([]InboundPart, 0),
+			},
+			FinishReason: finishReasonOpenAI2Gemini(choice.FinishReason),
+			Index:        choice.Index,
+		}
+		if text := choice.Message.StringContent(); text != "" {
+			candidate.Content.Parts = append(candidate.Content.Parts, InboundPart{Text: text})
+		}
+		for _, tool := range choice.Message.ToolCalls {
+			candidate.Content.Parts = append(candidate.Content.Parts, functionCallPart(tool))
+		}
+		geminiResponse.Candidates = append(geminiResponse.Candidates, candidate)
+	}
+	return &geminiResponse
+}
+
+func statusByStatusCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// ErrorOpenAI2Gemini converts an openai format error response body
+func ErrorOpenAI2Gemini(statusCode int, body []byte) []byte {
+	var errorResponse struct {
+		Error model.Error `json:"error"`
+	}
+	_ = json.Unmarshal(body, &errorResponse)
+	message := errorResponse.Error.Message
+	if message == "" {
+		message = string(body)
+	}
+	jsonResponse, _ := json.Marshal(map[string]any{
+		"error": Error{
+			Code:    statusCode,
+			Message: message,
+			Status:  statusByStatusCode(statusCode),
+		},
+	})
+	return jsonResponse
+}
+
+// StreamConverter converts openai format stream chunks to streamGenerateContent chunks,
+// the chunks are sent as server-sent events if sse is true, otherwise as a json array
+type StreamConverter struct {
+	modelName    string
+	promptTokens int
+	sse          bool
+	chunkCount   int
+	finishReason string
+	responseText string
+	toolCalls    []model.Tool
+	usage        *model.Usage
+}
+
+func NewStreamConverter(modelName string, promptTokens int, sse bool) *StreamConverter {
+	return &StreamConverter{
+		modelName:    modelName,
+		promptTokens: promptTokens,
+		sse:          sse,
+	}
+}
+
+func (s *StreamConverter) chunk(response *InboundResponse) string {
+	jsonResponse, _ := json.Marshal(response)
+	s.chunkCount++
+	if s.sse {
+		return fmt.Sprintf("data: %s\r\n\r\n", jsonResponse)
+	}
+	if s.chunkCount == 1 {
+		return "[" + string(jsonResponse)
+	}
+	return ",\r\n" + string(jsonResponse)
+}
+
+// Convert converts the data of a single openai stream chunk
+func (s *StreamConverter) Convert(data string) []string {
+	var streamResponse openai.ChatCompletionsStreamResponse
+	if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+		return nil
+	}
+	if streamResponse.Usage != nil {
+		s.usage = streamResponse.Usage
+	}
+	var chunks []string
+	for _, choice := range streamResponse.Choices {
+		for _, tool := range choice.Delta.ToolCalls {
+			// function calls are sent as a whole once finished
+			if tool.Id != "" || len(s.toolCalls) == 0 {
+				s.toolCalls = append(s.toolCalls, model.Tool{
+					Id:       tool.Id,
+					Function: model.Function{Name: tool.Function.Name},
+				})
+			}
+			last := &s.toolCalls[len(s.toolCalls)-1]
+			last.Function.Arguments = conv.AsString(last.Function.Arguments) + conv.AsString(tool.Function.Arguments)
+			s.responseText += conv.AsString(tool.Function.Arguments)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+		text := conv.AsString(choice.Delta.Content)
+		if text == "" {
+			continue
+		}
+		s.responseText += text
+		chunks = append(chunks, s.chunk(&InboundResponse{
+			Candidates: []InboundCandidate{{
+				Content: InboundContent{
+					Role:  "model",
+					Parts: []InboundPart{{Text: text}},
+				},
+				Index: choice.Index,
+			}},
+			ModelVersion: streamResponse.Model,
+		}))
+	}
+	return chunks
+}
+
+// Finish sends the function calls, the finish reason and the usage once the openai stream is done
+func (s *StreamConverter) Finish() []string {
+	usage := &UsageMetadata{}
+	if s.usage != nil {
+		usage.PromptTokenCount = s.usage.PromptTokens
+		usage.CandidatesTokenCount = s.usage.CompletionTokens
+	} else {
+		usage.PromptTokenCount = s.promptTokens
+		usage.CandidatesTokenCount = openai.CountTokenText(s.responseText, s.modelName)
+	}
+	usage.TotalTokenCount = usage.PromptTokenCount + usage.CandidatesTokenCount
+	candidate := InboundCandidate{
+		Content: InboundContent{
+			Role:  "model",
+			Parts: make([]InboundPart, 0, len(s.toolCalls)),
+		},
+		FinishReason: finishReasonOpenAI2Gemini(s.finishReason),
+	}
+	for _, tool := range s.toolCalls {
+		candidate.Content.Parts = append(candidate.Content.Parts, functionCallPart(tool))
+	}
+	chunks := []string{s.chunk(&InboundResponse{
+		Candidates:    []InboundCandidate{candidate},
+		UsageMetadata: usage,
+		ModelVersion:  s.modelName,
+	})}
+	if !s.sse {
+		chunks = append(chunks, "]")
+	}
+	return chunks
+}
@@ -0,0 +1,187 @@
+package gemini_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/songquanpeng/one-api/relay/adaptor/gemini"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertInboundRequest(t *testing.T) {
+	var request gemini.InboundRequest
+	err := json.Unmarshal([]byte(`{
+		"systemInstruction": {"parts": [{"text": "You are a weather bot."}]},
+		"generationConfig": {"temperature": 0, "topP": 0.5, "topK": 40, "maxOutputTokens": 256, "stopSequences": ["END"], "responseMimeType": "application/json"},
+		"tools": [{"functionDeclarations": [{"name": "get_weather", "parameters": {"type": "OBJECT", "properties": {"city": {"type": "STRING"}}}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["get_weather"]}},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Weather in Paris?"}, {"inlineData": {"mimeType": "image/png", "data": "aGk="}}]},
+			{"role": "model", "parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "get_weather", "response": {"weather": "sunny"}}}]},
+			{"role": "model", "parts": [{"text": "It is sunny."}]}
+		]
+	}`), &request)
+	assert.NoError(t, err)
+
+	textRequest := gemini.ConvertInboundRequest(&request, "gemini-1.5-pro")
+	assert.Equal(t, "gemini-1.5-pro", textRequest.Model)
+	if assert.NotNil(t, textRequest.Temperature) {
+		assert.Equal(t, 0.0, *textRequest.Temperature)
+	}
+	assert.Equal(t, 0.5, textRequest.TopP)
+	assert.Equal(t, 40, textRequest.TopK)
+	assert.Equal(t, 256, textRequest.MaxTokens)
+	assert.Equal(t, []string{"END"}, textRequest.Stop)
+	assert.Equal(t, "json_object", textRequest.ResponseFormat.Type)
+
+	assert.Len(t, textRequest.Tools, 1)
+	assert.Equal(t, "get_weather", textRequest.Tools[0].Function.Name)
+	parameters := textRequest.Tools[0].Function.Parameters.(map[string]any)
+	assert.Equal(t, "object", parameters["type"])
+	assert.Equal(t, "string", parameters["properties"].(map[string]any)["city"].(map[string]any)["type"])
+	assert.Equal(t, map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": "get_weather"},
+	}, textRequest.ToolChoice)
+
+	assert.Len(t, textRequest.Messages, 5)
+	assert.Equal(t, "system", textRequest.Messages[0].Role)
+	assert.Equal(t, "You are a weather bot.", textRequest.Messages[0].StringContent())
+
+	assert.Equal(t, "user", textRequest.Messages[1].Role)
+	contents := textRequest.Messages[1].ParseContent()
+	assert.Len(t, contents, 2)
+	assert.Equal(t, "Weather in Paris?", contents[0].Text)
+	assert.Equal(t, "data:image/png;base64,aGk=", contents[1].ImageURL.Url)
+
+	// the function responses are matched with the calls by name
+	assert.Equal(t, "assistant", textRequest.Messages[2].Role)
+	assert.Equal(t, "call_1", textRequest.Messages[2].ToolCalls[0].Id)
+	assert.Equal(t, `{"city":"Paris"}`, textRequest.Messages[2].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool", textRequest.Messages[3].Role)
+	assert.Equal(t, "call_1", textRequest.Messages[3].ToolCallId)
+	assert.Equal(t, `{"weather":"sunny"}`, textRequest.Messages[3].Content)
+	assert.Equal(t, "assistant", textRequest.Messages[4].Role)
+	assert.Equal(t, "It is sunny.", textRequest.Messages[4].StringContent())
+}
+
+func TestConvertInboundRequestToolConfig(t *testing.T) {
+	request := gemini.InboundRequest{
+		Contents: []gemini.InboundContent{{Role: "user", Parts: []gemini.InboundPart{{Text: "Hi"}}}},
+		Tools:    []gemini.InboundTool{{FunctionDeclarations: []gemini.InboundFunctionDeclaration{{Name: "a"}, {Name: "b"}}}},
+	}
+	for mode, expected := range map[string]any{"AUTO": "auto", "NONE": "none", "ANY": "required"} {
+		request.ToolConfig = &gemini.InboundToolConfig{}
+		request.ToolConfig.FunctionCallingConfig.Mode = mode
+		assert.Equal(t, expected, gemini.ConvertInboundRequest(&request, "gemini-pro").ToolChoice, mode)
+	}
+
+	// the tool config is ignored without tools
+	request.Tools = nil
+	assert.Nil(t, gemini.ConvertInboundRequest(&request, "gemini-pro").ToolChoice)
+	assert.Nil(t, gemini.ConvertInboundRequest(&request, "gemini-pro").Temperature)
+}
+
+func TestResponseOpenAI2Gemini(t *testing.T) {
+	response := openai.TextResponse{
+		Model: "gpt-4o",
+		Choices: []openai.TextResponseChoice{{
+			Index: 0,
+			Message: model.Message{
+				Role:    "assistant",
+				Content: "Let me check.",
+				ToolCalls: []model.Tool{{
+					Id:       "call_1",
+					Type:     "function",
+					Function: model.Function{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+				}},
+			},
+			FinishReason: "length",
+		}},
+		Usage: model.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}
+
+	geminiResponse := gemini.ResponseOpenAI2Gemini(&response)
+	assert.Equal(t, "gpt-4o", geminiResponse.ModelVersion)
+	assert.Equal(t, 15, geminiResponse.UsageMetadata.TotalTokenCount)
+	assert.Len(t, geminiResponse.Candidates, 1)
+	candidate := geminiResponse.Candidates[0]
+	assert.Equal(t, "MAX_TOKENS", candidate.FinishReason)
+	assert.Equal(t, "model", candidate.Content.Role)
+	assert.Len(t, candidate.Content.Parts, 2)
+	assert.Equal(t, "Let me check.", candidate.Content.Parts[0].Text)
+	assert.Equal(t, "get_weather", candidate.Content.Parts[1].FunctionCall.FunctionName)
+	assert.Equal(t, map[string]any{"city": "Paris"}, candidate.Content.Parts[1].FunctionCall.Arguments)
+}
+
+func TestErrorOpenAI2Gemini(t *testing.T) {
+	var response struct {
+		Error gemini.Error `json:"error"`
+	}
+	body := gemini.ErrorOpenAI2Gemini(http.StatusTooManyRequests, []byte(`{"error":{"message":"slow down","type":"rate_limit"}}`))
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, http.StatusTooManyRequests, response.Error.Code)
+	assert.Equal(t, "slow down", response.Error.Message)
+	assert.Equal(t, "RESOURCE_EXHAUSTED", response.Error.Status)
+
+	// the raw body is kept when it is not an openai error
+	body = gemini.ErrorOpenAI2Gemini(http.StatusBadGateway, []byte("bad gateway"))
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, "bad gateway", response.Error.Message)
+	assert.Equal(t, "INTERNAL", response.Error.Status)
+}
+
+func TestStreamConverter(t *testing.T) {
+	chunks := []string{
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}}]}`,
+		`{"model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"function":{"arguments":"\"Paris\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		`{"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":8,"completion_tokens":4,"total_tokens":12}}`,
+	}
+
+	converter := gemini.NewStreamConverter("gpt-4o", 0, true)
+	var output []string
+	for _, chunk := range chunks {
+		output = append(output, converter.Convert(chunk)...)
+	}
+	output = append(output, converter.Finish()...)
+	assert.Len(t, output, 3)
+
+	var responses []gemini.InboundResponse
+	for _, chunk := range output {
+		assert.True(t, strings.HasPrefix(chunk, "data: "))
+		var response gemini.InboundResponse
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(chunk, "data: "))), &response))
+		responses = append(responses, response)
+	}
+	assert.Equal(t, "Hel", responses[0].Candidates[0].Content.Parts[0].Text)
+	assert.Equal(t, "lo", responses[1].Candidates[0].Content.Parts[0].Text)
+	last := responses[2]
+	assert.Equal(t, "STOP", last.Candidates[0].FinishReason)
+	assert.Equal(t, "get_weather", last.Candidates[0].Content.Parts[0].FunctionCall.FunctionName)
+	assert.Equal(t, map[string]any{"city": "Paris"}, last.Candidates[0].Content.Parts[0].FunctionCall.Arguments)
+	assert.Equal(t, 8, last.UsageMetadata.PromptTokenCount)
+	assert.Equal(t, 4, last.UsageMetadata.CandidatesTokenCount)
+	assert.Equal(t, 12, last.UsageMetadata.TotalTokenCount)
+}
+
+func TestStreamConverterJSONArray(t *testing.T) {
+	converter := gemini.NewStreamConverter("gpt-4o", 0, false)
+	var output []string
+	output = append(output, converter.Convert(`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`)...)
+	output = append(output, converter.Convert(`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)...)
+	output = append(output, converter.Finish()...)
+
+	var responses []gemini.InboundResponse
+	assert.NoError(t, json.Unmarshal([]byte(strings.Join(output, "")), &responses))
+	assert.Len(t, responses, 2)
+	assert.Equal(t, "Hi", responses[0].Candidates[0].Content.Parts[0].Text)
+	assert.Equal(t, "STOP", responses[1].Candidates[0].FinishReason)
+	assert.Equal(t, 2, responses[1].UsageMetadata.TotalTokenCount)
+}
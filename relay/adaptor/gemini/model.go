@@ -65,7 +65,7 @@ type ChatTools struct {
 }
 
 type ChatGenerationConfig struct {
-	Temperature     float64  `json:"temperature,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
 	TopP            float64  `json:"topP,omitempty"`
 	TopK            float64  `json:"topK,omitempty"`
 	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
@@ -19,3 +19,9 @@ type Adaptor interface {
 	GetModelList() []string
 	GetChannelName() string
 }
+
+// RerankAdaptor is implemented by the adaptors which support the rerank api
+type RerankAdaptor interface {
+	Adaptor
+	ConvertRerankRequest(request *model.RerankRequest) (any, error)
+}
@@ -0,0 +1,14 @@
+package jina
+
+// https://jina.ai/reranker
+
+var ModelList = []string{
+	"jina-reranker-v2-base-multilingual",
+	"jina-reranker-v1-base-en",
+	"jina-reranker-v1-turbo-en",
+	"jina-reranker-v1-tiny-en",
+	"jina-colbert-v2",
+	"jina-embeddings-v3",
+	"jina-embeddings-v2-base-en",
+	"jina-embeddings-v2-base-zh",
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -35,6 +36,21 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 			fullRequestURL := fmt.Sprintf("%s/openai/deployments/%s/images/generations?api-version=%s", meta.BaseURL, meta.ActualModelName, meta.Config.APIVersion)
 			return fullRequestURL, nil
 		}
+		if meta.Mode == relaymode.Files || meta.Mode == relaymode.Batches || meta.Mode == relaymode.Responses {
+			// https://learn.microsoft.com/en-us/azure/ai-services/openai/reference-preview#files---upload
+			// https://{resource_name}.openai.azure.com/openai/files/{file_id}?api-version=2024-05-01-preview
+			// https://{resource_name}.openai.azure.com/openai/batches/{batch_id}?api-version=2024-07-01-preview
+			// https://{resource_name}.openai.azure.com/openai/responses?api-version=2025-03-01-preview
+			requestURL := strings.TrimPrefix(strings.Split(meta.RequestURLPath, "?")[0], "/v1")
+			fullRequestURL := fmt.Sprintf("%s/openai%s?api-version=%s", meta.BaseURL, requestURL, meta.Config.APIVersion)
+			return fullRequestURL, nil
+		}
+		if meta.Mode == relaymode.Realtime {
+			// https://learn.microsoft.com/en-us/azure/ai-services/openai/how-to/realtime-audio
+			// wss://{resource_name}.openai.azure.com/openai/realtime?api-version=2024-10-01-preview&deployment=gpt-4o-realtime-preview
+			fullRequestURL := fmt.Sprintf("%s/openai/realtime?api-version=%s&deployment=%s", meta.BaseURL, meta.Config.APIVersion, meta.ActualModelName)
+			return fullRequestURL, nil
+		}
 
 		// https://learn.microsoft.com/en-us/azure/cognitive-services/openai/chatgpt-quickstart?pivots=rest-api&tabs=command-line#rest-api
 		requestURL := strings.Split(meta.RequestURLPath, "?")[0]
@@ -53,6 +69,10 @@ func (a *Adaptor) GetRequestURL(meta *meta.Meta) (string, error) {
 	case channeltype.Novita:
 		return novita.GetRequestURL(meta)
 	default:
+		if meta.Mode == relaymode.Realtime {
+			// the model may be mapped, so the query is rebuilt
+			return GetFullRequestURL(meta.BaseURL, "/v1/realtime?model="+url.QueryEscape(meta.ActualModelName), meta.ChannelType), nil
+		}
 		return GetFullRequestURL(meta.BaseURL, meta.RequestURLPath, meta.ChannelType), nil
 	}
 }
@@ -85,6 +105,30 @@ func (a *Adaptor) ConvertImageRequest(request *model.ImageRequest) (any, error)
 	return request, nil
 }
 
+// RerankChannelTypes are the openai compatible channel types implementing the jina rerank api
+var RerankChannelTypes = []int{channeltype.Jina, channeltype.SiliconFlow}
+
+// SupportRerank reports whether the rerank api can be relayed to the openai compatible channel
+func SupportRerank(channelType int) bool {
+	for _, supported := range RerankChannelTypes {
+		if channelType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertRerankRequest implements adaptor.RerankAdaptor, jina compatible channels accept the request as is
+func (a *Adaptor) ConvertRerankRequest(request *model.RerankRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	if !SupportRerank(a.ChannelType) {
+		return nil, fmt.Errorf("channel type %d does not support rerank api", a.ChannelType)
+	}
+	return request, nil
+}
+
 func (a *Adaptor) DoRequest(c *gin.Context, meta *meta.Meta, requestBody io.Reader) (*http.Response, error) {
 	return adaptor.DoRequestHelper(a, c, meta, requestBody)
 }
@@ -102,8 +146,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, meta *meta.Met
 		}
 	} else {
 		switch meta.Mode {
-		case relaymode.ImagesGenerations:
+		case relaymode.ImagesGenerations, relaymode.ImagesEdits, relaymode.ImagesVariations:
 			err, _ = ImageHandler(c, resp)
+		case relaymode.Rerank:
+			err, usage = RerankHandler(c, resp, meta.PromptTokens, meta.ActualModelName, a.ChannelType)
 		default:
 			err, usage = Handler(c, resp, meta.PromptTokens, meta.ActualModelName)
 		}
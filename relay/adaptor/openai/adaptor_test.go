@@ -0,0 +1,28 @@
+package openai_test
+
+import (
+	"testing"
+
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertRerankRequest(t *testing.T) {
+	request := &model.RerankRequest{Model: "jina-reranker-v2-base-multilingual", Query: "q", Documents: []any{"a"}}
+	for _, channelType := range []int{channeltype.Jina, channeltype.SiliconFlow} {
+		adaptor := &openai.Adaptor{}
+		adaptor.Init(&meta.Meta{ChannelType: channelType})
+		converted, err := adaptor.ConvertRerankRequest(request)
+		assert.NoError(t, err)
+		assert.Equal(t, request, converted)
+	}
+	for _, channelType := range []int{channeltype.OpenAI, channeltype.Azure, channeltype.DeepSeek} {
+		adaptor := &openai.Adaptor{}
+		adaptor.Init(&meta.Meta{ChannelType: channelType})
+		_, err := adaptor.ConvertRerankRequest(request)
+		assert.Error(t, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/songquanpeng/one-api/relay/adaptor/deepseek"
 	"github.com/songquanpeng/one-api/relay/adaptor/doubao"
 	"github.com/songquanpeng/one-api/relay/adaptor/groq"
+	"github.com/songquanpeng/one-api/relay/adaptor/jina"
 	"github.com/songquanpeng/one-api/relay/adaptor/lingyiwanwu"
 	"github.com/songquanpeng/one-api/relay/adaptor/minimax"
 	"github.com/songquanpeng/one-api/relay/adaptor/mistral"
@@ -32,6 +33,7 @@ var CompatibleChannels = []int{
 	channeltype.TogetherAI,
 	channeltype.Novita,
 	channeltype.SiliconFlow,
+	channeltype.Jina,
 }
 
 func GetCompatibleChannelMeta(channelType int) (string, []string) {
@@ -64,6 +66,8 @@ func GetCompatibleChannelMeta(channelType int) (string, []string) {
 		return "novita", novita.ModelList
 	case channeltype.SiliconFlow:
 		return "siliconflow", siliconflow.ModelList
+	case channeltype.Jina:
+		return "jina", jina.ModelList
 	default:
 		return "openai", ModelList
 	}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/siliconflow"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://jina.ai/reranker
+
+// RerankHandler handles the rerank responses of jina compatible channels,
+// the usage is counted locally since the upstreams report it differently
+func RerankHandler(c *gin.Context, resp *http.Response, promptTokens int, modelName string, channelType int) (*model.ErrorWithStatusCode, *model.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), nil
+	}
+	var rerankResponse model.RerankResponse
+	switch channelType {
+	case channeltype.SiliconFlow:
+		var siliconflowResponse siliconflow.RerankResponse
+		err = json.Unmarshal(responseBody, &siliconflowResponse)
+		rerankResponse = *siliconflow.ResponseSiliconFlow2Rerank(&siliconflowResponse)
+	default:
+		err = json.Unmarshal(responseBody, &rerankResponse)
+	}
+	if err != nil {
+		return ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	usage := &model.Usage{
+		PromptTokens: promptTokens,
+		TotalTokens:  promptTokens,
+	}
+	rerankResponse.Model = modelName
+	rerankResponse.Usage = usage
+	jsonResponse, err := json.Marshal(rerankResponse)
+	if err != nil {
+		return ErrorWrapper(err, "marshal_response_body_failed", http.StatusInternalServerError), nil
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, _ = c.Writer.Write(jsonResponse)
+	return nil, usage
+}
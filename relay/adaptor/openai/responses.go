@@ -0,0 +1,734 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// The responses api is passed through to channels which support it natively,
+// for other channels the requests are converted to chat completions and the responses are converted back.
+// https://platform.openai.com/docs/api-reference/responses
+
+type ResponsesInputItem struct {
+	Type      string `json:"type,omitempty"` // message when empty
+	Role      string `json:"role,omitempty"`
+	Content   any    `json:"content,omitempty"` // string or a list of contents
+	CallId    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    any    `json:"output,omitempty"`
+}
+
+type ResponsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageUrl string `json:"image_url,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+type ResponsesTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+	Strict      *bool  `json:"strict,omitempty"`
+}
+
+type ResponsesTextFormat struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+	Strict      *bool          `json:"strict,omitempty"`
+}
+
+type ResponsesText struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
+type ResponsesRequest struct {
+	Model              string          `json:"model"`
+	Input              any             `json:"input,omitempty"` // string or a list of items
+	Instructions       string          `json:"instructions,omitempty"`
+	Tools              []ResponsesTool `json:"tools,omitempty"`
+	ToolChoice         any             `json:"tool_choice,omitempty"`
+	MaxOutputTokens    int             `json:"max_output_tokens,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               float64         `json:"top_p,omitempty"`
+	Stream             bool            `json:"stream,omitempty"`
+	Store              *bool           `json:"store,omitempty"`
+	PreviousResponseId string          `json:"previous_response_id,omitempty"`
+	Text               *ResponsesText  `json:"text,omitempty"`
+	User               string          `json:"user,omitempty"`
+}
+
+// ShouldStore reports whether the response can be referenced by previous_response_id later, which is the default
+func (r ResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+type ResponsesInputTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type ResponsesUsage struct {
+	InputTokens        int                          `json:"input_tokens"`
+	InputTokensDetails *ResponsesInputTokensDetails `json:"input_tokens_details,omitempty"`
+	OutputTokens       int                          `json:"output_tokens"`
+	TotalTokens        int                          `json:"total_tokens"`
+}
+
+func newResponsesUsage(usage *model.Usage) ResponsesUsage {
+	responsesUsage := ResponsesUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+	if cachedTokens := usage.CachedTokens(); cachedTokens > 0 {
+		responsesUsage.InputTokensDetails = &ResponsesInputTokensDetails{
+			CachedTokens: cachedTokens,
+		}
+	}
+	return responsesUsage
+}
+
+type ResponsesOutputText struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	Annotations []any  `json:"annotations"`
+}
+
+type ResponsesMessageItem struct {
+	Type    string                `json:"type"`
+	Id      string                `json:"id"`
+	Status  string                `json:"status"`
+	Role    string                `json:"role"`
+	Content []ResponsesOutputText `json:"content"`
+}
+
+type ResponsesFunctionCallItem struct {
+	Type      string `json:"type"`
+	Id        string `json:"id"`
+	CallId    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Status    string `json:"status"`
+}
+
+type ResponsesIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type ResponsesResponse struct {
+	Id                 string                      `json:"id"`
+	Object             string                      `json:"object"`
+	CreatedAt          int64                       `json:"created_at"`
+	Status             string                      `json:"status"`
+	Model              string                      `json:"model"`
+	Output             []any                       `json:"output"`
+	IncompleteDetails  *ResponsesIncompleteDetails `json:"incomplete_details"`
+	Instructions       string                      `json:"instructions,omitempty"`
+	PreviousResponseId string                      `json:"previous_response_id,omitempty"`
+	Usage              *ResponsesUsage             `json:"usage"`
+	Error              *model.Error                `json:"error"`
+}
+
+// ResponsesChannelTypes are the channel types implementing the responses api natively
+var ResponsesChannelTypes = []int{channeltype.OpenAI, channeltype.Azure}
+
+// SupportResponses reports whether the responses api can be passed through to the channel
+func SupportResponses(channelType int) bool {
+	for _, supported := range ResponsesChannelTypes {
+		if channelType == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func responsesContentText(content any) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+	var contents []ResponsesInputContent
+	jsonContent, _ := json.Marshal(content)
+	_ = json.Unmarshal(jsonContent, &contents)
+	var texts []string
+	for _, item := range contents {
+		if item.Text != "" {
+			texts = append(texts, item.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func convertResponsesContent(content any) any {
+	if text, ok := content.(string); ok {
+		return text
+	}
+	var contents []ResponsesInputContent
+	jsonContent, _ := json.Marshal(content)
+	_ = json.Unmarshal(jsonContent, &contents)
+	var messageContents []model.MessageContent
+	hasImage := false
+	for _, item := range contents {
+		switch item.Type {
+		case "input_text", "output_text":
+			messageContents = append(messageContents, model.MessageContent{
+				Type: model.ContentTypeText,
+				Text: item.Text,
+			})
+		case "input_image":
+			hasImage = true
+			messageContents = append(messageContents, model.MessageContent{
+				Type: model.ContentTypeImageURL,
+				ImageURL: &model.ImageURL{
+					Url:    item.ImageUrl,
+					Detail: item.Detail,
+				},
+			})
+		}
+	}
+	if !hasImage {
+		return responsesContentText(content)
+	}
+	return messageContents
+}
+
+// ConvertResponsesInput converts the input items to chat completions messages
+func ConvertResponsesInput(input any) []model.Message {
+	if text, ok := input.(string); ok {
+		return []model.Message{{Role: "user", Content: text}}
+	}
+	var items []ResponsesInputItem
+	jsonInput, _ := json.Marshal(input)
+	_ = json.Unmarshal(jsonInput, &items)
+	var messages []model.Message
+	for _, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			messages = append(messages, model.Message{
+				Role:    role,
+				Content: convertResponsesContent(item.Content),
+			})
+		case "function_call":
+			tool := model.Tool{
+				Id:   item.CallId,
+				Type: "function",
+				Function: model.Function{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// parallel function calls belong to the same assistant message
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" && len(messages[last].ToolCalls) > 0 {
+				messages[last].ToolCalls = append(messages[last].ToolCalls, tool)
+				continue
+			}
+			messages = append(messages, model.Message{
+				Role:      "assistant",
+				Content:   "",
+				ToolCalls: []model.Tool{tool},
+			})
+		case "function_call_output":
+			messages = append(messages, model.Message{
+				Role:       "tool",
+				Content:    responsesContentText(item.Output),
+				ToolCallId: item.CallId,
+			})
+		}
+	}
+	return messages
+}
+
+// ConvertResponsesRequest converts the request to a chat completions request,
+// messages are the conversation so far, including the converted input
+func ConvertResponsesRequest(request *ResponsesRequest, messages []model.Message) *model.GeneralOpenAIRequest {
+	textRequest := model.GeneralOpenAIRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxOutputTokens,
+		Temperature: request.Temperature,
+		TopP:        request.TopP,
+		Stream:      request.Stream,
+		User:        request.User,
+	}
+	if request.Instructions != "" {
+		textRequest.Messages = append(textRequest.Messages, model.Message{
+			Role:    "system",
+			Content: request.Instructions,
+		})
+	}
+	textRequest.Messages = append(textRequest.Messages, messages...)
+	for _, tool := range request.Tools {
+		// built-in tools such as web search are only available through the native api
+		if tool.Type != "function" {
+			continue
+		}
+		textRequest.Tools = append(textRequest.Tools, model.Tool{
+			Type: "function",
+			Function: model.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if len(textRequest.Tools) > 0 {
+		switch toolChoice := request.ToolChoice.(type) {
+		case string:
+			textRequest.ToolChoice = toolChoice
+		case map[string]any:
+			if toolChoice["type"] == "function" {
+				textRequest.ToolChoice = map[string]any{
+					"type":     "function",
+					"function": map[string]any{"name": toolChoice["name"]},
+				}
+			}
+		}
+	}
+	if request.Text != nil && request.Text.Format != nil {
+		switch request.Text.Format.Type {
+		case "json_object":
+			textRequest.ResponseFormat = &model.ResponseFormat{Type: "json_object"}
+		case "json_schema":
+			textRequest.ResponseFormat = &model.ResponseFormat{
+				Type: "json_schema",
+				JsonSchema: &model.JSONSchema{
+					Description: request.Text.Format.Description,
+					Name:        request.Text.Format.Name,
+					Schema:      request.Text.Format.Schema,
+					Strict:      request.Text.Format.Strict,
+				},
+			}
+		}
+	}
+	return &textRequest
+}
+
+func newResponsesResponse(request *ResponsesRequest, modelName string) *ResponsesResponse {
+	if modelName == "" {
+		modelName = request.Model
+	}
+	return &ResponsesResponse{
+		Id:                 "resp_" + random.GetUUID(),
+		Object:             "response",
+		CreatedAt:          helper.GetTimestamp(),
+		Status:             "in_progress",
+		Model:              modelName,
+		Output:             make([]any, 0),
+		Instructions:       request.Instructions,
+		PreviousResponseId: request.PreviousResponseId,
+	}
+}
+
+func (r *ResponsesResponse) finish(finishReason string, usage ResponsesUsage) {
+	r.Status = "completed"
+	switch finishReason {
+	case "length":
+		r.Status = "incomplete"
+		r.IncompleteDetails = &ResponsesIncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		r.Status = "incomplete"
+		r.IncompleteDetails = &ResponsesIncompleteDetails{Reason: "content_filter"}
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	r.Usage = &usage
+}
+
+func newMessageItem(status string) *ResponsesMessageItem {
+	return &ResponsesMessageItem{
+		Type:    "message",
+		Id:      "msg_" + random.GetUUID(),
+		Status:  status,
+		Role:    "assistant",
+		Content: make([]ResponsesOutputText, 0),
+	}
+}
+
+func newOutputText(text string) ResponsesOutputText {
+	return ResponsesOutputText{
+		Type:        "output_text",
+		Text:        text,
+		Annotations: make([]any, 0),
+	}
+}
+
+func newFunctionCallItem(callId string, name string, arguments string, status string) *ResponsesFunctionCallItem {
+	return &ResponsesFunctionCallItem{
+		Type:      "function_call",
+		Id:        "fc_" + random.GetUUID(),
+		CallId:    callId,
+		Name:      name,
+		Arguments: arguments,
+		Status:    status,
+	}
+}
+
+// ResponseText2Responses converts a chat completions response
+func ResponseText2Responses(response *TextResponse, request *ResponsesRequest) *ResponsesResponse {
+	responsesResponse := newResponsesResponse(request, response.Model)
+	finishReason := ""
+	if len(response.Choices) > 0 {
+		choice := response.Choices[0]
+		if text := choice.Message.StringContent(); text != "" {
+			item := newMessageItem("completed")
+			item.Content = append(item.Content, newOutputText(text))
+			responsesResponse.Output = append(responsesResponse.Output, item)
+		}
+		for _, tool := range choice.Message.ToolCalls {
+			responsesResponse.Output = append(responsesResponse.Output, newFunctionCallItem(tool.Id, tool.Function.Name, conv.AsString(tool.Function.Arguments), "completed"))
+		}
+		finishReason = choice.FinishReason
+	}
+	responsesResponse.finish(finishReason, newResponsesUsage(&response.Usage))
+	return responsesResponse
+}
+
+// ResponsesOutputMessage converts the output of a converted response back to an assistant message,
+// so that it can be replayed when the response is referenced by previous_response_id
+func ResponsesOutputMessage(response *ResponsesResponse) model.Message {
+	message := model.Message{
+		Role:    "assistant",
+		Content: "",
+	}
+	var texts []string
+	for _, output := range response.Output {
+		switch item := output.(type) {
+		case *ResponsesMessageItem:
+			for _, content := range item.Content {
+				texts = append(texts, content.Text)
+			}
+		case *ResponsesFunctionCallItem:
+			message.ToolCalls = append(message.ToolCalls, model.Tool{
+				Id:   item.CallId,
+				Type: "function",
+				Function: model.Function{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			})
+		}
+	}
+	message.Content = strings.Join(texts, "")
+	return message
+}
+
+// ResponsesStreamConverter converts openai format stream chunks to responses api stream events
+type ResponsesStreamConverter struct {
+	request        *ResponsesRequest
+	promptTokens   int
+	response       *ResponsesResponse
+	sequenceNumber int
+	messageItem    *ResponsesMessageItem
+	functionItem   *ResponsesFunctionCallItem
+	text           string
+	finishReason   string
+	responseText   string
+	usage          *model.Usage
+	finished       bool
+}
+
+func NewResponsesStreamConverter(request *ResponsesRequest, promptTokens int) *ResponsesStreamConverter {
+	return &ResponsesStreamConverter{
+		request:      request,
+		promptTokens: promptTokens,
+	}
+}
+
+// Response returns the converted response once the relay is done, nil if it failed
+func (s *ResponsesStreamConverter) Response() *ResponsesResponse {
+	if !s.finished {
+		return nil
+	}
+	return s.response
+}
+
+func (s *ResponsesStreamConverter) event(eventType string, data map[string]any) string {
+	data["type"] = eventType
+	data["sequence_number"] = s.sequenceNumber
+	s.sequenceNumber++
+	jsonData, _ := json.Marshal(data)
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", eventType, jsonData)
+}
+
+func (s *ResponsesStreamConverter) start(modelName string) []string {
+	s.response = newResponsesResponse(s.request, modelName)
+	return []string{
+		s.event("response.created", map[string]any{"response": s.response}),
+		s.event("response.in_progress", map[string]any{"response": s.response}),
+	}
+}
+
+func (s *ResponsesStreamConverter) outputIndex() int {
+	return len(s.response.Output)
+}
+
+func (s *ResponsesStreamConverter) stopItem() []string {
+	var events []string
+	if s.messageItem != nil {
+		part := newOutputText(s.text)
+		events = append(events,
+			s.event("response.output_text.done", map[string]any{
+				"item_id":       s.messageItem.Id,
+				"output_index":  s.outputIndex(),
+				"content_index": 0,
+				"text":          s.text,
+			}),
+			s.event("response.content_part.done", map[string]any{
+				"item_id":       s.messageItem.Id,
+				"output_index":  s.outputIndex(),
+				"content_index": 0,
+				"part":          part,
+			}),
+		)
+		s.messageItem.Status = "completed"
+		s.messageItem.Content = append(s.messageItem.Content, part)
+		events = append(events, s.event("response.output_item.done", map[string]any{
+			"output_index": s.outputIndex(),
+			"item":         s.messageItem,
+		}))
+		s.response.Output = append(s.response.Output, s.messageItem)
+		s.messageItem = nil
+		s.text = ""
+	}
+	if s.functionItem != nil {
+		events = append(events, s.event("response.function_call_arguments.done", map[string]any{
+			"item_id":      s.functionItem.Id,
+			"output_index": s.outputIndex(),
+			"arguments":    s.functionItem.Arguments,
+		}))
+		s.functionItem.Status = "completed"
+		events = append(events, s.event("response.output_item.done", map[string]any{
+			"output_index": s.outputIndex(),
+			"item":         s.functionItem,
+		}))
+		s.response.Output = append(s.response.Output, s.functionItem)
+		s.functionItem = nil
+	}
+	return events
+}
+
+func (s *ResponsesStreamConverter) startMessage() []string {
+	events := s.stopItem()
+	s.messageItem = newMessageItem("in_progress")
+	return append(events,
+		s.event("response.output_item.added", map[string]any{
+			"output_index": s.outputIndex(),
+			"item":         s.messageItem,
+		}),
+		s.event("response.content_part.added", map[string]any{
+			"item_id":       s.messageItem.Id,
+			"output_index":  s.outputIndex(),
+			"content_index": 0,
+			"part":          newOutputText(""),
+		}),
+	)
+}
+
+func (s *ResponsesStreamConverter) startFunctionCall(callId string, name string) []string {
+	events := s.stopItem()
+	s.functionItem = newFunctionCallItem(callId, name, "", "in_progress")
+	return append(events, s.event("response.output_item.added", map[string]any{
+		"output_index": s.outputIndex(),
+		"item":         s.functionItem,
+	}))
+}
+
+// Convert converts the data of a single openai stream chunk
+func (s *ResponsesStreamConverter) Convert(data string) []string {
+	var streamResponse ChatCompletionsStreamResponse
+	if err := json.Unmarshal([]byte(data), &streamResponse); err != nil {
+		return nil
+	}
+	var events []string
+	if s.response == nil {
+		events = append(events, s.start(streamResponse.Model)...)
+	}
+	if streamResponse.Usage != nil {
+		s.usage = streamResponse.Usage
+	}
+	for _, choice := range streamResponse.Choices {
+		if text := conv.AsString(choice.Delta.Content); text != "" {
+			if s.messageItem == nil {
+				events = append(events, s.startMessage()...)
+			}
+			s.text += text
+			s.responseText += text
+			events = append(events, s.event("response.output_text.delta", map[string]any{
+				"item_id":       s.messageItem.Id,
+				"output_index":  s.outputIndex(),
+				"content_index": 0,
+				"delta":         text,
+			}))
+		}
+		for _, tool := range choice.Delta.ToolCalls {
+			if tool.Id != "" {
+				events = append(events, s.startFunctionCall(tool.Id, tool.Function.Name)...)
+			}
+			if arguments := conv.AsString(tool.Function.Arguments); arguments != "" && s.functionItem != nil {
+				s.functionItem.Arguments += arguments
+				s.responseText += arguments
+				events = append(events, s.event("response.function_call_arguments.delta", map[string]any{
+					"item_id":      s.functionItem.Id,
+					"output_index": s.outputIndex(),
+					"delta":        arguments,
+				}))
+			}
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// Finish completes the response once the openai stream is done
+func (s *ResponsesStreamConverter) Finish() []string {
+	var events []string
+	if s.response == nil {
+		events = append(events, s.start(s.request.Model)...)
+	}
+	events = append(events, s.stopItem()...)
+	var usage ResponsesUsage
+	if s.usage != nil {
+		usage = newResponsesUsage(s.usage)
+	} else {
+		usage.InputTokens = s.promptTokens
+		usage.OutputTokens = CountTokenText(s.responseText, s.request.Model)
+	}
+	s.response.finish(s.finishReason, usage)
+	s.finished = true
+	eventType := "response.completed"
+	if s.response.Status == "incomplete" {
+		eventType = "response.incomplete"
+	}
+	return append(events, s.event(eventType, map[string]any{"response": s.response}))
+}
+
+// ConvertTextResponse converts a whole chat completions response
+func (s *ResponsesStreamConverter) ConvertTextResponse(response *TextResponse) *ResponsesResponse {
+	s.response = ResponseText2Responses(response, s.request)
+	s.finished = true
+	return s.response
+}
+
+type responsesStreamEvent struct {
+	Type     string            `json:"type"`
+	Delta    string            `json:"delta"`
+	Response ResponsesResponse `json:"response"`
+}
+
+func (u *ResponsesUsage) toUsage() *model.Usage {
+	usage := &model.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+	if u.InputTokensDetails != nil {
+		usage.PromptTokensDetails = &model.PromptTokensDetails{
+			CachedTokens: u.InputTokensDetails.CachedTokens,
+		}
+	}
+	return usage
+}
+
+// ResponsesStreamHandler passes the native responses api stream through, returning the response id, the text streamed and the usage
+func ResponsesStreamHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, string, string, *model.Usage) {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	scanner.Split(bufio.ScanLines)
+	var responseId string
+	var responseText string
+	var usage *model.Usage
+
+	common.SetEventStreamHeaders(c)
+	for scanner.Scan() {
+		line := scanner.Text()
+		_, _ = c.Writer.WriteString(line + "\n")
+		if line == "" {
+			c.Writer.Flush()
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event responsesStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			logger.SysError("error unmarshalling stream response: " + err.Error())
+			continue
+		}
+		switch event.Type {
+		case "response.output_text.delta", "response.refusal.delta", "response.function_call_arguments.delta":
+			// the text is counted when the stream is interrupted before the usage is sent
+			responseText += event.Delta
+		}
+		if event.Response.Id != "" {
+			responseId = event.Response.Id
+		}
+		if event.Response.Usage != nil {
+			usage = event.Response.Usage.toUsage()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.SysError("error reading stream: " + err.Error())
+	}
+	c.Writer.Flush()
+
+	err := resp.Body.Close()
+	if err != nil {
+		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), responseId, responseText, usage
+	}
+	return nil, responseId, responseText, usage
+}
+
+// ResponsesHandler passes the native responses api response through, returning the response id and the usage
+func ResponsesHandler(c *gin.Context, resp *http.Response) (*model.ErrorWithStatusCode, string, *model.Usage) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError), "", nil
+	}
+	err = resp.Body.Close()
+	if err != nil {
+		return ErrorWrapper(err, "close_response_body_failed", http.StatusInternalServerError), "", nil
+	}
+	var response ResponsesResponse
+	err = json.Unmarshal(responseBody, &response)
+	if err != nil {
+		return ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError), "", nil
+	}
+	if response.Error != nil && response.Error.Message != "" {
+		return &model.ErrorWithStatusCode{
+			Error:      *response.Error,
+			StatusCode: resp.StatusCode,
+		}, "", nil
+	}
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err = c.Writer.Write(responseBody)
+	if err != nil {
+		return ErrorWrapper(err, "write_response_body_failed", http.StatusInternalServerError), "", nil
+	}
+	var usage *model.Usage
+	if response.Usage != nil {
+		usage = response.Usage.toUsage()
+	}
+	return nil, response.Id, usage
+}
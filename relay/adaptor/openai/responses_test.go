@@ -0,0 +1,106 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertResponsesRequest(t *testing.T) {
+	var request openai.ResponsesRequest
+	err := json.Unmarshal([]byte(`{
+		"model": "gpt-4o",
+		"instructions": "You are a weather bot.",
+		"max_output_tokens": 256,
+		"tools": [{"type": "function", "name": "get_weather", "parameters": {"type": "object"}}, {"type": "web_search_preview"}],
+		"tool_choice": {"type": "function", "name": "get_weather"},
+		"input": [
+			{"role": "user", "content": [{"type": "input_text", "text": "Weather in Paris and Rome?"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{\"city\":\"Paris\"}"},
+			{"type": "function_call", "call_id": "call_2", "name": "get_weather", "arguments": "{\"city\":\"Rome\"}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "Sunny"},
+			{"type": "function_call_output", "call_id": "call_2", "output": "Rainy"}
+		]
+	}`), &request)
+	assert.NoError(t, err)
+
+	textRequest := openai.ConvertResponsesRequest(&request, openai.ConvertResponsesInput(request.Input))
+	assert.Equal(t, 256, textRequest.MaxTokens)
+	assert.Len(t, textRequest.Tools, 1)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "get_weather"}}, textRequest.ToolChoice)
+
+	assert.Len(t, textRequest.Messages, 5)
+	assert.Equal(t, "system", textRequest.Messages[0].Role)
+	assert.Equal(t, "Weather in Paris and Rome?", textRequest.Messages[1].Content)
+	assert.Equal(t, "assistant", textRequest.Messages[2].Role)
+	assert.Len(t, textRequest.Messages[2].ToolCalls, 2)
+	assert.Equal(t, "tool", textRequest.Messages[4].Role)
+	assert.Equal(t, "call_2", textRequest.Messages[4].ToolCallId)
+}
+
+func TestResponsesStreamConverter(t *testing.T) {
+	request := &openai.ResponsesRequest{Model: "gpt-4o", Stream: true}
+	converter := openai.NewResponsesStreamConverter(request, 10)
+	var events []string
+	events = append(events, converter.Convert(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`)...)
+	events = append(events, converter.Convert(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"delta":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`)...)
+	events = append(events, converter.Finish()...)
+
+	var eventTypes []string
+	for _, event := range events {
+		eventTypes = append(eventTypes, strings.TrimPrefix(strings.SplitN(event, "\n", 2)[0], "event: "))
+	}
+	assert.Equal(t, []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.output_item.added", "response.function_call_arguments.delta",
+		"response.function_call_arguments.done", "response.output_item.done",
+		"response.completed",
+	}, eventTypes)
+	assert.Contains(t, events[12], `"output_tokens":5`)
+
+	response := converter.Response()
+	assert.Len(t, response.Output, 2)
+	message := openai.ResponsesOutputMessage(response)
+	assert.Equal(t, "Hi", message.Content)
+	assert.Equal(t, "call_1", message.ToolCalls[0].Id)
+}
+
+func TestResponsesStreamHandler(t *testing.T) {
+	stream := func(events ...string) *http.Response {
+		return &http.Response{Body: io.NopCloser(strings.NewReader(strings.Join(events, "\n\n") + "\n\n"))}
+	}
+	created := `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1"}}`
+	delta := `event: response.output_text.delta
+data: {"type":"response.output_text.delta","delta":"Hello"}`
+	arguments := `event: response.function_call_arguments.delta
+data: {"type":"response.function_call_arguments.delta","delta":"{}"}`
+	completed := `event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":3,"output_tokens":2,"total_tokens":5}}}`
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	err, responseId, responseText, usage := openai.ResponsesStreamHandler(c, stream(created, delta, arguments, completed))
+	assert.Nil(t, err)
+	assert.Equal(t, "resp_1", responseId)
+	assert.Equal(t, "Hello{}", responseText)
+	if assert.NotNil(t, usage) {
+		assert.Equal(t, 2, usage.CompletionTokens)
+	}
+
+	// the stream is interrupted before the usage is sent, the text is left to be counted
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	err, responseId, responseText, usage = openai.ResponsesStreamHandler(c, stream(created, delta))
+	assert.Nil(t, err)
+	assert.Equal(t, "resp_1", responseId)
+	assert.Equal(t, "Hello", responseText)
+	assert.Nil(t, usage)
+}
@@ -0,0 +1,29 @@
+package siliconflow
+
+import (
+	"github.com/songquanpeng/one-api/relay/model"
+)
+
+// https://docs.siliconflow.cn/api-reference/rerank/create-rerank
+
+type RerankTokens struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type RerankMeta struct {
+	Tokens *RerankTokens `json:"tokens,omitempty"`
+}
+
+type RerankResponse struct {
+	Id      string               `json:"id"`
+	Results []model.RerankResult `json:"results"`
+	Meta    RerankMeta           `json:"meta"`
+}
+
+func ResponseSiliconFlow2Rerank(response *RerankResponse) *model.RerankResponse {
+	return &model.RerankResponse{
+		Id:      response.Id,
+		Results: response.Results,
+	}
+}
@@ -40,7 +40,7 @@ func ConvertRequest(request model.GeneralOpenAIRequest) *ChatRequest {
 		Stream:      &request.Stream,
 		Messages:    messages,
 		TopP:        &request.TopP,
-		Temperature: &request.Temperature,
+		Temperature: request.Temperature,
 	}
 }
 
@@ -11,7 +11,7 @@ type Request struct {
 	MaxTokens     int                 `json:"max_tokens,omitempty"`
 	StopSequences []string            `json:"stop_sequences,omitempty"`
 	Stream        bool                `json:"stream,omitempty"`
-	Temperature   float64             `json:"temperature,omitempty"`
+	Temperature   *float64            `json:"temperature,omitempty"`
 	TopP          float64             `json:"top_p,omitempty"`
 	TopK          int                 `json:"top_k,omitempty"`
 	Tools         []anthropic.Tool    `json:"tools,omitempty"`
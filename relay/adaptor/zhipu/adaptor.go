@@ -70,8 +70,10 @@ func (a *Adaptor) ConvertRequest(c *gin.Context, relayMode int, request *model.G
 		request.TopP = math.Max(0.01, request.TopP)
 
 		// Temperature (0.0, 1.0)
-		request.Temperature = math.Min(0.99, request.Temperature)
-		request.Temperature = math.Max(0.01, request.Temperature)
+		if request.Temperature != nil {
+			temperature := math.Max(0.01, math.Min(0.99, *request.Temperature))
+			request.Temperature = &temperature
+		}
 		a.SetVersionByModeName(request.Model)
 		if a.APIVersion == "v4" {
 			return request, nil
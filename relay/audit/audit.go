@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the audit log keeps the full request and response bodies of the tokens and groups which opt in,
+// the bodies are redacted by the rules before they are truncated to the size cap and stored
+
+const defaultReplacement = "[REDACTED]"
+
+// RedactionRule replaces every match of the pattern, the replacement may refer to the groups of the pattern
+type RedactionRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"`
+	regexp      *regexp.Regexp
+}
+
+var (
+	redactionRules = mustCompileRules([]RedactionRule{
+		{Pattern: `sk-[A-Za-z0-9_-]{16,}`},
+		{Pattern: `(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{16,}`, Replacement: "${1}" + defaultReplacement},
+	})
+	redactionRulesLock sync.RWMutex
+)
+
+func compileRules(rules []RedactionRule) ([]RedactionRule, error) {
+	for i := range rules {
+		re, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", rules[i].Pattern, err)
+		}
+		rules[i].regexp = re
+		if rules[i].Replacement == "" {
+			rules[i].Replacement = defaultReplacement
+		}
+	}
+	return rules, nil
+}
+
+func mustCompileRules(rules []RedactionRule) []RedactionRule {
+	rules, err := compileRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func RedactionRules2JSONString() string {
+	redactionRulesLock.RLock()
+	defer redactionRulesLock.RUnlock()
+	jsonBytes, err := json.Marshal(redactionRules)
+	if err != nil {
+		logger.SysError("error marshalling redaction rules: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateRedactionRulesByJSONString(jsonStr string) error {
+	var newRedactionRules []RedactionRule
+	err := json.Unmarshal([]byte(jsonStr), &newRedactionRules)
+	if err != nil {
+		return err
+	}
+	newRedactionRules, err = compileRules(newRedactionRules)
+	if err != nil {
+		return err
+	}
+	redactionRulesLock.Lock()
+	redactionRules = newRedactionRules
+	redactionRulesLock.Unlock()
+	return nil
+}
+
+// ShouldAudit reports whether the requests of the token in the group are audited
+func ShouldAudit(group string, tokenAuditLog bool) bool {
+	if !config.AuditLogEnabled {
+		return false
+	}
+	if tokenAuditLog {
+		return true
+	}
+	for _, g := range strings.Split(config.AuditLogGroups, ",") {
+		if strings.TrimSpace(g) == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize redacts the body and truncates it to the size cap, the second return value reports whether it is truncated.
+// binary bodies such as audio are replaced by a placeholder
+func Sanitize(body []byte) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("[binary data of %d bytes]", len(body)), false
+	}
+	text := string(body)
+	redactionRulesLock.RLock()
+	rules := redactionRules
+	redactionRulesLock.RUnlock()
+	for _, rule := range rules {
+		text = rule.regexp.ReplaceAllString(text, rule.Replacement)
+	}
+	return truncate(text, config.AuditLogMaxBodySize)
+}
+
+func truncate(text string, limit int) (string, bool) {
+	if limit <= 0 || len(text) <= limit {
+		return text, false
+	}
+	// cut at the boundary of a rune, so that the stored text is still valid utf-8
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit], true
+}
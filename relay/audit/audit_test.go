@@ -0,0 +1,32 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	maxBodySize, rules := config.AuditLogMaxBodySize, audit.RedactionRules2JSONString()
+	t.Cleanup(func() {
+		config.AuditLogMaxBodySize = maxBodySize
+		assert.NoError(t, audit.UpdateRedactionRulesByJSONString(rules))
+	})
+	config.AuditLogMaxBodySize = 64
+	body, truncated := audit.Sanitize([]byte(`{"key":"sk-abcdefghijklmnopqrstuvwxyz","auth":"Bearer abcdefghijklmnopqrstuvwxyz"}`))
+	assert.False(t, truncated)
+	assert.Equal(t, `{"key":"[REDACTED]","auth":"Bearer [REDACTED]"}`, body)
+
+	assert.NoError(t, audit.UpdateRedactionRulesByJSONString(`[{"pattern":"\\d{11}","replacement":"***"}]`))
+	body, truncated = audit.Sanitize([]byte("call 13800138000 " + "你好你好你好你好你好你好你好你好你好你好你好你好你好你好你好"))
+	assert.True(t, truncated)
+	assert.Equal(t, "call *** 你好你好你好你好你好你好你好你好你好", body)
+
+	body, truncated = audit.Sanitize([]byte{0xff, 0xfe, 0x00})
+	assert.False(t, truncated)
+	assert.Equal(t, "[binary data of 3 bytes]", body)
+
+	assert.Error(t, audit.UpdateRedactionRulesByJSONString(`[{"pattern":"("}]`))
+}
@@ -0,0 +1,34 @@
+package ratio
+
+// AudioPromptRatio is the price of audio input tokens relative to text input tokens of the model
+// https://openai.com/api/pricing/
+var AudioPromptRatio = map[string]float64{
+	"gpt-4o-realtime-preview":                 8,          // $0.04 / 1K tokens
+	"gpt-4o-realtime-preview-2024-10-01":      20,         // $0.1 / 1K tokens
+	"gpt-4o-realtime-preview-2024-12-17":      8,          // $0.04 / 1K tokens
+	"gpt-4o-mini-realtime-preview":            10.0 / 0.6, // $0.01 / 1K tokens
+	"gpt-4o-mini-realtime-preview-2024-12-17": 10.0 / 0.6, // $0.01 / 1K tokens
+}
+
+// AudioCompletionRatio is the price of audio output tokens relative to audio input tokens of the model
+var AudioCompletionRatio = map[string]float64{
+	"gpt-4o-realtime-preview":                 2,
+	"gpt-4o-realtime-preview-2024-10-01":      2,
+	"gpt-4o-realtime-preview-2024-12-17":      2,
+	"gpt-4o-mini-realtime-preview":            2,
+	"gpt-4o-mini-realtime-preview-2024-12-17": 2,
+}
+
+func GetAudioPromptRatio(name string) float64 {
+	if ratio, ok := AudioPromptRatio[name]; ok {
+		return ratio
+	}
+	return 1
+}
+
+func GetAudioCompletionRatio(name string) float64 {
+	if ratio, ok := AudioCompletionRatio[name]; ok {
+		return ratio
+	}
+	return 2
+}
@@ -0,0 +1,80 @@
+package ratio
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// CacheReadRatio is the price of prompt tokens read from the cache relative to uncached prompt tokens of the model
+// https://openai.com/api/pricing/
+// https://www.anthropic.com/pricing#anthropic-api
+// https://api-docs.deepseek.com/quick_start/pricing
+var CacheReadRatio = map[string]float64{
+	"gpt-4o":                     0.5,
+	"gpt-4o-2024-08-06":          0.5,
+	"gpt-4o-mini":                0.5,
+	"gpt-4o-mini-2024-07-18":     0.5,
+	"claude-3-haiku-20240307":    0.1,
+	"claude-3-5-sonnet-20240620": 0.1,
+	"claude-3-opus-20240229":     0.1,
+	"deepseek-chat":              0.1,
+	"deepseek-coder":             0.1,
+}
+
+// CacheWriteRatio is the price of prompt tokens written to the cache relative to uncached prompt tokens of the model
+var CacheWriteRatio = map[string]float64{
+	"claude-3-haiku-20240307":    1.25,
+	"claude-3-5-sonnet-20240620": 1.25,
+	"claude-3-opus-20240229":     1.25,
+}
+
+func CacheReadRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(CacheReadRatio)
+	if err != nil {
+		logger.SysError("error marshalling cache read ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateCacheReadRatioByJSONString(jsonStr string) error {
+	CacheReadRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &CacheReadRatio)
+}
+
+func CacheWriteRatio2JSONString() string {
+	jsonBytes, err := json.Marshal(CacheWriteRatio)
+	if err != nil {
+		logger.SysError("error marshalling cache write ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateCacheWriteRatioByJSONString(jsonStr string) error {
+	CacheWriteRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &CacheWriteRatio)
+}
+
+func GetCacheReadRatio(name string) float64 {
+	if ratio, ok := CacheReadRatio[name]; ok {
+		return ratio
+	}
+	if strings.HasPrefix(name, "gpt-4o") || strings.HasPrefix(name, "o1") {
+		return 0.5
+	}
+	if strings.HasPrefix(name, "claude-") || strings.HasPrefix(name, "deepseek-") {
+		return 0.1
+	}
+	return 1
+}
+
+func GetCacheWriteRatio(name string) float64 {
+	if ratio, ok := CacheWriteRatio[name]; ok {
+		return ratio
+	}
+	if strings.HasPrefix(name, "claude-") {
+		return 1.25
+	}
+	return 1
+}
@@ -74,6 +74,12 @@ var ModelRatio = map[string]float64{
 	"text-moderation-latest":  0.1,
 	"dall-e-2":                0.02 * USD, // $0.016 - $0.020 / image
 	"dall-e-3":                0.04 * USD, // $0.040 - $0.120 / image
+	// https://openai.com/api/pricing/, the price of text tokens, see AudioPromptRatio for audio tokens
+	"gpt-4o-realtime-preview":                 2.5, // $0.005 / 1K tokens
+	"gpt-4o-realtime-preview-2024-10-01":      2.5, // $0.005 / 1K tokens
+	"gpt-4o-realtime-preview-2024-12-17":      2.5, // $0.005 / 1K tokens
+	"gpt-4o-mini-realtime-preview":            0.3, // $0.0006 / 1K tokens
+	"gpt-4o-mini-realtime-preview-2024-12-17": 0.3, // $0.0006 / 1K tokens
 	// https://www.anthropic.com/api#pricing
 	"claude-instant-1.2":         0.8 / 1000 * USD,
 	"claude-2.0":                 8.0 / 1000 * USD,
@@ -124,6 +130,7 @@ var ModelRatio = map[string]float64{
 	"ali-stable-diffusion-xl":   8,
 	"ali-stable-diffusion-v1.5": 8,
 	"wanx-v1":                   8,
+	"wanx2.1-imageedit":         0.14 * RMB,
 	"SparkDesk":                 1.2858, // ￥0.018 / 1k tokens
 	"SparkDesk-v1.1":            1.2858, // ￥0.018 / 1k tokens
 	"SparkDesk-v2.1":            1.2858, // ￥0.018 / 1k tokens
@@ -193,6 +200,22 @@ var ModelRatio = map[string]float64{
 	"command-light-nightly": 0.5,
 	"command-r":             0.5 / 1000 * USD,
 	"command-r-plus":        3.0 / 1000 * USD,
+	// cohere bills rerank per search, which is approximated by the tokens of the query and the documents
+	"rerank-v3.5":              0.1 / 1000 * USD,
+	"rerank-english-v3.0":      0.1 / 1000 * USD,
+	"rerank-multilingual-v3.0": 0.1 / 1000 * USD,
+	// https://jina.ai/reranker
+	"jina-reranker-v2-base-multilingual": 0.05 / 1000 * USD,
+	"jina-reranker-v1-base-en":           0.05 / 1000 * USD,
+	"jina-reranker-v1-turbo-en":          0.05 / 1000 * USD,
+	"jina-reranker-v1-tiny-en":           0.05 / 1000 * USD,
+	"jina-colbert-v2":                    0.05 / 1000 * USD,
+	"jina-embeddings-v3":                 0.05 / 1000 * USD,
+	"jina-embeddings-v2-base-en":         0.05 / 1000 * USD,
+	"jina-embeddings-v2-base-zh":         0.05 / 1000 * USD,
+	// https://siliconflow.cn/pricing
+	"BAAI/bge-reranker-v2-m3":             0,
+	"netease-youdao/bce-reranker-base_v1": 0,
 	// https://platform.deepseek.com/api-docs/pricing/
 	"deepseek-chat":  1.0 / 1000 * RMB,
 	"deepseek-coder": 1.0 / 1000 * RMB,
@@ -323,7 +346,7 @@ func GetCompletionRatio(name string, channelType int) float64 {
 		return 4.0 / 3.0
 	}
 	if strings.HasPrefix(name, "gpt-4") {
-		if strings.HasPrefix(name, "gpt-4o-mini") || name == "gpt-4o-2024-08-06" {
+		if strings.HasPrefix(name, "gpt-4o-mini") || name == "gpt-4o-2024-08-06" || strings.Contains(name, "realtime") {
 			return 4
 		}
 		if strings.HasPrefix(name, "gpt-4-turbo") ||
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the response cache is stored in redis when it is enabled, so that it is shared by all nodes,
+// otherwise every node keeps its own in-memory cache
+
+const keyPrefix = "response_cache:"
+
+var (
+	memoryCache     *LRU
+	memoryCacheOnce sync.Once
+)
+
+func getMemoryCache() *LRU {
+	memoryCacheOnce.Do(func() {
+		memoryCache = NewLRU(config.ResponseCacheSize)
+	})
+	return memoryCache
+}
+
+func Get(ctx context.Context, key string) (string, bool) {
+	if common.RedisEnabled {
+		value, err := common.RDB.Get(ctx, keyPrefix+key).Result()
+		if err != nil {
+			return "", false
+		}
+		return value, true
+	}
+	return getMemoryCache().Get(key)
+}
+
+func Set(ctx context.Context, key string, value string, ttl time.Duration) {
+	if common.RedisEnabled {
+		err := common.RDB.Set(ctx, keyPrefix+key, value, ttl).Err()
+		if err != nil {
+			logger.Errorf(ctx, "failed to set response cache: %s", err.Error())
+		}
+		return
+	}
+	getMemoryCache().Set(key, value, ttl)
+}
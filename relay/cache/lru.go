@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key      string
+	value    string
+	expireAt time.Time
+}
+
+// LRU is an in-memory cache which evicts the least recently used entry once it is full,
+// expired entries are removed when they are accessed
+type LRU struct {
+	mutex    sync.Mutex
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		list:     list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *LRU) Get(key string) (string, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	element, ok := l.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expireAt) {
+		l.list.Remove(element)
+		delete(l.items, key)
+		return "", false
+	}
+	l.list.MoveToFront(element)
+	return entry.value, true
+}
+
+func (l *LRU) Set(key string, value string, ttl time.Duration) {
+	if l.capacity <= 0 {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if element, ok := l.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expireAt = time.Now().Add(ttl)
+		l.list.MoveToFront(element)
+		return
+	}
+	l.items[key] = l.list.PushFront(&lruEntry{
+		key:      key,
+		value:    value,
+		expireAt: time.Now().Add(ttl),
+	})
+	for l.list.Len() > l.capacity {
+		oldest := l.list.Back()
+		l.list.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *LRU) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.list.Len()
+}
@@ -0,0 +1,29 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/relay/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	lru := cache.NewLRU(2)
+	lru.Set("a", "1", time.Minute)
+	lru.Set("b", "2", time.Minute)
+	// a becomes the most recently used entry, so b is evicted
+	_, ok := lru.Get("a")
+	assert.True(t, ok)
+	lru.Set("c", "3", time.Minute)
+	assert.Equal(t, 2, lru.Len())
+	_, ok = lru.Get("b")
+	assert.False(t, ok)
+	value, ok := lru.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "3", value)
+
+	lru.Set("d", "4", -time.Second)
+	_, ok = lru.Get("d")
+	assert.False(t, ok)
+}
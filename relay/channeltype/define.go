@@ -46,5 +46,6 @@ const (
 	VertextAI
 	Proxy
 	SiliconFlow
+	Jina
 	Dummy
 )
@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// RelayBatchHelper forwards batch api requests to the channel which stores the input file. On creation the models
+// of the input file are checked and an estimate is pre-consumed, the quota is settled by SettleBatch once the batch is finished
+func RelayBatchHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	if meta.APIType != apitype.OpenAI {
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support batch api", meta.ChannelType), "unsupported_channel_type", http.StatusBadRequest)
+	}
+	isCreate := c.Request.Method == http.MethodPost && c.Param("id") == ""
+	var preConsumedQuota int64
+	if isCreate {
+		var bizErr *relaymodel.ErrorWithStatusCode
+		preConsumedQuota, bizErr = preConsumeBatchQuota(c, meta)
+		if bizErr != nil {
+			return bizErr
+		}
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	resp, err := doRequest(c, meta, adaptor, bytes.NewReader(requestBody))
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return RelayErrorHandler(resp)
+	}
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	_ = resp.Body.Close()
+	var batch relaymodel.Batch
+	if err = json.Unmarshal(responseBody, &batch); err != nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if isCreate {
+		record := &model.Batch{
+			BatchId:      batch.Id,
+			UserId:       meta.UserId,
+			TokenId:      meta.TokenId,
+			TokenName:    meta.TokenName,
+			ChannelId:    meta.ChannelId,
+			Endpoint:     batch.Endpoint,
+			InputFileId:  batch.InputFileId,
+			OutputFileId: batch.OutputFileId,
+			Status:       batch.Status,
+			PreConsumed:  preConsumedQuota,
+			CreatedTime:  batch.CreatedAt,
+
+			OrganizationId: meta.OrganizationId,
+		}
+		if err = record.Insert(); err != nil {
+			logger.Errorf(ctx, "failed to record batch %s: %s", batch.Id, err.Error())
+			billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+			return openai.ErrorWrapper(err, "record_batch_failed", http.StatusInternalServerError)
+		}
+	} else {
+		record, err := model.GetBatchByBatchId(batch.Id, meta.UserId)
+		if err == nil && record.Status != batch.Status {
+			err = record.UpdateStatus(batch.Status, batch.OutputFileId)
+		}
+		if err != nil {
+			logger.Errorf(ctx, "failed to update batch %s: %s", batch.Id, err.Error())
+		}
+	}
+	c.Data(resp.StatusCode, "application/json", responseBody)
+	return nil
+}
+
+// preConsumeBatchQuota fetches the input file of the batch to be created, checks the model of every request and
+// pre-consumes the estimated quota of them
+func preConsumeBatchQuota(c *gin.Context, meta *meta.Meta) (int64, *relaymodel.ErrorWithStatusCode) {
+	ctx := c.Request.Context()
+	var batchRequest relaymodel.BatchRequest
+	if err := common.UnmarshalBodyReusable(c, &batchRequest); err != nil {
+		return 0, openai.ErrorWrapper(err, "invalid_batch_request", http.StatusBadRequest)
+	}
+	channel, err := model.GetChannelById(meta.ChannelId, true)
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "get_channel_failed", http.StatusInternalServerError)
+	}
+	content, err := getFromChannel(channel, relaymode.Files, "/v1/files/"+batchRequest.InputFileId+"/content")
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "get_input_file_failed", http.StatusInternalServerError)
+	}
+	groupModels, err := model.CacheGetGroupModels(ctx, meta.Group)
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "get_group_models_failed", http.StatusInternalServerError)
+	}
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	preConsumedQuota, err := getBatchPreConsumedQuota(content, c.GetString(ctxkey.AvailableModels), groupModels, meta.ChannelType, groupRatio)
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "model_not_available", http.StatusForbidden)
+	}
+	userQuota, err := model.CacheGetQuotaOf(ctx, meta.UserId, meta.OrganizationId)
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	if userQuota <= 0 || userQuota < preConsumedQuota {
+		return 0, openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+	if err = model.CacheDecreaseQuotaOf(meta.UserId, meta.OrganizationId, preConsumedQuota); err != nil {
+		return 0, openai.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
+	}
+	if err = model.PreConsumeTokenQuota(meta.TokenId, preConsumedQuota); err != nil {
+		return 0, openai.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
+	}
+	return preConsumedQuota, nil
+}
+
+// getBatchPreConsumedQuota checks the model of every request in the input file against the models the token and the
+// group may use, and estimates the quota of the requests the same way as the single requests are pre-consumed
+func getBatchPreConsumedQuota(content []byte, availableModels string, groupModels []string, channelType int, groupRatio float64) (int64, error) {
+	var quota int64
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var input relaymodel.BatchInput
+		if err := json.Unmarshal(line, &input); err != nil {
+			return 0, fmt.Errorf("invalid request at line %d of the input file: %w", i+1, err)
+		}
+		modelName := input.Body.Model
+		if availableModels != "" && !isModelInList(modelName, strings.Split(availableModels, ",")) {
+			return 0, fmt.Errorf("the token is not allowed to use model %s at line %d of the input file", modelName, i+1)
+		}
+		if !isModelInList(modelName, groupModels) {
+			return 0, fmt.Errorf("model %s at line %d of the input file is not available", modelName, i+1)
+		}
+		ratio := billingratio.GetModelRatio(modelName, channelType) * groupRatio * config.BatchDiscountRatio
+		promptTokens := getPromptTokens(&input.Body, relaymode.GetByPath(input.Url))
+		quota += getPreConsumedQuota(&input.Body, promptTokens, ratio)
+	}
+	return quota, nil
+}
+
+func isModelInList(modelName string, models []string) bool {
+	for _, m := range models {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// SettleBatch checks the upstream status of the batch,
+// and consumes the quota of every succeeded request in the output file once the batch is finished
+func SettleBatch(ctx context.Context, batch *model.Batch) error {
+	if batch.OrganizationId != 0 {
+		ctx = context.WithValue(ctx, helper.OrganizationIdKey, batch.OrganizationId)
+	}
+	channel, err := model.GetChannelById(batch.ChannelId, true)
+	if err != nil {
+		return err
+	}
+	responseBody, err := getFromChannel(channel, relaymode.Batches, "/v1/batches/"+batch.BatchId)
+	if err != nil {
+		return err
+	}
+	var upstreamBatch relaymodel.Batch
+	if err = json.Unmarshal(responseBody, &upstreamBatch); err != nil {
+		return err
+	}
+	if upstreamBatch.Status != batch.Status {
+		if err = batch.UpdateStatus(upstreamBatch.Status, upstreamBatch.OutputFileId); err != nil {
+			return err
+		}
+	}
+	if !relaymodel.IsBatchFinished(batch.Status) {
+		return nil
+	}
+
+	usages := make(map[string]*relaymodel.Usage)
+	if batch.OutputFileId != "" {
+		content, err := getFromChannel(channel, relaymode.Files, "/v1/files/"+batch.OutputFileId+"/content")
+		if err != nil {
+			return err
+		}
+		usages = getBatchUsages(content)
+	}
+	group := getUserGroup(batch.UserId)
+	groupRatio := billingratio.GetGroupRatio(group)
+	modelNames := make([]string, 0, len(usages))
+	for modelName := range usages {
+		modelNames = append(modelNames, modelName)
+	}
+	sort.Strings(modelNames)
+	quotas := make([]int64, len(modelNames))
+	var totalQuota int64
+	for i, modelName := range modelNames {
+		quotas[i] = getBatchQuota(usages[modelName], modelName, channel.Type, groupRatio)
+		totalQuota += quotas[i]
+	}
+	// the batch is charged and marked settled at once, a failed charge leaves it to be settled again
+	ok, err := batch.Settle(totalQuota)
+	if err != nil || !ok {
+		return err
+	}
+	err = model.CacheUpdateUserQuota(ctx, batch.UserId)
+	if err != nil {
+		logger.Error(ctx, "error update user quota cache: "+err.Error())
+	}
+	for i, modelName := range modelNames {
+		usage := usages[modelName]
+		modelRatio := billingratio.GetModelRatio(modelName, channel.Type)
+		completionRatio := billingratio.GetCompletionRatio(modelName, channel.Type)
+		logContent := fmt.Sprintf("批处理 %s，模型倍率 %.2f，分组倍率 %.2f，补全倍率 %.2f，批处理倍率 %.2f", batch.BatchId, modelRatio, groupRatio, completionRatio, config.BatchDiscountRatio)
+		model.RecordConsumeLog(ctx, batch.UserId, batch.ChannelId, usage.PromptTokens, usage.CompletionTokens, modelName, batch.TokenName, quotas[i], logContent)
+		metrics.RecordConsumption(batch.ChannelId, modelName, group, usage.PromptTokens, usage.CompletionTokens, quotas[i])
+	}
+	if totalQuota != 0 {
+		model.UpdateUsedQuotaAndRequestCountOf(batch.UserId, batch.OrganizationId, totalQuota)
+		model.UpdateChannelUsedQuota(batch.ChannelId, totalQuota)
+	}
+	return nil
+}
+
+// getBatchUsages sums up the usage of succeeded requests in the output file by model
+func getBatchUsages(content []byte) map[string]*relaymodel.Usage {
+	usages := make(map[string]*relaymodel.Usage)
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var output relaymodel.BatchOutput
+		if err := json.Unmarshal(line, &output); err != nil {
+			logger.SysError("error unmarshalling batch output: " + err.Error())
+			continue
+		}
+		if output.Response == nil || output.Response.StatusCode != http.StatusOK || output.Response.Body.Usage == nil {
+			continue
+		}
+		modelName := output.Response.Body.Model
+		if _, ok := usages[modelName]; !ok {
+			usages[modelName] = &relaymodel.Usage{}
+		}
+		usages[modelName].PromptTokens += output.Response.Body.Usage.PromptTokens
+		usages[modelName].CompletionTokens += output.Response.Body.Usage.CompletionTokens
+		usages[modelName].TotalTokens += output.Response.Body.Usage.TotalTokens
+	}
+	return usages
+}
+
+func getBatchQuota(usage *relaymodel.Usage, modelName string, channelType int, groupRatio float64) int64 {
+	modelRatio := billingratio.GetModelRatio(modelName, channelType)
+	completionRatio := billingratio.GetCompletionRatio(modelName, channelType)
+	ratio := modelRatio * groupRatio * config.BatchDiscountRatio
+	quota := int64(math.Ceil((float64(usage.PromptTokens) + float64(usage.CompletionTokens)*completionRatio) * ratio))
+	if ratio != 0 && quota <= 0 {
+		quota = 1
+	}
+	return quota
+}
+
+func getUserGroup(userId int) string {
+	group, err := model.CacheGetUserGroup(userId)
+	if err != nil {
+		logger.SysError("error getting user group: " + err.Error())
+		return "default"
+	}
+	return group
+}
+
+// getFromChannel sends a GET request to the openai compatible channel outside of a relay request
+func getFromChannel(channel *model.Channel, relayMode int, requestURLPath string) ([]byte, error) {
+	cfg, _ := channel.LoadConfig()
+	if channel.Type == channeltype.Azure && cfg.APIVersion == "" && channel.Other != nil {
+		cfg.APIVersion = *channel.Other
+	}
+	meta := &meta.Meta{
+		Mode:           relayMode,
+		ChannelType:    channel.Type,
+		ChannelId:      channel.Id,
+		BaseURL:        channel.GetBaseURL(),
+		APIKey:         channel.Key,
+		APIType:        channeltype.ToAPIType(channel.Type),
+		Config:         cfg,
+		RequestURLPath: requestURLPath,
+	}
+	if meta.BaseURL == "" {
+		meta.BaseURL = channeltype.ChannelBaseURLs[channel.Type]
+	}
+	adaptor := &openai.Adaptor{}
+	adaptor.Init(meta)
+	fullRequestURL, err := adaptor.GetRequestURL(meta)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, fullRequestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if channel.Type == channeltype.Azure {
+		req.Header.Set("api-key", meta.APIKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+meta.APIKey)
+	}
+	resp, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
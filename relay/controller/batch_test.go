@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/client"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const batchOutput = `{"id":"r1","custom_id":"1","response":{"status_code":200,"body":{"model":"gpt-4o-mini","usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}}}
+{"id":"r2","custom_id":"2","response":{"status_code":200,"body":{"model":"gpt-4o-mini","usage":{"prompt_tokens":20,"completion_tokens":10,"total_tokens":30}}}}
+{"id":"r3","custom_id":"3","response":{"status_code":500,"body":{"model":"gpt-4o-mini","usage":{"prompt_tokens":99,"completion_tokens":99,"total_tokens":198}}}}
+not json
+{"id":"r4","custom_id":"4","response":{"status_code":200,"body":{"model":"text-embedding-3-small","usage":{"prompt_tokens":7,"total_tokens":7}}}}
+`
+
+func TestGetBatchUsages(t *testing.T) {
+	usages := getBatchUsages([]byte(batchOutput))
+	require.Len(t, usages, 2)
+	assert.Equal(t, 30, usages["gpt-4o-mini"].PromptTokens)
+	assert.Equal(t, 15, usages["gpt-4o-mini"].CompletionTokens)
+	assert.Equal(t, 45, usages["gpt-4o-mini"].TotalTokens)
+	assert.Equal(t, 7, usages["text-embedding-3-small"].PromptTokens)
+	assert.Empty(t, getBatchUsages(nil))
+}
+
+func TestGetBatchPreConsumedQuota(t *testing.T) {
+	approximate := config.ApproximateTokenEnabled
+	config.ApproximateTokenEnabled = true
+	t.Cleanup(func() { config.ApproximateTokenEnabled = approximate })
+	input := []byte(`{"custom_id":"1","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}}
+{"custom_id":"2","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}}
+`)
+	groupModels := []string{"gpt-4o-mini", "gpt-4o"}
+
+	quota, err := getBatchPreConsumedQuota(input, "", groupModels, channeltype.OpenAI, 1)
+	assert.NoError(t, err)
+	assert.Greater(t, quota, int64(0))
+
+	_, err = getBatchPreConsumedQuota(input, "gpt-4o-mini", groupModels, channeltype.OpenAI, 1)
+	assert.ErrorContains(t, err, "gpt-4o at line 2")
+
+	_, err = getBatchPreConsumedQuota(input, "", []string{"gpt-4o-mini"}, channeltype.OpenAI, 1)
+	assert.ErrorContains(t, err, "gpt-4o at line 2")
+
+	_, err = getBatchPreConsumedQuota([]byte("not json"), "", groupModels, channeltype.OpenAI, 1)
+	assert.Error(t, err)
+}
+
+// setupTestDB opens an in-memory database for the test, it is left in place afterwards, and redis is
+// left disabled, as the quota updates run in the background may still be using them
+func setupTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&model.User{}, &model.Token{}, &model.Channel{}, &model.Batch{}, &model.Log{}, &model.Budget{},
+		&model.Organization{}, &model.OrganizationMember{}))
+	model.DB, model.LOG_DB = db, db
+	common.RedisEnabled = false
+	usingSQLite := common.UsingSQLite
+	common.UsingSQLite = true
+	t.Cleanup(func() {
+		common.UsingSQLite = usingSQLite
+	})
+}
+
+// setupBatchChannel creates the channel of the batches, whose upstream reports the batch in the status
+func setupBatchChannel(t *testing.T, status *string) {
+	client.Init()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/batches/batch_1":
+			_, _ = fmt.Fprintf(w, `{"id":"batch_1","status":%q,"output_file_id":"file_out"}`, *status)
+		case "/v1/files/file_out/content":
+			_, _ = w.Write([]byte(batchOutput))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	baseURL := server.URL
+	require.NoError(t, model.DB.Create(&model.Channel{Id: 1, Type: channeltype.OpenAI, Key: "sk-test", Status: model.ChannelStatusEnabled, BaseURL: &baseURL}).Error)
+}
+
+func TestSettleBatch(t *testing.T) {
+	setupTestDB(t)
+	status := "in_progress"
+	setupBatchChannel(t, &status)
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "batch", Quota: 100000, Group: "default", Status: model.UserStatusEnabled}).Error)
+	require.NoError(t, model.DB.Create(&model.Token{Id: 1, UserId: 1, Key: "batch-token", Name: "t", RemainQuota: 100000, Status: model.TokenStatusEnabled}).Error)
+	batch := &model.Batch{BatchId: "batch_1", UserId: 1, TokenId: 1, ChannelId: 1, Status: "validating", PreConsumed: 1000}
+	require.NoError(t, batch.Insert())
+
+	// an unfinished batch is only updated
+	require.NoError(t, SettleBatch(context.Background(), batch))
+	assert.False(t, batch.Settled)
+	assert.Equal(t, "in_progress", batch.Status)
+
+	status = "completed"
+	require.NoError(t, SettleBatch(context.Background(), batch))
+	require.True(t, batch.Settled)
+	usages := getBatchUsages([]byte(batchOutput))
+	var expected int64
+	for modelName, usage := range usages {
+		expected += getBatchQuota(usage, modelName, channeltype.OpenAI, 1)
+	}
+	assert.Equal(t, expected, batch.Quota)
+
+	// only the part beyond the pre-consumed estimate is charged
+	user, err := model.GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000)-(expected-1000), user.Quota)
+	token, err := model.GetTokenById(1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000)-(expected-1000), token.RemainQuota)
+
+	// a settled batch is not charged again
+	stale := &model.Batch{Id: batch.Id, BatchId: "batch_1", UserId: 1, TokenId: 1, ChannelId: 1, Status: "completed", PreConsumed: 1000}
+	require.NoError(t, SettleBatch(context.Background(), stale))
+	user, err = model.GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000)-(expected-1000), user.Quota)
+
+	var logs int64
+	model.DB.Model(&model.Log{}).Where("type = ?", model.LogTypeConsume).Count(&logs)
+	assert.Equal(t, int64(2), logs)
+}
+
+func TestSettleOrganizationBatch(t *testing.T) {
+	setupTestDB(t)
+	status := "completed"
+	setupBatchChannel(t, &status)
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "member", Quota: 100, Group: "default", Status: model.UserStatusEnabled}).Error)
+	organization := &model.Organization{Name: "acme", Status: model.OrganizationStatusEnabled, Quota: 100000}
+	require.NoError(t, model.CreateOrganization(organization, 1))
+	require.NoError(t, model.DB.Create(&model.Token{Id: 1, UserId: 1, Key: "organization-token", Name: "t", UnlimitedQuota: true, Status: model.TokenStatusEnabled, OrganizationId: organization.Id}).Error)
+	batch := &model.Batch{BatchId: "batch_1", UserId: 1, TokenId: 1, ChannelId: 1, Status: "in_progress", OrganizationId: organization.Id}
+	require.NoError(t, batch.Insert())
+
+	require.NoError(t, SettleBatch(context.Background(), batch))
+	require.True(t, batch.Settled)
+	// the batch is charged to the organization, the user is left untouched
+	organization, err := model.GetOrganizationById(organization.Id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100000)-batch.Quota, organization.Quota)
+	user, err := model.GetUserById(1, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), user.Quota)
+	assert.Zero(t, user.UsedQuota)
+
+	var logs []*model.Log
+	require.NoError(t, model.DB.Where("type = ?", model.LogTypeConsume).Find(&logs).Error)
+	require.NotEmpty(t, logs)
+	for _, log := range logs {
+		assert.Equal(t, organization.Id, log.OrganizationId)
+	}
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/common/conv"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/render"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/cache"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// cachedResponse is the non-stream openai format response, stream responses are merged before being cached
+type cachedResponse struct {
+	Body  json.RawMessage  `json:"body"`
+	Usage relaymodel.Usage `json:"usage"`
+}
+
+func isResponseCacheModel(modelName string) bool {
+	if config.ResponseCacheModels == "" {
+		return true
+	}
+	for _, m := range strings.Split(config.ResponseCacheModels, ",") {
+		if strings.TrimSpace(m) == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// getResponseCacheKey returns the cache key of the request, the second return value reports whether it is cacheable,
+// only embeddings and deterministic chat completions are cached
+func getResponseCacheKey(meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest) (string, bool) {
+	if !config.ResponseCacheEnabled || !meta.ResponseCache || !isResponseCacheModel(meta.OriginModelName) {
+		return "", false
+	}
+	switch meta.Mode {
+	case relaymode.Embeddings:
+	case relaymode.ChatCompletions:
+		// the temperature defaults to 1 when it is omitted
+		if textRequest.Temperature == nil || *textRequest.Temperature != 0 || textRequest.N > 1 {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	// the same response can be served to stream and non-stream requests of any user in the group
+	normalizedRequest := *textRequest
+	normalizedRequest.Stream = false
+	normalizedRequest.StreamOptions = nil
+	normalizedRequest.User = ""
+	jsonData, err := json.Marshal(normalizedRequest)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", meta.Mode, meta.Group, jsonData)))
+	return hex.EncodeToString(hash[:]), true
+}
+
+func getCachedResponse(ctx context.Context, key string) (*cachedResponse, bool) {
+	value, ok := cache.Get(ctx, key)
+	if !ok {
+		return nil, false
+	}
+	var response cachedResponse
+	if err := json.Unmarshal([]byte(value), &response); err != nil {
+		logger.Errorf(ctx, "failed to unmarshal cached response: %s", err.Error())
+		return nil, false
+	}
+	return &response, true
+}
+
+// responseCacheWriter keeps a copy of everything written to the client, so that the response can be cached once it succeeds
+type responseCacheWriter struct {
+	gin.ResponseWriter
+	buffer bytes.Buffer
+}
+
+func newResponseCacheWriter(writer gin.ResponseWriter) *responseCacheWriter {
+	return &responseCacheWriter{
+		ResponseWriter: writer,
+	}
+}
+
+func (w *responseCacheWriter) Write(data []byte) (int, error) {
+	w.buffer.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCacheWriter) WriteString(s string) (int, error) {
+	w.buffer.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// mergeStreamResponse merges the chunks of a chat completions stream into a non-stream response,
+// it fails when the stream is not finished
+func mergeStreamResponse(data []byte) (*openai.TextResponse, bool) {
+	textResponse := &openai.TextResponse{
+		Object: "chat.completion",
+	}
+	message := relaymodel.Message{
+		Role: "assistant",
+	}
+	var content strings.Builder
+	finishReason := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasPrefix(line, "data: ") || strings.HasPrefix(line, "data: [DONE]") {
+			continue
+		}
+		var streamResponse openai.ChatCompletionsStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &streamResponse); err != nil {
+			return nil, false
+		}
+		textResponse.Id = streamResponse.Id
+		textResponse.Model = streamResponse.Model
+		textResponse.Created = streamResponse.Created
+		if streamResponse.Usage != nil {
+			textResponse.Usage = *streamResponse.Usage
+		}
+		for _, choice := range streamResponse.Choices {
+			content.WriteString(conv.AsString(choice.Delta.Content))
+			for _, tool := range choice.Delta.ToolCalls {
+				// the following deltas of a tool call only carry the arguments
+				if tool.Id != "" || len(message.ToolCalls) == 0 {
+					message.ToolCalls = append(message.ToolCalls, tool)
+					continue
+				}
+				last := &message.ToolCalls[len(message.ToolCalls)-1]
+				last.Function.Arguments = conv.AsString(last.Function.Arguments) + conv.AsString(tool.Function.Arguments)
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if finishReason == "" {
+		return nil, false
+	}
+	message.Content = content.String()
+	textResponse.Choices = []openai.TextResponseChoice{
+		{
+			Message:      message,
+			FinishReason: finishReason,
+		},
+	}
+	return textResponse, true
+}
+
+// MergeStreamResponse reassembles a chat completions stream into the json of a non-stream response
+func MergeStreamResponse(data []byte) ([]byte, bool) {
+	textResponse, ok := mergeStreamResponse(data)
+	if !ok {
+		return nil, false
+	}
+	jsonResponse, err := json.Marshal(textResponse)
+	if err != nil {
+		return nil, false
+	}
+	return jsonResponse, true
+}
+
+func cacheResponse(ctx context.Context, key string, meta *meta.Meta, writer *responseCacheWriter, usage *relaymodel.Usage) {
+	if writer.Status() != http.StatusOK || usage == nil {
+		return
+	}
+	body := writer.buffer.Bytes()
+	if meta.IsStream {
+		textResponse, ok := mergeStreamResponse(body)
+		if !ok {
+			return
+		}
+		textResponse.Usage = *usage
+		jsonResponse, err := json.Marshal(textResponse)
+		if err != nil {
+			return
+		}
+		body = jsonResponse
+	}
+	if !json.Valid(body) {
+		return
+	}
+	jsonData, err := json.Marshal(cachedResponse{
+		Body:  body,
+		Usage: *usage,
+	})
+	if err != nil {
+		logger.Errorf(ctx, "failed to marshal cached response: %s", err.Error())
+		return
+	}
+	cache.Set(ctx, key, string(jsonData), time.Duration(config.ResponseCacheTTL)*time.Second)
+}
+
+// replayCachedResponse writes the cached response to the client, the chat completions are replayed as a stream if requested,
+// followed by the usage chunk when the stream options ask for it
+func replayCachedResponse(c *gin.Context, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, response *cachedResponse) error {
+	if !meta.IsStream {
+		c.Data(http.StatusOK, "application/json", response.Body)
+		return nil
+	}
+	var textResponse openai.TextResponse
+	if err := json.Unmarshal(response.Body, &textResponse); err != nil {
+		return err
+	}
+	common.SetEventStreamHeaders(c)
+	for _, choice := range textResponse.Choices {
+		finishReason := choice.FinishReason
+		streamResponse := openai.ChatCompletionsStreamResponse{
+			Id:      textResponse.Id,
+			Object:  "chat.completion.chunk",
+			Created: helper.GetTimestamp(),
+			Model:   textResponse.Model,
+			Choices: []openai.ChatCompletionsStreamResponseChoice{
+				{
+					Index:        choice.Index,
+					Delta:        choice.Message,
+					FinishReason: &finishReason,
+				},
+			},
+		}
+		if err := render.ObjectData(c, streamResponse); err != nil {
+			return err
+		}
+	}
+	if textRequest.StreamOptions != nil && textRequest.StreamOptions.IncludeUsage {
+		usage := response.Usage
+		streamResponse := openai.ChatCompletionsStreamResponse{
+			Id:      textResponse.Id,
+			Object:  "chat.completion.chunk",
+			Created: helper.GetTimestamp(),
+			Model:   textResponse.Model,
+			Choices: []openai.ChatCompletionsStreamResponseChoice{},
+			Usage:   &usage,
+		}
+		if err := render.ObjectData(c, streamResponse); err != nil {
+			return err
+		}
+	}
+	render.Done(c)
+	return nil
+}
+
+// consumeCachedQuota consumes the discounted quota of a cache hit, the pre-consumed quota is settled as well
+func consumeCachedQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64) {
+	completionRatio := billingratio.GetCompletionRatio(textRequest.Model, meta.ChannelType)
+	promptTokens := getWeightedPromptTokens(usage, billingratio.GetCacheReadRatio(textRequest.Model), billingratio.GetCacheWriteRatio(textRequest.Model))
+	quota := int64(math.Ceil((promptTokens + float64(usage.CompletionTokens)*completionRatio) * ratio * config.ResponseCacheBillingRatio))
+	err := model.PostConsumeTokenQuota(meta.TokenId, quota-preConsumedQuota)
+	if err != nil {
+		logger.Error(ctx, "error consuming token remain quota: "+err.Error())
+	}
+	err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+	if err != nil {
+		logger.Error(ctx, "error update user quota cache: "+err.Error())
+	}
+	reconcileRateLimit(ctx, meta, usage)
+	logContent := fmt.Sprintf("缓存命中，模型倍率 %.2f，分组倍率 %.2f，补全倍率 %.2f，缓存倍率 %.2f", modelRatio, groupRatio, completionRatio, config.ResponseCacheBillingRatio)
+	model.RecordCacheHitLog(ctx, meta.UserId, usage.PromptTokens, usage.CompletionTokens, textRequest.Model, meta.TokenName, quota, logContent)
+	model.UpdateUsedQuotaAndRequestCountOf(meta.UserId, meta.OrganizationId, quota)
+	metrics.RecordConsumption(meta.ChannelId, meta.OriginModelName, meta.Group, usage.PromptTokens, usage.CompletionTokens, quota)
+}
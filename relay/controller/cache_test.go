@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/config"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetResponseCacheKey(t *testing.T) {
+	enabled := config.ResponseCacheEnabled
+	config.ResponseCacheEnabled = true
+	t.Cleanup(func() { config.ResponseCacheEnabled = enabled })
+	meta := &meta.Meta{Mode: relaymode.ChatCompletions, Group: "default", OriginModelName: "gpt-4o-mini", ResponseCache: true}
+	zero := 0.0
+	newRequest := func() *relaymodel.GeneralOpenAIRequest {
+		return &relaymodel.GeneralOpenAIRequest{
+			Model:       "gpt-4o-mini",
+			Temperature: &zero,
+			Messages:    []relaymodel.Message{{Role: "user", Content: "hi"}},
+		}
+	}
+
+	key, ok := getResponseCacheKey(meta, newRequest())
+	require.True(t, ok)
+
+	// the stream related fields and the user do not change the key
+	request := newRequest()
+	request.Stream = true
+	request.StreamOptions = &relaymodel.StreamOptions{IncludeUsage: true}
+	request.User = "someone"
+	streamKey, ok := getResponseCacheKey(meta, request)
+	require.True(t, ok)
+	assert.Equal(t, key, streamKey)
+	assert.True(t, request.StreamOptions.IncludeUsage)
+
+	request = newRequest()
+	request.MaxTokens = 10
+	otherKey, ok := getResponseCacheKey(meta, request)
+	require.True(t, ok)
+	assert.NotEqual(t, key, otherKey)
+
+	// requests which are not deterministic are not cached
+	request = newRequest()
+	request.Temperature = nil
+	_, ok = getResponseCacheKey(meta, request)
+	assert.False(t, ok)
+}
+
+func TestReplayCachedResponse(t *testing.T) {
+	response := &cachedResponse{
+		Body:  []byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`),
+		Usage: relaymodel.Usage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4},
+	}
+	replay := func(request *relaymodel.GeneralOpenAIRequest) string {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		require.NoError(t, replayCachedResponse(c, &meta.Meta{IsStream: true}, request, response))
+		return recorder.Body.String()
+	}
+
+	body := replay(&relaymodel.GeneralOpenAIRequest{Stream: true})
+	assert.Contains(t, body, `"content":"Hello"`)
+	assert.NotContains(t, body, `"usage"`)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(body), "data: [DONE]"))
+
+	body = replay(&relaymodel.GeneralOpenAIRequest{Stream: true, StreamOptions: &relaymodel.StreamOptions{IncludeUsage: true}})
+	assert.Contains(t, body, `"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}`)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(body), "data: [DONE]"))
+
+	// the merged stream carries the same content
+	textResponse, ok := mergeStreamResponse([]byte(body))
+	require.True(t, ok)
+	assert.Equal(t, "Hello", textResponse.Choices[0].Message.StringContent())
+	assert.Equal(t, 4, textResponse.Usage.TotalTokens)
+}
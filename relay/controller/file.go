@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// RelayFileHelper forwards files api requests to the channel selected by the distributor,
+// uploaded files are recorded so that later requests can be routed back to the same channel
+func RelayFileHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	if meta.APIType != apitype.OpenAI {
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support files api", meta.ChannelType), "unsupported_channel_type", http.StatusBadRequest)
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
+	}
+	resp, err := doRequest(c, meta, adaptor, bytes.NewReader(requestBody))
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		return RelayErrorHandler(resp)
+	}
+
+	switch c.Request.Method {
+	case http.MethodPost:
+		return handleFileUploadResponse(c, resp, meta)
+	case http.MethodDelete:
+		return handleFileDeleteResponse(c, resp, meta)
+	default:
+		return passThroughResponse(c, resp)
+	}
+}
+
+func handleFileUploadResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) *relaymodel.ErrorWithStatusCode {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	_ = resp.Body.Close()
+	var file relaymodel.File
+	if err = json.Unmarshal(responseBody, &file); err != nil {
+		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	record := &model.File{
+		FileId:      file.Id,
+		UserId:      meta.UserId,
+		TokenId:     meta.TokenId,
+		ChannelId:   meta.ChannelId,
+		Filename:    file.Filename,
+		Purpose:     file.Purpose,
+		Bytes:       file.Bytes,
+		CreatedTime: file.CreatedAt,
+	}
+	if err = record.Insert(); err != nil {
+		logger.Errorf(c.Request.Context(), "failed to record file %s: %s", file.Id, err.Error())
+		return openai.ErrorWrapper(err, "record_file_failed", http.StatusInternalServerError)
+	}
+	c.Data(resp.StatusCode, "application/json", responseBody)
+	return nil
+}
+
+func handleFileDeleteResponse(c *gin.Context, resp *http.Response, meta *meta.Meta) *relaymodel.ErrorWithStatusCode {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return openai.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	_ = resp.Body.Close()
+	var deleted relaymodel.FileDeleted
+	if err = json.Unmarshal(responseBody, &deleted); err != nil {
+		return openai.ErrorWrapper(err, "unmarshal_response_body_failed", http.StatusInternalServerError)
+	}
+	if deleted.Deleted {
+		file, err := model.GetFileByFileId(deleted.Id, meta.UserId)
+		if err == nil {
+			err = file.Delete()
+		}
+		if err != nil {
+			logger.Errorf(c.Request.Context(), "failed to delete file record %s: %s", deleted.Id, err.Error())
+		}
+	}
+	c.Data(resp.StatusCode, "application/json", responseBody)
+	return nil
+}
+
+func passThroughResponse(c *gin.Context, resp *http.Response) *relaymodel.ErrorWithStatusCode {
+	for k, v := range resp.Header {
+		c.Writer.Header().Set(k, v[0])
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+	_, err := io.Copy(c.Writer, resp.Body)
+	if err != nil {
+		return openai.ErrorWrapper(err, "copy_response_body_failed", http.StatusInternalServerError)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
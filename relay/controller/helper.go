@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"strings"
@@ -12,7 +13,10 @@ import (
 	"github.com/songquanpeng/one-api/common"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/songquanpeng/one-api/relay/adaptor"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
@@ -20,8 +24,33 @@ import (
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
 	"github.com/songquanpeng/one-api/relay/relaymode"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// doRequest sends the request to the upstream within the stage span of the relay
+func doRequest(c *gin.Context, meta *meta.Meta, adaptor adaptor.Adaptor, requestBody io.Reader) (*http.Response, error) {
+	span, endSpan := tracing.StartStage(c, "adaptor.DoRequest")
+	defer endSpan()
+	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	tracing.RecordError(span, err)
+	return resp, err
+}
+
+// doResponse relays the response of the upstream within the stage span of the relay
+func doResponse(c *gin.Context, meta *meta.Meta, adaptor adaptor.Adaptor, resp *http.Response) (*relaymodel.Usage, *relaymodel.ErrorWithStatusCode) {
+	span, endSpan := tracing.StartStage(c, "adaptor.DoResponse", attribute.Bool("stream", meta.IsStream))
+	defer endSpan()
+	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	if respErr != nil {
+		span.SetStatus(codes.Error, respErr.Message)
+	}
+	if usage != nil {
+		span.SetAttributes(attribute.Int("tokens.prompt", usage.PromptTokens), attribute.Int("tokens.completion", usage.CompletionTokens))
+	}
+	return usage, respErr
+}
+
 func getAndValidateTextRequest(c *gin.Context, relayMode int) (*relaymodel.GeneralOpenAIRequest, error) {
 	textRequest := &relaymodel.GeneralOpenAIRequest{}
 	err := common.UnmarshalBodyReusable(c, textRequest)
@@ -64,14 +93,14 @@ func getPreConsumedQuota(textRequest *relaymodel.GeneralOpenAIRequest, promptTok
 func preConsumeQuota(ctx context.Context, textRequest *relaymodel.GeneralOpenAIRequest, promptTokens int, ratio float64, meta *meta.Meta) (int64, *relaymodel.ErrorWithStatusCode) {
 	preConsumedQuota := getPreConsumedQuota(textRequest, promptTokens, ratio)
 
-	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
+	userQuota, err := model.CacheGetQuotaOf(ctx, meta.UserId, meta.OrganizationId)
 	if err != nil {
 		return preConsumedQuota, openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
 	}
 	if userQuota-preConsumedQuota < 0 {
 		return preConsumedQuota, openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
 	}
-	err = model.CacheDecreaseUserQuota(meta.UserId, preConsumedQuota)
+	err = model.CacheDecreaseQuotaOf(meta.UserId, meta.OrganizationId, preConsumedQuota)
 	if err != nil {
 		return preConsumedQuota, openai.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 	}
@@ -90,16 +119,28 @@ func preConsumeQuota(ctx context.Context, textRequest *relaymodel.GeneralOpenAIR
 	return preConsumedQuota, nil
 }
 
+// getWeightedPromptTokens weights the prompt tokens read from and written to the prompt cache by their ratios
+func getWeightedPromptTokens(usage *relaymodel.Usage, cacheReadRatio float64, cacheWriteRatio float64) float64 {
+	cachedTokens := usage.CachedTokens()
+	cacheCreationTokens := usage.CacheCreationTokens()
+	uncachedTokens := usage.PromptTokens - cachedTokens - cacheCreationTokens
+	return float64(uncachedTokens) + float64(cachedTokens)*cacheReadRatio + float64(cacheCreationTokens)*cacheWriteRatio
+}
+
 func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.Meta, textRequest *relaymodel.GeneralOpenAIRequest, ratio float64, preConsumedQuota int64, modelRatio float64, groupRatio float64) {
+	_, span := tracing.Start(ctx, "postConsumeQuota")
+	defer span.End()
 	if usage == nil {
 		logger.Error(ctx, "usage is nil, which is unexpected")
 		return
 	}
 	var quota int64
 	completionRatio := billingratio.GetCompletionRatio(textRequest.Model, meta.ChannelType)
+	cacheReadRatio := billingratio.GetCacheReadRatio(textRequest.Model)
+	cacheWriteRatio := billingratio.GetCacheWriteRatio(textRequest.Model)
 	promptTokens := usage.PromptTokens
 	completionTokens := usage.CompletionTokens
-	quota = int64(math.Ceil((float64(promptTokens) + float64(completionTokens)*completionRatio) * ratio))
+	quota = int64(math.Ceil((getWeightedPromptTokens(usage, cacheReadRatio, cacheWriteRatio) + float64(completionTokens)*completionRatio) * ratio))
 	if ratio != 0 && quota <= 0 {
 		quota = 1
 	}
@@ -109,6 +150,11 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 		// we cannot just return, because we may have to return the pre-consumed quota
 		quota = 0
 	}
+	span.SetAttributes(
+		attribute.Int("tokens.prompt", promptTokens),
+		attribute.Int("tokens.completion", completionTokens),
+		attribute.Int64("quota", quota),
+	)
 	quotaDelta := quota - preConsumedQuota
 	err := model.PostConsumeTokenQuota(meta.TokenId, quotaDelta)
 	if err != nil {
@@ -118,10 +164,17 @@ func postConsumeQuota(ctx context.Context, usage *relaymodel.Usage, meta *meta.M
 	if err != nil {
 		logger.Error(ctx, "error update user quota cache: "+err.Error())
 	}
+	reconcileRateLimit(ctx, meta, usage)
 	logContent := fmt.Sprintf("模型倍率 %.2f，分组倍率 %.2f，补全倍率 %.2f", modelRatio, groupRatio, completionRatio)
-	model.RecordConsumeLog(ctx, meta.UserId, meta.ChannelId, promptTokens, completionTokens, textRequest.Model, meta.TokenName, quota, logContent)
-	model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
+	cachedTokens := usage.CachedTokens()
+	cacheCreationTokens := usage.CacheCreationTokens()
+	if cachedTokens > 0 || cacheCreationTokens > 0 {
+		logContent += fmt.Sprintf("，缓存读取 %d tokens，缓存读取倍率 %.2f，缓存写入 %d tokens，缓存写入倍率 %.2f", cachedTokens, cacheReadRatio, cacheCreationTokens, cacheWriteRatio)
+	}
+	model.RecordConsumeLogWithCachedTokens(ctx, meta.UserId, meta.ChannelId, promptTokens, completionTokens, cachedTokens, cacheCreationTokens, textRequest.Model, meta.TokenName, quota, logContent)
+	model.UpdateUsedQuotaAndRequestCountOf(meta.UserId, meta.OrganizationId, quota)
 	model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+	metrics.RecordConsumption(meta.ChannelId, meta.OriginModelName, meta.Group, promptTokens, completionTokens, quota)
 }
 
 func getMappedModelName(modelName string, mapping map[string]string) (string, bool) {
@@ -3,23 +3,27 @@ package controller
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
-	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
 	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
 	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
 	"github.com/songquanpeng/one-api/relay/channeltype"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
 )
 
 func getImageRequest(c *gin.Context, relayMode int) (*relaymodel.ImageRequest, error) {
@@ -70,7 +74,11 @@ func validateImageRequest(imageRequest *relaymodel.ImageRequest, meta *meta.Meta
 	if imageRequest.Prompt == "" {
 		return openai.ErrorWrapper(errors.New("prompt is required"), "prompt_missing", http.StatusBadRequest)
 	}
+	return validateImageParameters(imageRequest)
+}
 
+// validateImageParameters checks the parameters shared by the generations, edits and variations
+func validateImageParameters(imageRequest *relaymodel.ImageRequest) *relaymodel.ErrorWithStatusCode {
 	// model validation
 	if !isValidImageSize(imageRequest.Model, imageRequest.Size) {
 		return openai.ErrorWrapper(errors.New("size not supported for this image model"), "size_not_supported", http.StatusBadRequest)
@@ -102,6 +110,44 @@ func getImageCostRatio(imageRequest *relaymodel.ImageRequest) (float64, error) {
 	return imageCostRatio, nil
 }
 
+// getImageQuota returns the quota of the images, the request is rejected when the user cannot afford it
+func getImageQuota(c *gin.Context, meta *meta.Meta, modelName string, imageCostRatio float64, n int) (int64, *relaymodel.ErrorWithStatusCode) {
+	ratio := billingratio.GetModelRatio(modelName, meta.ChannelType) * billingratio.GetGroupRatio(meta.Group)
+	if bizErr := consumeRateLimit(c, meta, 0); bizErr != nil {
+		return 0, bizErr
+	}
+	userQuota, err := model.CacheGetQuotaOf(c.Request.Context(), meta.UserId, meta.OrganizationId)
+	if err != nil {
+		return 0, openai.ErrorWrapper(err, "get_user_quota_failed", http.StatusInternalServerError)
+	}
+	quota := int64(ratio*imageCostRatio*1000) * int64(n)
+	if userQuota-quota < 0 {
+		return 0, openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	}
+	return quota, nil
+}
+
+// postConsumeImageQuota consumes the quota of the images once they are generated
+func postConsumeImageQuota(ctx context.Context, meta *meta.Meta, modelName string, quota int64) {
+	err := model.PostConsumeTokenQuota(meta.TokenId, quota)
+	if err != nil {
+		logger.SysError("error consuming token remain quota: " + err.Error())
+	}
+	err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+	if err != nil {
+		logger.SysError("error update user quota cache: " + err.Error())
+	}
+	if quota != 0 {
+		modelRatio := billingratio.GetModelRatio(modelName, meta.ChannelType)
+		groupRatio := billingratio.GetGroupRatio(meta.Group)
+		logContent := fmt.Sprintf("模型倍率 %.2f，分组倍率 %.2f", modelRatio, groupRatio)
+		model.RecordConsumeLog(ctx, meta.UserId, meta.ChannelId, 0, 0, modelName, meta.TokenName, quota, logContent)
+		model.UpdateUsedQuotaAndRequestCountOf(meta.UserId, meta.OrganizationId, quota)
+		model.UpdateChannelUsedQuota(meta.ChannelId, quota)
+		metrics.RecordConsumption(meta.ChannelId, meta.OriginModelName, meta.Group, 0, 0, quota)
+	}
+}
+
 func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
 	ctx := c.Request.Context()
 	meta := meta.GetByContext(c)
@@ -167,19 +213,13 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		requestBody = bytes.NewBuffer(jsonStr)
 	}
 
-	modelRatio := billingratio.GetModelRatio(imageModel, meta.ChannelType)
-	groupRatio := billingratio.GetGroupRatio(meta.Group)
-	ratio := modelRatio * groupRatio
-	userQuota, err := model.CacheGetUserQuota(ctx, meta.UserId)
-
-	quota := int64(ratio*imageCostRatio*1000) * int64(imageRequest.N)
-
-	if userQuota-quota < 0 {
-		return openai.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusForbidden)
+	quota, bizErr := getImageQuota(c, meta, imageModel, imageCostRatio, imageRequest.N)
+	if bizErr != nil {
+		return bizErr
 	}
 
 	// do request
-	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	resp, err := doRequest(c, meta, adaptor, requestBody)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
@@ -189,31 +229,220 @@ func RelayImageHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 		if resp != nil && resp.StatusCode != http.StatusOK {
 			return
 		}
+		postConsumeImageQuota(ctx, meta, imageModel, quota)
+	}(c.Request.Context())
+
+	// do response
+	_, respErr := doResponse(c, meta, adaptor, resp)
+	if respErr != nil {
+		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		return respErr
+	}
+
+	return nil
+}
+
+func getImageEditRequest(c *gin.Context, relayMode int) (*relaymodel.ImageEditRequest, *multipart.Form, error) {
+	imageRequest := &relaymodel.ImageEditRequest{}
+	err := common.UnmarshalBodyReusable(c, imageRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(getImageFiles(form, "image")) == 0 {
+		return nil, nil, errors.New("image is required")
+	}
+	if relayMode == relaymode.ImagesEdits && imageRequest.Prompt == "" {
+		return nil, nil, errors.New("prompt is required")
+	}
+	if imageRequest.N == 0 {
+		imageRequest.N = 1
+	}
+	if imageRequest.Size == "" {
+		imageRequest.Size = "1024x1024"
+	}
+	if imageRequest.Model == "" {
+		imageRequest.Model = "dall-e-2"
+	}
+	return imageRequest, form, nil
+}
+
+// getImageFiles returns the files of the field, gpt-image-1 accepts several images as image[]
+func getImageFiles(form *multipart.Form, field string) []*multipart.FileHeader {
+	if files := form.File[field]; len(files) > 0 {
+		return files
+	}
+	return form.File[field+"[]"]
+}
+
+func getImageDataURL(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	mimeType := file.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// getImageEditRequestBody rebuilds the multipart form with the mapped model name
+func getImageEditRequestBody(form *multipart.Form, modelName string) (io.Reader, string, error) {
+	requestBody := &bytes.Buffer{}
+	writer := multipart.NewWriter(requestBody)
+	for key, values := range form.Value {
+		if key == "model" {
+			continue
+		}
+		for _, value := range values {
+			if err := writer.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.WriteField("model", modelName); err != nil {
+		return nil, "", err
+	}
+	for _, files := range form.File {
+		for _, file := range files {
+			part, err := writer.CreatePart(file.Header)
+			if err != nil {
+				return nil, "", err
+			}
+			f, err := file.Open()
+			if err != nil {
+				return nil, "", err
+			}
+			_, err = io.Copy(part, f)
+			_ = f.Close()
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return requestBody, writer.FormDataContentType(), nil
+}
+
+// RelayImageEditHelper relays multipart image edits and variations,
+// they are forwarded as is to openai compatible channels and converted for adaptors which support editing
+func RelayImageEditHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	editRequest, form, err := getImageEditRequest(c, relayMode)
+	if err != nil {
+		logger.Errorf(ctx, "getImageEditRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "invalid_image_request", http.StatusBadRequest)
+	}
+
+	// map model name
+	var isModelMapped bool
+	meta.OriginModelName = editRequest.Model
+	editRequest.Model, isModelMapped = getMappedModelName(editRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = editRequest.Model
+	imageRequest := &relaymodel.ImageRequest{
+		Model:          editRequest.Model,
+		Prompt:         editRequest.Prompt,
+		N:              editRequest.N,
+		Size:           editRequest.Size,
+		Quality:        editRequest.Quality,
+		ResponseFormat: editRequest.ResponseFormat,
+		User:           editRequest.User,
+	}
+
+	// model validation, the prompt is checked by getImageEditRequest as variations have none
+	if bizErr := validateImageParameters(imageRequest); bizErr != nil {
+		return bizErr
+	}
+
+	imageCostRatio, err := getImageCostRatio(imageRequest)
+	if err != nil {
+		return openai.ErrorWrapper(err, "get_image_cost_ratio_failed", http.StatusInternalServerError)
+	}
+	c.Set("response_format", imageRequest.ResponseFormat)
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
 
-		err := model.PostConsumeTokenQuota(meta.TokenId, quota)
+	var requestBody io.Reader
+	switch {
+	case meta.APIType == apitype.OpenAI:
+		if isModelMapped {
+			var contentType string
+			requestBody, contentType, err = getImageEditRequestBody(form, imageRequest.Model)
+			if err != nil {
+				return openai.ErrorWrapper(err, "new_request_body_failed", http.StatusInternalServerError)
+			}
+			c.Request.Header.Set("Content-Type", contentType)
+			break
+		}
+		// the body has been consumed by parsing the form
+		body, err := common.GetRequestBody(c)
 		if err != nil {
-			logger.SysError("error consuming token remain quota: " + err.Error())
+			return openai.ErrorWrapper(err, "read_request_body_failed", http.StatusBadRequest)
 		}
-		err = model.CacheUpdateUserQuota(ctx, meta.UserId)
+		requestBody = bytes.NewReader(body)
+	case meta.ChannelType == channeltype.Ali && relayMode == relaymode.ImagesEdits:
+		imageRequest.Image, err = getImageDataURL(getImageFiles(form, "image")[0])
 		if err != nil {
-			logger.SysError("error update user quota cache: " + err.Error())
+			return openai.ErrorWrapper(err, "read_image_failed", http.StatusBadRequest)
 		}
-		if quota != 0 {
-			tokenName := c.GetString(ctxkey.TokenName)
-			logContent := fmt.Sprintf("模型倍率 %.2f，分组倍率 %.2f", modelRatio, groupRatio)
-			model.RecordConsumeLog(ctx, meta.UserId, meta.ChannelId, 0, 0, imageRequest.Model, tokenName, quota, logContent)
-			model.UpdateUserUsedQuotaAndRequestCount(meta.UserId, quota)
-			channelId := c.GetInt(ctxkey.ChannelId)
-			model.UpdateChannelUsedQuota(channelId, quota)
+		if masks := form.File["mask"]; len(masks) > 0 {
+			imageRequest.Mask, err = getImageDataURL(masks[0])
+			if err != nil {
+				return openai.ErrorWrapper(err, "read_image_failed", http.StatusBadRequest)
+			}
 		}
-	}(c.Request.Context())
+		finalRequest, err := adaptor.ConvertImageRequest(imageRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "convert_image_request_failed", http.StatusInternalServerError)
+		}
+		jsonStr, err := json.Marshal(finalRequest)
+		if err != nil {
+			return openai.ErrorWrapper(err, "marshal_image_request_failed", http.StatusInternalServerError)
+		}
+		requestBody = bytes.NewBuffer(jsonStr)
+		c.Request.Header.Set("Content-Type", "application/json")
+	default:
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support this image api", meta.ChannelType), "unsupported_channel_type", http.StatusServiceUnavailable)
+	}
+
+	quota, bizErr := getImageQuota(c, meta, imageRequest.Model, imageCostRatio, imageRequest.N)
+	if bizErr != nil {
+		return bizErr
+	}
+
+	// do request
+	resp, err := doRequest(c, meta, adaptor, requestBody)
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		return RelayErrorHandler(resp)
+	}
 
 	// do response
-	_, respErr := adaptor.DoResponse(c, resp, meta)
+	_, respErr := doResponse(c, meta, adaptor, resp)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		return respErr
 	}
 
+	postConsumeImageQuota(ctx, meta, imageRequest.Model, quota)
 	return nil
 }
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func newMultipartContext(t *testing.T, fields map[string]string, files map[string][]byte) *gin.Context {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		require.NoError(t, writer.WriteField(key, value))
+	}
+	for field, data := range files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="image.png"`)
+		header.Set("Content-Type", "application/octet-stream")
+		part, err := writer.CreatePart(header)
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/edits", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c
+}
+
+func TestGetImageEditRequest(t *testing.T) {
+	c := newMultipartContext(t, map[string]string{"prompt": "add a hat", "n": "2"}, map[string][]byte{"image[]": pngHeader})
+	editRequest, form, err := getImageEditRequest(c, relaymode.ImagesEdits)
+	require.NoError(t, err)
+	assert.Equal(t, "add a hat", editRequest.Prompt)
+	assert.Equal(t, 2, editRequest.N)
+	assert.Equal(t, "1024x1024", editRequest.Size)
+	assert.Equal(t, "dall-e-2", editRequest.Model)
+	assert.Len(t, getImageFiles(form, "image"), 1)
+
+	// variations take no prompt, edits require one
+	c = newMultipartContext(t, nil, map[string][]byte{"image": pngHeader})
+	_, _, err = getImageEditRequest(c, relaymode.ImagesVariations)
+	assert.NoError(t, err)
+	c = newMultipartContext(t, nil, map[string][]byte{"image": pngHeader})
+	_, _, err = getImageEditRequest(c, relaymode.ImagesEdits)
+	assert.EqualError(t, err, "prompt is required")
+
+	c = newMultipartContext(t, map[string]string{"prompt": "add a hat"}, nil)
+	_, _, err = getImageEditRequest(c, relaymode.ImagesEdits)
+	assert.EqualError(t, err, "image is required")
+}
+
+func TestGetImageDataURL(t *testing.T) {
+	c := newMultipartContext(t, map[string]string{"prompt": "add a hat"}, map[string][]byte{"image": pngHeader})
+	_, form, err := getImageEditRequest(c, relaymode.ImagesEdits)
+	require.NoError(t, err)
+	// the octet-stream type is replaced by the detected one
+	dataURL, err := getImageDataURL(getImageFiles(form, "image")[0])
+	require.NoError(t, err)
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgoAAAANSUhEUg==", dataURL)
+}
+
+func TestGetImageEditRequestBody(t *testing.T) {
+	c := newMultipartContext(t, map[string]string{"model": "my-image", "prompt": "add a hat"}, map[string][]byte{"image": pngHeader, "mask": []byte("mask")})
+	_, form, err := getImageEditRequest(c, relaymode.ImagesEdits)
+	require.NoError(t, err)
+
+	requestBody, contentType, err := getImageEditRequestBody(form, "gpt-image-1")
+	require.NoError(t, err)
+	request := httptest.NewRequest(http.MethodPost, "/v1/images/edits", requestBody)
+	request.Header.Set("Content-Type", contentType)
+	require.NoError(t, request.ParseMultipartForm(1<<20))
+	assert.Equal(t, []string{"gpt-image-1"}, request.MultipartForm.Value["model"])
+	assert.Equal(t, []string{"add a hat"}, request.MultipartForm.Value["prompt"])
+
+	files := request.MultipartForm.File
+	require.Len(t, files["image"], 1)
+	require.Len(t, files["mask"], 1)
+	f, err := files["image"][0].Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, pngHeader, data)
+	assert.Equal(t, "image.png", files["image"][0].Filename)
+}
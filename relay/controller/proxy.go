@@ -24,14 +24,14 @@ func RelayProxyHelper(c *gin.Context, relayMode int) *relaymodel.ErrorWithStatus
 	}
 	adaptor.Init(meta)
 
-	resp, err := adaptor.DoRequest(c, meta, c.Request.Body)
+	resp, err := doRequest(c, meta, adaptor, c.Request.Body)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
 	}
 
 	// do response
-	_, respErr := adaptor.DoResponse(c, resp, meta)
+	_, respErr := doResponse(c, meta, adaptor, resp)
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		return respErr
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+)
+
+// https://platform.openai.com/docs/guides/rate-limits
+
+func getRateLimitRules(meta *meta.Meta, kind string, tokenLimit int64, groupLimit int64) []ratelimit.Rule {
+	var rules []ratelimit.Rule
+	if tokenLimit > 0 {
+		rules = append(rules, ratelimit.Rule{Key: fmt.Sprintf("token:%d:%s", meta.TokenId, kind), Limit: tokenLimit})
+	}
+	// the limits of the group apply to each user in it separately
+	if groupLimit > 0 {
+		rules = append(rules, ratelimit.Rule{Key: fmt.Sprintf("user:%d:%s", meta.UserId, kind), Limit: groupLimit})
+	}
+	return rules
+}
+
+func setRateLimitHeaders(c *gin.Context, kind string, result ratelimit.Result) {
+	c.Header("x-ratelimit-limit-"+kind, strconv.FormatInt(result.Limit, 10))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(result.Remaining, 10))
+	c.Header("x-ratelimit-reset-"+kind, result.Reset.Round(time.Millisecond).String())
+}
+
+func rateLimitError(c *gin.Context, kind string, unit string, result ratelimit.Result, requested int64) *model.ErrorWithStatusCode {
+	c.Set(ctxkey.RateLimitExceeded, true)
+	return &model.ErrorWithStatusCode{
+		Error: model.Error{
+			Message: fmt.Sprintf("Rate limit reached for %s per min (%s): Limit %d, Used %d, Requested %d. Please try again in %s.",
+				kind, unit, result.Limit, result.Limit-result.Remaining, requested, result.Reset.Round(time.Second)),
+			Type: kind,
+			Code: "rate_limit_exceeded",
+		},
+		StatusCode: http.StatusTooManyRequests,
+	}
+}
+
+// consumeRateLimit enforces the rpm and tpm limits of the token and the per-user limits of the group,
+// the prompt tokens are consumed up front and corrected with the actual usage by reconcileRateLimit.
+// the limits are checked once per request, retries on other channels are not limited again
+func consumeRateLimit(c *gin.Context, meta *meta.Meta, promptTokens int) *model.ErrorWithStatusCode {
+	if _, ok := c.Get(ctxkey.RateLimit); ok {
+		return nil
+	}
+	ctx := c.Request.Context()
+	groupLimit := ratelimit.GetGroupRateLimit(meta.Group)
+	requestRules := getRateLimitRules(meta, "requests", meta.TokenRpmLimit, groupLimit.RPM)
+	var tokenRules []ratelimit.Rule
+	if promptTokens > 0 {
+		tokenRules = getRateLimitRules(meta, "tokens", meta.TokenTpmLimit, groupLimit.TPM)
+	}
+
+	var requestReservation *ratelimit.Reservation
+	if len(requestRules) > 0 {
+		reservation, result, ok, err := ratelimit.Reserve(ctx, requestRules, 1)
+		if err != nil {
+			return openai.ErrorWrapper(err, "rate_limit_failed", http.StatusInternalServerError)
+		}
+		setRateLimitHeaders(c, "requests", result)
+		if !ok {
+			return rateLimitError(c, "requests", "RPM", result, 1)
+		}
+		requestReservation = reservation
+	}
+	var tokenReservation *ratelimit.Reservation
+	if len(tokenRules) > 0 {
+		reservation, result, ok, err := ratelimit.Reserve(ctx, tokenRules, int64(promptTokens))
+		if err != nil {
+			requestReservation.Reconcile(ctx, 0)
+			return openai.ErrorWrapper(err, "rate_limit_failed", http.StatusInternalServerError)
+		}
+		setRateLimitHeaders(c, "tokens", result)
+		if !ok {
+			// the rejected request does not count
+			requestReservation.Reconcile(ctx, 0)
+			return rateLimitError(c, "tokens", "TPM", result, int64(promptTokens))
+		}
+		tokenReservation = reservation
+	}
+	c.Set(ctxkey.RateLimit, tokenReservation)
+	meta.RateLimit = tokenReservation
+	return nil
+}
+
+// reconcileRateLimit corrects the tokens consumed up front with the actual usage
+func reconcileRateLimit(ctx context.Context, meta *meta.Meta, usage *model.Usage) {
+	if meta.RateLimit == nil || usage == nil {
+		return
+	}
+	meta.RateLimit.Reconcile(ctx, int64(usage.PromptTokens+usage.CompletionTokens))
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/random"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/monitor/metrics"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+var realtimeUpgrader = websocket.Upgrader{
+	Subprotocols: []string{"realtime"},
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// RelayRealtimeHelper proxies a realtime api websocket session to the upstream,
+// the quota of every response is pre-consumed once it is created and settled once it is done,
+// and the session is closed when the quota runs out
+func RelayRealtimeHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	if meta.APIType != apitype.OpenAI || (meta.ChannelType != channeltype.OpenAI && meta.ChannelType != channeltype.Azure) {
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support realtime api", meta.ChannelType), "unsupported_channel_type", http.StatusServiceUnavailable)
+	}
+	meta.OriginModelName = c.Query("model")
+	meta.ActualModelName, _ = getMappedModelName(meta.OriginModelName, meta.ModelMapping)
+	if bizErr := consumeRateLimit(c, meta, 0); bizErr != nil {
+		return bizErr
+	}
+	// the first response is reserved before the session is opened, the following ones once they are created
+	session := newRealtimeSession(ctx, meta)
+	if bizErr := session.reserve(); bizErr != nil {
+		return bizErr
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		session.release()
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+	fullRequestURL, err := adaptor.GetRequestURL(meta)
+	if err != nil {
+		session.release()
+		return openai.ErrorWrapper(err, "get_request_url_failed", http.StatusInternalServerError)
+	}
+	// http -> ws, https -> wss
+	fullRequestURL = "ws" + strings.TrimPrefix(fullRequestURL, "http")
+	req, err := http.NewRequest(http.MethodGet, fullRequestURL, nil)
+	if err != nil {
+		session.release()
+		return openai.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	if err = adaptor.SetupRequestHeader(c, req, meta); err != nil {
+		session.release()
+		return openai.ErrorWrapper(err, "setup_request_header_failed", http.StatusInternalServerError)
+	}
+	req.Header.Del("Content-Type")
+	req.Header.Del("Accept")
+	req.Header.Set("OpenAI-Beta", "realtime=v1")
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+	}
+	upstream, resp, err := dialer.DialContext(ctx, fullRequestURL, req.Header)
+	if err != nil {
+		logger.Errorf(ctx, "dial realtime upstream failed: %s", err.Error())
+		session.release()
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return RelayErrorHandler(resp)
+		}
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	client, err := realtimeUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already replied with an error
+		logger.Errorf(ctx, "upgrade realtime connection failed: %s", err.Error())
+		_ = upstream.Close()
+		session.release()
+		return nil
+	}
+
+	session.relay(client, upstream)
+	session.settle()
+	return nil
+}
+
+type realtimeSession struct {
+	ctx                  context.Context
+	meta                 *meta.Meta
+	modelRatio           float64
+	groupRatio           float64
+	completionRatio      float64
+	audioPromptRatio     float64
+	audioCompletionRatio float64
+	usage                relaymodel.Usage
+	quota                int64
+	// the quota pre-consumed for the response in progress, it is settled once the response is done
+	preConsumedQuota int64
+	reserved         bool
+}
+
+func newRealtimeSession(ctx context.Context, meta *meta.Meta) *realtimeSession {
+	return &realtimeSession{
+		ctx:                  ctx,
+		meta:                 meta,
+		modelRatio:           billingratio.GetModelRatio(meta.ActualModelName, meta.ChannelType),
+		groupRatio:           billingratio.GetGroupRatio(meta.Group),
+		completionRatio:      billingratio.GetCompletionRatio(meta.ActualModelName, meta.ChannelType),
+		audioPromptRatio:     billingratio.GetAudioPromptRatio(meta.ActualModelName),
+		audioCompletionRatio: billingratio.GetAudioCompletionRatio(meta.ActualModelName),
+	}
+}
+
+// relay pumps messages in both directions until either side closes the connection
+func (s *realtimeSession) relay(client *websocket.Conn, upstream *websocket.Conn) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer upstream.Close()
+		for {
+			messageType, data, err := client.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = upstream.WriteMessage(messageType, data); err != nil {
+				logger.Errorf(s.ctx, "write realtime upstream failed: %s", err.Error())
+				return
+			}
+		}
+	}()
+	for {
+		messageType, data, err := upstream.ReadMessage()
+		if err != nil {
+			break
+		}
+		if err = client.WriteMessage(messageType, data); err != nil {
+			break
+		}
+		if messageType != websocket.TextMessage || s.handleEvent(data) {
+			continue
+		}
+		// the quota runs out, terminate the session
+		errorEvent, _ := json.Marshal(relaymodel.RealtimeEvent{
+			Type:    "error",
+			EventId: "event_" + random.GetUUID(),
+			Error: &relaymodel.Error{
+				Message: "user quota is not enough",
+				Type:    "insufficient_quota",
+				Code:    "insufficient_user_quota",
+			},
+		})
+		_ = client.WriteMessage(websocket.TextMessage, errorEvent)
+		_ = client.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "insufficient quota"))
+		break
+	}
+	_ = upstream.Close()
+	_ = client.Close()
+	<-done
+}
+
+// handleEvent reserves the quota of created responses and consumes the usage of finished ones,
+// returns false once the quota runs out
+func (s *realtimeSession) handleEvent(data []byte) bool {
+	var event relaymodel.RealtimeEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return true
+	}
+	switch event.Type {
+	case "response.created":
+		// responses are also created by the server side voice activity detection, not only by the client
+		if bizErr := s.reserve(); bizErr != nil {
+			logger.Warnf(s.ctx, "reserve realtime response failed: %s", bizErr.Message)
+			return false
+		}
+	case "response.done":
+		if event.Response != nil && event.Response.Usage != nil {
+			return s.consume(event.Response.Usage)
+		}
+	}
+	return true
+}
+
+// reserve pre-consumes the estimate of a response, unless one is already reserved
+func (s *realtimeSession) reserve() *relaymodel.ErrorWithStatusCode {
+	if s.reserved {
+		return nil
+	}
+	// the output tokens are priced the highest, the estimate takes them all as output
+	outputRatio := math.Max(s.completionRatio, s.audioPromptRatio*s.audioCompletionRatio)
+	preConsumedQuota, bizErr := preConsumeQuota(s.ctx, &relaymodel.GeneralOpenAIRequest{}, 0, s.modelRatio*s.groupRatio*outputRatio, s.meta)
+	if bizErr != nil {
+		return bizErr
+	}
+	s.preConsumedQuota = preConsumedQuota
+	s.reserved = true
+	return nil
+}
+
+// release returns the quota reserved for a response which never finishes
+func (s *realtimeSession) release() {
+	if s.reserved {
+		billing.ReturnPreConsumedQuota(s.ctx, s.preConsumedQuota, s.meta.TokenId)
+	}
+	s.preConsumedQuota = 0
+	s.reserved = false
+}
+
+func (s *realtimeSession) getQuota(usage *relaymodel.RealtimeUsage) int64 {
+	inputTokens := float64(usage.InputTokenDetails.TextTokens) + float64(usage.InputTokenDetails.AudioTokens)*s.audioPromptRatio
+	outputTokens := float64(usage.OutputTokenDetails.TextTokens)*s.completionRatio + float64(usage.OutputTokenDetails.AudioTokens)*s.audioPromptRatio*s.audioCompletionRatio
+	ratio := s.modelRatio * s.groupRatio
+	quota := int64(math.Ceil((inputTokens + outputTokens) * ratio))
+	if ratio != 0 && quota <= 0 && usage.TotalTokens > 0 {
+		quota = 1
+	}
+	return quota
+}
+
+func (s *realtimeSession) consume(usage *relaymodel.RealtimeUsage) bool {
+	quota := s.getQuota(usage)
+	s.usage.PromptTokens += usage.InputTokens
+	s.usage.CompletionTokens += usage.OutputTokens
+	s.usage.TotalTokens += usage.TotalTokens
+	s.quota += quota
+	quotaDelta := quota - s.preConsumedQuota
+	s.preConsumedQuota = 0
+	s.reserved = false
+	if quotaDelta == 0 {
+		return true
+	}
+	err := model.PostConsumeTokenQuota(s.meta.TokenId, quotaDelta)
+	if err != nil {
+		logger.Error(s.ctx, "error consuming token remain quota: "+err.Error())
+		return false
+	}
+	err = model.CacheUpdateUserQuota(s.ctx, s.meta.UserId)
+	if err != nil {
+		logger.Error(s.ctx, "error update user quota cache: "+err.Error())
+	}
+	userQuota, err := model.CacheGetQuotaOf(s.ctx, s.meta.UserId, s.meta.OrganizationId)
+	if err != nil || userQuota <= 0 {
+		return false
+	}
+	token, err := model.GetTokenById(s.meta.TokenId)
+	if err != nil || (!token.UnlimitedQuota && token.RemainQuota <= 0) {
+		return false
+	}
+	return true
+}
+
+// settle records the consumption of the whole session, the reservation of an unfinished response is returned
+func (s *realtimeSession) settle() {
+	s.release()
+	if s.usage.TotalTokens == 0 {
+		return
+	}
+	logContent := fmt.Sprintf("实时会话，模型倍率 %.2f，分组倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f", s.modelRatio, s.groupRatio, s.completionRatio, s.audioPromptRatio, s.audioCompletionRatio)
+	model.RecordConsumeLog(s.ctx, s.meta.UserId, s.meta.ChannelId, s.usage.PromptTokens, s.usage.CompletionTokens, s.meta.ActualModelName, s.meta.TokenName, s.quota, logContent)
+	model.UpdateUsedQuotaAndRequestCountOf(s.meta.UserId, s.meta.OrganizationId, s.quota)
+	model.UpdateChannelUsedQuota(s.meta.ChannelId, s.quota)
+	metrics.RecordConsumption(s.meta.ChannelId, s.meta.OriginModelName, s.meta.Group, s.usage.PromptTokens, s.usage.CompletionTokens, s.quota)
+}
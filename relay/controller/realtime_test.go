@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealtimeSessionQuota(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "realtime", Quota: 20000, Group: "default", Status: model.UserStatusEnabled}).Error)
+	require.NoError(t, model.DB.Create(&model.Token{Id: 1, UserId: 1, Key: "realtime-token", Name: "t", RemainQuota: 20000, Status: model.TokenStatusEnabled}).Error)
+	session := &realtimeSession{
+		ctx:                  context.Background(),
+		meta:                 &meta.Meta{UserId: 1, TokenId: 1, Group: "default"},
+		modelRatio:           1,
+		groupRatio:           1,
+		completionRatio:      1,
+		audioPromptRatio:     1,
+		audioCompletionRatio: 1,
+	}
+	userQuota := func() int64 {
+		quota, err := model.GetUserQuota(1)
+		require.NoError(t, err)
+		return quota
+	}
+
+	// the first response is reserved before the session is opened
+	require.Nil(t, session.reserve())
+	assert.Equal(t, int64(500), session.preConsumedQuota)
+	assert.Equal(t, int64(19500), userQuota())
+	// the reservation is kept for the response created next
+	assert.True(t, session.handleEvent([]byte(`{"type":"response.created"}`)))
+	assert.Equal(t, int64(19500), userQuota())
+
+	// the reservation is settled with the usage of the response
+	assert.True(t, session.handleEvent([]byte(`{"type":"response.done","response":{"usage":{"total_tokens":150,"input_tokens":100,"output_tokens":50,"input_token_details":{"text_tokens":100},"output_token_details":{"text_tokens":50}}}}`)))
+	assert.False(t, session.reserved)
+	assert.Equal(t, int64(150), session.quota)
+	assert.Equal(t, int64(19850), userQuota())
+
+	// another response is reserved once it is created
+	assert.True(t, session.handleEvent([]byte(`{"type":"response.created"}`)))
+	assert.Equal(t, int64(19350), userQuota())
+	session.release()
+	assert.Eventually(t, func() bool { return userQuota() == 19850 }, time.Second, 10*time.Millisecond)
+
+	// the session is cut when the next response cannot be reserved
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("quota", 100).Error)
+	assert.False(t, session.handleEvent([]byte(`{"type":"response.created"}`)))
+	assert.False(t, session.reserved)
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+func getAndValidateRerankRequest(c *gin.Context) (*relaymodel.RerankRequest, error) {
+	rerankRequest := &relaymodel.RerankRequest{}
+	err := common.UnmarshalBodyReusable(c, rerankRequest)
+	if err != nil {
+		return nil, err
+	}
+	if rerankRequest.Model == "" {
+		return nil, errors.New("model is required")
+	}
+	if rerankRequest.Query == "" {
+		return nil, errors.New("query is required")
+	}
+	if len(rerankRequest.Documents) == 0 {
+		return nil, errors.New("documents are required")
+	}
+	return rerankRequest, nil
+}
+
+func getRerankPromptTokens(rerankRequest *relaymodel.RerankRequest) int {
+	promptTokens := openai.CountTokenInput(rerankRequest.Query, rerankRequest.Model)
+	promptTokens += openai.CountTokenInput(rerankRequest.DocumentTexts(), rerankRequest.Model)
+	return promptTokens
+}
+
+// RelayRerankHelper relays rerank requests, the quota is consumed by the tokens of the query and the documents
+func RelayRerankHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	rerankRequest, err := getAndValidateRerankRequest(c)
+	if err != nil {
+		logger.Errorf(ctx, "getAndValidateRerankRequest failed: %s", err.Error())
+		return openai.ErrorWrapper(err, "invalid_rerank_request", http.StatusBadRequest)
+	}
+	rerankAdaptor, ok := relay.GetAdaptor(meta.APIType).(adaptor.RerankAdaptor)
+	if !ok || (meta.APIType == apitype.OpenAI && !openai.SupportRerank(meta.ChannelType)) {
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support rerank api", meta.ChannelType), "unsupported_channel_type", http.StatusServiceUnavailable)
+	}
+
+	// map model name
+	meta.OriginModelName = rerankRequest.Model
+	rerankRequest.Model, _ = getMappedModelName(rerankRequest.Model, meta.ModelMapping)
+	meta.ActualModelName = rerankRequest.Model
+	// get model ratio & group ratio
+	modelRatio := billingratio.GetModelRatio(rerankRequest.Model, meta.ChannelType)
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	ratio := modelRatio * groupRatio
+	// pre-consume quota, reranking produces no completion tokens
+	textRequest := &relaymodel.GeneralOpenAIRequest{
+		Model: rerankRequest.Model,
+	}
+	promptTokens := getRerankPromptTokens(rerankRequest)
+	meta.PromptTokens = promptTokens
+	if bizErr := consumeRateLimit(c, meta, promptTokens); bizErr != nil {
+		return bizErr
+	}
+	preConsumedQuota, bizErr := preConsumeQuota(ctx, textRequest, promptTokens, ratio, meta)
+	if bizErr != nil {
+		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
+		return bizErr
+	}
+
+	rerankAdaptor.Init(meta)
+	convertedRequest, err := rerankAdaptor.ConvertRerankRequest(rerankRequest)
+	if err != nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	jsonData, err := json.Marshal(convertedRequest)
+	if err != nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "json_marshal_failed", http.StatusInternalServerError)
+	}
+
+	// do request
+	resp, err := doRequest(c, meta, rerankAdaptor, bytes.NewBuffer(jsonData))
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return RelayErrorHandler(resp)
+	}
+
+	// do response
+	usage, respErr := doResponse(c, meta, rerankAdaptor, resp)
+	if respErr != nil {
+		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return respErr
+	}
+	// post-consume quota
+	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio)
+	return nil
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/apitype"
+	"github.com/songquanpeng/one-api/relay/billing"
+	billingratio "github.com/songquanpeng/one-api/relay/billing/ratio"
+	"github.com/songquanpeng/one-api/relay/meta"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// RelayResponsesHelper passes responses api requests through to channels which support it natively,
+// the quota is consumed the same way as chat completions
+func RelayResponsesHelper(c *gin.Context) *relaymodel.ErrorWithStatusCode {
+	ctx := c.Request.Context()
+	meta := meta.GetByContext(c)
+	if meta.APIType != apitype.OpenAI || !openai.SupportResponses(meta.ChannelType) {
+		return openai.ErrorWrapper(fmt.Errorf("channel type %d does not support responses api", meta.ChannelType), "unsupported_channel_type", http.StatusServiceUnavailable)
+	}
+	var request openai.ResponsesRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		return openai.ErrorWrapper(err, "invalid_responses_request", http.StatusBadRequest)
+	}
+	meta.IsStream = request.Stream
+
+	// map model name
+	meta.OriginModelName = request.Model
+	request.Model, _ = getMappedModelName(request.Model, meta.ModelMapping)
+	meta.ActualModelName = request.Model
+	// the converted request is only used to estimate the prompt tokens
+	textRequest := openai.ConvertResponsesRequest(&request, openai.ConvertResponsesInput(request.Input))
+	modelRatio := billingratio.GetModelRatio(request.Model, meta.ChannelType)
+	groupRatio := billingratio.GetGroupRatio(meta.Group)
+	ratio := modelRatio * groupRatio
+	promptTokens := openai.CountTokenMessages(textRequest.Messages, request.Model)
+	meta.PromptTokens = promptTokens
+	if bizErr := consumeRateLimit(c, meta, promptTokens); bizErr != nil {
+		return bizErr
+	}
+	preConsumedQuota, bizErr := preConsumeQuota(ctx, textRequest, promptTokens, ratio, meta)
+	if bizErr != nil {
+		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
+		return bizErr
+	}
+
+	adaptor := relay.GetAdaptor(meta.APIType)
+	if adaptor == nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(fmt.Errorf("invalid api type: %d", meta.APIType), "invalid_api_type", http.StatusBadRequest)
+	}
+	adaptor.Init(meta)
+
+	requestBody, err := getResponsesRequestBody(c, meta)
+	if err != nil {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
+	}
+	resp, err := doRequest(c, meta, adaptor, requestBody)
+	if err != nil {
+		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
+	}
+	if isErrorHappened(meta, resp) {
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return RelayErrorHandler(resp)
+	}
+
+	var responseId string
+	var responseText string
+	var usage *relaymodel.Usage
+	var respErr *relaymodel.ErrorWithStatusCode
+	span, endSpan := tracing.StartStage(c, "adaptor.DoResponse", attribute.Bool("stream", meta.IsStream))
+	if meta.IsStream {
+		respErr, responseId, responseText, usage = openai.ResponsesStreamHandler(c, resp)
+	} else {
+		respErr, responseId, usage = openai.ResponsesHandler(c, resp)
+	}
+	if respErr != nil {
+		span.SetStatus(codes.Error, respErr.Message)
+	}
+	endSpan()
+	if respErr != nil {
+		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
+		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+		return respErr
+	}
+	if usage == nil {
+		// the stream is interrupted before the response is completed, the text streamed so far is billed
+		usage = openai.ResponseText2Usage(responseText, request.Model, promptTokens)
+	}
+	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio)
+
+	if request.ShouldStore() && responseId != "" {
+		record := &model.StoredResponse{
+			ResponseId: responseId,
+			UserId:     meta.UserId,
+			ChannelId:  meta.ChannelId,
+		}
+		if err = record.Insert(); err != nil {
+			logger.Errorf(ctx, "failed to record response %s: %s", responseId, err.Error())
+		}
+	}
+	return nil
+}
+
+func getResponsesRequestBody(c *gin.Context, meta *meta.Meta) (io.Reader, error) {
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return nil, err
+	}
+	if meta.OriginModelName == meta.ActualModelName {
+		return bytes.NewReader(requestBody), nil
+	}
+	// keep the fields unknown to one-api untouched
+	request := make(map[string]any)
+	if err = json.Unmarshal(requestBody, &request); err != nil {
+		return nil, err
+	}
+	request["model"] = meta.ActualModelName
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(jsonData), nil
+}
@@ -8,7 +8,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/common/tracing"
 	"github.com/songquanpeng/one-api/relay"
 	"github.com/songquanpeng/one-api/relay/adaptor"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
@@ -42,11 +44,28 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	// pre-consume quota
 	promptTokens := getPromptTokens(textRequest, meta.Mode)
 	meta.PromptTokens = promptTokens
+	if bizErr := consumeRateLimit(c, meta, promptTokens); bizErr != nil {
+		return bizErr
+	}
 	preConsumedQuota, bizErr := preConsumeQuota(ctx, textRequest, promptTokens, ratio, meta)
 	if bizErr != nil {
 		logger.Warnf(ctx, "preConsumeQuota failed: %+v", *bizErr)
 		return bizErr
 	}
+	// serve identical requests from the response cache
+	cacheKey, cacheable := getResponseCacheKey(meta, textRequest)
+	if cacheable {
+		if response, ok := getCachedResponse(ctx, cacheKey); ok {
+			logger.Infof(ctx, "response cache hit: %s", cacheKey)
+			c.Set(ctxkey.ResponseCacheHit, true)
+			if err = replayCachedResponse(c, meta, textRequest, response); err != nil {
+				billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
+				return openai.ErrorWrapper(err, "replay_cached_response_failed", http.StatusInternalServerError)
+			}
+			go consumeCachedQuota(ctx, &response.Usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio)
+			return nil
+		}
+	}
 
 	adaptor := relay.GetAdaptor(meta.APIType)
 	if adaptor == nil {
@@ -55,13 +74,16 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	adaptor.Init(meta)
 
 	// get request body
+	span, endSpan := tracing.StartStage(c, "adaptor.ConvertRequest")
 	requestBody, err := getRequestBody(c, meta, textRequest, adaptor)
+	tracing.RecordError(span, err)
+	endSpan()
 	if err != nil {
 		return openai.ErrorWrapper(err, "convert_request_failed", http.StatusInternalServerError)
 	}
 
 	// do request
-	resp, err := adaptor.DoRequest(c, meta, requestBody)
+	resp, err := doRequest(c, meta, adaptor, requestBody)
 	if err != nil {
 		logger.Errorf(ctx, "DoRequest failed: %s", err.Error())
 		return openai.ErrorWrapper(err, "do_request_failed", http.StatusInternalServerError)
@@ -72,12 +94,23 @@ func RelayTextHelper(c *gin.Context) *model.ErrorWithStatusCode {
 	}
 
 	// do response
-	usage, respErr := adaptor.DoResponse(c, resp, meta)
+	var cacheWriter *responseCacheWriter
+	if cacheable {
+		cacheWriter = newResponseCacheWriter(c.Writer)
+		c.Writer = cacheWriter
+	}
+	usage, respErr := doResponse(c, meta, adaptor, resp)
+	if cacheWriter != nil {
+		c.Writer = cacheWriter.ResponseWriter
+	}
 	if respErr != nil {
 		logger.Errorf(ctx, "respErr is not nil: %+v", respErr)
 		billing.ReturnPreConsumedQuota(ctx, preConsumedQuota, meta.TokenId)
 		return respErr
 	}
+	if cacheWriter != nil {
+		cacheResponse(ctx, cacheKey, meta, cacheWriter, usage)
+	}
 	// post-consume quota
 	go postConsumeQuota(ctx, usage, meta, textRequest, ratio, preConsumedQuota, modelRatio, groupRatio)
 	return nil
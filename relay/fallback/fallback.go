@@ -0,0 +1,196 @@
+package fallback
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// a fallback chain lists the models which take over a request in order, when the channels of the requested model
+// are all failed or unavailable. the chains are defined per group, the chains of a token override those of its group
+
+// Chains maps a model to the models it falls back to
+type Chains map[string][]string
+
+// GroupModelFallback is the fallback chains of every group
+var GroupModelFallback = map[string]Chains{}
+
+func GroupModelFallback2JSONString() string {
+	jsonBytes, err := json.Marshal(GroupModelFallback)
+	if err != nil {
+		logger.SysError("error marshalling group model fallback: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupModelFallbackByJSONString(jsonStr string) error {
+	newGroupModelFallback := make(map[string]Chains)
+	err := json.Unmarshal([]byte(jsonStr), &newGroupModelFallback)
+	if err != nil {
+		return err
+	}
+	GroupModelFallback = newGroupModelFallback
+	return nil
+}
+
+// ParseChains parses the fallback chains of a token, an empty string means no chains
+func ParseChains(jsonStr string) (Chains, error) {
+	chains := make(Chains)
+	if jsonStr == "" {
+		return chains, nil
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &chains); err != nil {
+		return nil, err
+	}
+	return chains, nil
+}
+
+func getChain(c *gin.Context, modelName string) []string {
+	if tokenChains, err := ParseChains(c.GetString(ctxkey.ModelFallback)); err == nil {
+		if chain, ok := tokenChains[modelName]; ok {
+			return chain
+		}
+	}
+	return GroupModelFallback[c.GetString(ctxkey.Group)][modelName]
+}
+
+func isModelAvailable(c *gin.Context, modelName string) bool {
+	availableModels := c.GetString(ctxkey.AvailableModels)
+	if availableModels == "" {
+		return true
+	}
+	for _, m := range strings.Split(availableModels, ",") {
+		if m == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json")
+}
+
+func isMultipartRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// GetNextModels returns the models of the fallback chain of the requested model which come after the current model,
+// the models the token is not allowed to use are skipped. only json and multipart requests carry a model which can be replaced
+func GetNextModels(c *gin.Context, requestModel string, currentModel string) []string {
+	if !isJSONRequest(c) && !isMultipartRequest(c) {
+		return nil
+	}
+	chain := getChain(c, requestModel)
+	if currentModel != requestModel {
+		index := -1
+		for i, m := range chain {
+			if m == currentModel {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return nil
+		}
+		chain = chain[index+1:]
+	}
+	var models []string
+	seen := map[string]bool{requestModel: true, currentModel: true}
+	for _, m := range chain {
+		if seen[m] || !isModelAvailable(c, m) {
+			continue
+		}
+		seen[m] = true
+		models = append(models, m)
+	}
+	return models
+}
+
+// UseModel replaces the model of the request body, so that the request is relayed and billed as the model.
+// the body of the requests which are neither json nor multipart is left as it is
+func UseModel(c *gin.Context, modelName string) error {
+	if !isJSONRequest(c) && !isMultipartRequest(c) {
+		return nil
+	}
+	requestBody, err := common.GetRequestBody(c)
+	if err != nil {
+		return err
+	}
+	if isMultipartRequest(c) {
+		requestBody, err = replaceMultipartModel(requestBody, c.Request.Header.Get("Content-Type"), modelName)
+		if err != nil {
+			return fmt.Errorf("failed to replace the model of the request: %w", err)
+		}
+		// the form parsed from the original body is dropped, so that it is parsed again from the new one
+		c.Request.Form, c.Request.PostForm, c.Request.MultipartForm = nil, nil, nil
+		c.Request.ContentLength = int64(len(requestBody))
+	} else {
+		var request map[string]json.RawMessage
+		if err = json.Unmarshal(requestBody, &request); err != nil {
+			return fmt.Errorf("failed to replace the model of the request: %w", err)
+		}
+		request["model"], _ = json.Marshal(modelName)
+		requestBody, err = json.Marshal(request)
+		if err != nil {
+			return err
+		}
+	}
+	c.Set(ctxkey.KeyRequestBody, requestBody)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+	return nil
+}
+
+// replaceMultipartModel rewrites the model field of the multipart body, the other parts are copied as they are
+// and the boundary is kept, so that the content type of the request still applies
+func replaceMultipartModel(requestBody []byte, contentType string, modelName string) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("the multipart boundary is missing")
+	}
+	reader := multipart.NewReader(bytes.NewReader(requestBody), boundary)
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err = writer.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		w, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "model" {
+			_, err = io.WriteString(w, modelName)
+		} else {
+			_, err = io.Copy(w, part)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
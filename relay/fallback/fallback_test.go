@@ -0,0 +1,85 @@
+package fallback_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/ctxkey"
+	"github.com/songquanpeng/one-api/relay/fallback"
+	"github.com/stretchr/testify/assert"
+)
+
+func newContext(body string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(ctxkey.Group, "default")
+	return c
+}
+
+func TestGetNextModels(t *testing.T) {
+	assert.NoError(t, fallback.UpdateGroupModelFallbackByJSONString(`{"default":{"gpt-4o":["claude-3-5-sonnet","gpt-4o","gemini-1.5-pro"]}}`))
+	c := newContext(`{}`)
+	assert.Equal(t, []string{"claude-3-5-sonnet", "gemini-1.5-pro"}, fallback.GetNextModels(c, "gpt-4o", "gpt-4o"))
+	assert.Equal(t, []string{"gemini-1.5-pro"}, fallback.GetNextModels(c, "gpt-4o", "claude-3-5-sonnet"))
+	assert.Empty(t, fallback.GetNextModels(c, "gpt-4o-mini", "gpt-4o-mini"))
+
+	// the chains of the token override those of the group, and the models it may not use are skipped
+	c.Set(ctxkey.ModelFallback, `{"gpt-4o":["gpt-4-turbo","claude-3-opus"]}`)
+	c.Set(ctxkey.AvailableModels, "gpt-4o,claude-3-opus")
+	assert.Equal(t, []string{"claude-3-opus"}, fallback.GetNextModels(c, "gpt-4o", "gpt-4o"))
+}
+
+func TestUseModel(t *testing.T) {
+	c := newContext(`{"model":"gpt-4o","stream":true}`)
+	assert.NoError(t, fallback.UseModel(c, "claude-3-5-sonnet"))
+	requestBody, err := common.GetRequestBody(c)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"model":"claude-3-5-sonnet","stream":true}`, string(requestBody))
+}
+
+func TestUseModelMultipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	assert.NoError(t, writer.WriteField("model", "whisper"))
+	part, err := writer.CreateFormFile("file", "audio.mp3")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte("\x00\x01audio"))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+	c := newContext("")
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	// the form parsed before the model is replaced is parsed again
+	var request struct {
+		Model string `form:"model"`
+	}
+	assert.NoError(t, common.UnmarshalBodyReusable(c, &request))
+	assert.Equal(t, "whisper", request.Model)
+
+	assert.NoError(t, fallback.UseModel(c, "whisper-1"))
+	assert.Equal(t, "whisper-1", c.PostForm("model"))
+	file, err := c.FormFile("file")
+	assert.NoError(t, err)
+	f, err := file.Open()
+	assert.NoError(t, err)
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x00\x01audio"), data)
+}
+
+func TestUseModelOtherContentType(t *testing.T) {
+	c := newContext("plain text")
+	c.Request.Header.Set("Content-Type", "text/plain")
+	assert.NoError(t, fallback.UseModel(c, "gpt-4o"))
+	requestBody, err := common.GetRequestBody(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain text", string(requestBody))
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/songquanpeng/one-api/common/ctxkey"
 	"github.com/songquanpeng/one-api/model"
 	"github.com/songquanpeng/one-api/relay/channeltype"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
 	"github.com/songquanpeng/one-api/relay/relaymode"
 	"strings"
 )
@@ -30,6 +31,15 @@ type Meta struct {
 	ActualModelName string
 	RequestURLPath  string
 	PromptTokens    int // only for DoResponse
+	// ResponseCache reports whether the token opts in to the response cache
+	ResponseCache bool
+	// TokenRpmLimit and TokenTpmLimit are the rate limits of the token, 0 means unlimited
+	TokenRpmLimit int64
+	TokenTpmLimit int64
+	// OrganizationId is the organization whose quota the token draws, 0 means the user
+	OrganizationId int
+	// RateLimit is the reservation of the request in the rate limits, it is corrected with the actual usage
+	RateLimit *ratelimit.Reservation
 }
 
 func GetByContext(c *gin.Context) *Meta {
@@ -46,6 +56,13 @@ func GetByContext(c *gin.Context) *Meta {
 		BaseURL:         c.GetString(ctxkey.BaseURL),
 		APIKey:          strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer "),
 		RequestURLPath:  c.Request.URL.String(),
+		ResponseCache:   c.GetBool(ctxkey.ResponseCache),
+		TokenRpmLimit:   c.GetInt64(ctxkey.TokenRpmLimit),
+		TokenTpmLimit:   c.GetInt64(ctxkey.TokenTpmLimit),
+		OrganizationId:  c.GetInt(ctxkey.OrganizationId),
+	}
+	if reservation, ok := c.Get(ctxkey.RateLimit); ok {
+		meta.RateLimit = reservation.(*ratelimit.Reservation)
 	}
 	cfg, ok := c.Get(ctxkey.Config)
 	if ok {
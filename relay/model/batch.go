@@ -0,0 +1,71 @@
+package model
+
+// https://platform.openai.com/docs/api-reference/batch
+
+type BatchRequest struct {
+	InputFileId      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type Batch struct {
+	Id            string             `json:"id"`
+	Object        string             `json:"object"`
+	Endpoint      string             `json:"endpoint"`
+	InputFileId   string             `json:"input_file_id"`
+	Status        string             `json:"status"`
+	OutputFileId  string             `json:"output_file_id,omitempty"`
+	ErrorFileId   string             `json:"error_file_id,omitempty"`
+	CreatedAt     int64              `json:"created_at"`
+	RequestCounts BatchRequestCounts `json:"request_counts"`
+}
+
+type BatchList struct {
+	Object  string  `json:"object"`
+	Data    []Batch `json:"data"`
+	HasMore bool    `json:"has_more"`
+}
+
+// BatchInput is a single line of the input file of a batch
+type BatchInput struct {
+	CustomId string               `json:"custom_id"`
+	Method   string               `json:"method"`
+	Url      string               `json:"url"`
+	Body     GeneralOpenAIRequest `json:"body"`
+}
+
+// BatchOutput is a single line of the output file of a batch
+type BatchOutput struct {
+	Id       string `json:"id"`
+	CustomId string `json:"custom_id"`
+	Response *struct {
+		StatusCode int `json:"status_code"`
+		Body       struct {
+			Model string `json:"model"`
+			Usage *Usage `json:"usage"`
+		} `json:"body"`
+	} `json:"response"`
+}
+
+const (
+	BatchStatusCompleted = "completed"
+	BatchStatusFailed    = "failed"
+	BatchStatusExpired   = "expired"
+	BatchStatusCancelled = "cancelled"
+)
+
+// IsBatchFinished reports whether the batch will not change anymore
+func IsBatchFinished(status string) bool {
+	switch status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
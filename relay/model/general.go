@@ -12,6 +12,10 @@ type JSONSchema struct {
 	Strict      *bool                  `json:"strict,omitempty"`
 }
 
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
 type GeneralOpenAIRequest struct {
 	Messages         []Message       `json:"messages,omitempty"`
 	Model            string          `json:"model,omitempty"`
@@ -23,7 +27,8 @@ type GeneralOpenAIRequest struct {
 	Seed             float64         `json:"seed,omitempty"`
 	Stop             any             `json:"stop,omitempty"`
 	Stream           bool            `json:"stream,omitempty"`
-	Temperature      float64         `json:"temperature,omitempty"`
+	StreamOptions    *StreamOptions  `json:"stream_options,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
 	TopP             float64         `json:"top_p,omitempty"`
 	TopK             int             `json:"top_k,omitempty"`
 	Tools            []Tool          `json:"tools,omitempty"`
@@ -37,7 +42,7 @@ type GeneralOpenAIRequest struct {
 	Dimensions       int             `json:"dimensions,omitempty"`
 	Instruction      string          `json:"instruction,omitempty"`
 	Size             string          `json:"size,omitempty"`
-	NumCtx           int         	 `json:"num_ctx,omitempty"`
+	NumCtx           int             `json:"num_ctx,omitempty"`
 }
 
 func (r GeneralOpenAIRequest) ParseInput() []string {
@@ -9,4 +9,18 @@ type ImageRequest struct {
 	ResponseFormat string `json:"response_format,omitempty"`
 	Style          string `json:"style,omitempty"`
 	User           string `json:"user,omitempty"`
+	// the data urls of the uploaded images, only set when image edits are converted for other adaptors
+	Image string `json:"image,omitempty"`
+	Mask  string `json:"mask,omitempty"`
+}
+
+// ImageEditRequest is the form of image edits and variations, the images are read from the form files
+type ImageEditRequest struct {
+	Model          string `form:"model"`
+	Prompt         string `form:"prompt"`
+	N              int    `form:"n"`
+	Size           string `form:"size"`
+	Quality        string `form:"quality"`
+	ResponseFormat string `form:"response_format"`
+	User           string `form:"user"`
 }
@@ -1,9 +1,34 @@
 package model
 
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+	// CacheCreationTokens is the prompt tokens written to the cache, only anthropic reports it
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
+}
+
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+	// deepseek reports the prompt tokens read from the cache this way
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens,omitempty"`
+}
+
+// CachedTokens returns the prompt tokens read from the cache, they are included in the prompt tokens
+func (u *Usage) CachedTokens() int {
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return u.PromptCacheHitTokens
+}
+
+// CacheCreationTokens returns the prompt tokens written to the cache, they are included in the prompt tokens
+func (u *Usage) CacheCreationTokens() int {
+	if u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CacheCreationTokens
 }
 
 type Error struct {
@@ -0,0 +1,31 @@
+package model
+
+// https://platform.openai.com/docs/api-reference/realtime-server-events/response/done
+
+type RealtimeInputTokenDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+	TextTokens   int `json:"text_tokens"`
+	AudioTokens  int `json:"audio_tokens"`
+}
+
+type RealtimeOutputTokenDetails struct {
+	TextTokens  int `json:"text_tokens"`
+	AudioTokens int `json:"audio_tokens"`
+}
+
+type RealtimeUsage struct {
+	TotalTokens        int                        `json:"total_tokens"`
+	InputTokens        int                        `json:"input_tokens"`
+	OutputTokens       int                        `json:"output_tokens"`
+	InputTokenDetails  RealtimeInputTokenDetails  `json:"input_token_details"`
+	OutputTokenDetails RealtimeOutputTokenDetails `json:"output_token_details"`
+}
+
+type RealtimeEvent struct {
+	Type     string `json:"type"`
+	EventId  string `json:"event_id,omitempty"`
+	Response *struct {
+		Usage *RealtimeUsage `json:"usage,omitempty"`
+	} `json:"response,omitempty"`
+	Error *Error `json:"error,omitempty"`
+}
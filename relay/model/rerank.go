@@ -0,0 +1,43 @@
+package model
+
+type RerankRequest struct {
+	Model string `json:"model"`
+	Query string `json:"query"`
+	// the documents are either strings or objects with a text field
+	Documents       []any `json:"documents"`
+	TopN            int   `json:"top_n,omitempty"`
+	ReturnDocuments *bool `json:"return_documents,omitempty"`
+}
+
+// DocumentTexts returns the texts of the documents, documents in unknown formats are skipped
+func (r RerankRequest) DocumentTexts() []string {
+	texts := make([]string, 0, len(r.Documents))
+	for _, document := range r.Documents {
+		switch v := document.(type) {
+		case string:
+			texts = append(texts, v)
+		case map[string]any:
+			if text, ok := v["text"].(string); ok {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return texts
+}
+
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+type RerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+type RerankResponse struct {
+	Id      string         `json:"id,omitempty"`
+	Model   string         `json:"model"`
+	Results []RerankResult `json:"results"`
+	Usage   *Usage         `json:"usage,omitempty"`
+}
@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+)
+
+type counter struct {
+	bucket   int64
+	current  int64
+	previous int64
+}
+
+// roll moves the counter to the bucket
+func (c *counter) roll(bucket int64) {
+	switch {
+	case bucket == c.bucket:
+	case bucket == c.bucket+1:
+		c.previous = c.current
+		c.current = 0
+	case bucket > c.bucket+1:
+		c.previous = 0
+		c.current = 0
+	default:
+		return
+	}
+	c.bucket = bucket
+}
+
+var (
+	counters     = make(map[string]*counter)
+	countersLock sync.Mutex
+)
+
+func getCounter(key string, bucket int64) *counter {
+	c, ok := counters[key]
+	if !ok {
+		c = &counter{bucket: bucket}
+		counters[key] = c
+	}
+	c.roll(bucket)
+	return c
+}
+
+func memoryReserve(rules []Rule, bucket int64, weight float64, amount int64) []int64 {
+	countersLock.Lock()
+	defer countersLock.Unlock()
+	used := make([]int64, len(rules))
+	allowed := true
+	for i, rule := range rules {
+		c := getCounter(rule.Key, bucket)
+		used[i] = int64(math.Floor(float64(c.previous)*weight)) + c.current
+		if used[i]+amount > rule.Limit {
+			allowed = false
+		}
+	}
+	if allowed {
+		for _, rule := range rules {
+			counters[rule.Key].current += amount
+		}
+	}
+	// drop the counters which have no effect on the window any more
+	for key, c := range counters {
+		if c.bucket < bucket-1 {
+			delete(counters, key)
+		}
+	}
+	return used
+}
+
+func memoryAdjust(keys []string, bucket int64, delta int64) {
+	countersLock.Lock()
+	defer countersLock.Unlock()
+	for _, key := range keys {
+		c, ok := counters[key]
+		if !ok {
+			continue
+		}
+		switch bucket {
+		case c.bucket:
+			c.current += delta
+			if c.current < 0 {
+				c.current = 0
+			}
+		case c.bucket - 1:
+			c.previous += delta
+			if c.previous < 0 {
+				c.previous = 0
+			}
+		}
+	}
+}
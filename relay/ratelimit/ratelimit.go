@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// the limits are enforced with sliding window counters, the count of a window is estimated
+// by the count of the current bucket plus the part of the previous bucket that still overlaps the window.
+// the counters are stored in redis when it is enabled, so that they are shared by all nodes,
+// otherwise every node keeps its own counters in memory
+
+// Window is the length of the sliding windows
+const Window = time.Minute
+
+const keyPrefix = "rate_limit:"
+
+// Limit is the requests and tokens allowed per minute, 0 means unlimited
+type Limit struct {
+	RPM int64 `json:"rpm"`
+	TPM int64 `json:"tpm"`
+}
+
+// the group rate limits are configured by group but enforced per user, every user
+// in the group is allowed the full limit on their own, it is not shared by the group
+var (
+	groupRateLimit     = map[string]Limit{}
+	groupRateLimitLock sync.RWMutex
+)
+
+func GroupRateLimit2JSONString() string {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	jsonBytes, err := json.Marshal(groupRateLimit)
+	if err != nil {
+		logger.SysError("error marshalling group rate limit: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateGroupRateLimitByJSONString(jsonStr string) error {
+	newGroupRateLimit := make(map[string]Limit)
+	err := json.Unmarshal([]byte(jsonStr), &newGroupRateLimit)
+	if err != nil {
+		return err
+	}
+	for group, limit := range newGroupRateLimit {
+		if limit.RPM < 0 || limit.TPM < 0 {
+			return fmt.Errorf("invalid rate limit of group %s", group)
+		}
+	}
+	groupRateLimitLock.Lock()
+	groupRateLimit = newGroupRateLimit
+	groupRateLimitLock.Unlock()
+	return nil
+}
+
+// GetGroupRateLimit returns the limit applied to each user of the group
+func GetGroupRateLimit(group string) Limit {
+	groupRateLimitLock.RLock()
+	defer groupRateLimitLock.RUnlock()
+	return groupRateLimit[group]
+}
+
+// Rule limits the amount consumed under the key within the window
+type Rule struct {
+	Key   string
+	Limit int64
+}
+
+// Result describes the most restrictive rule
+type Result struct {
+	Limit     int64
+	Remaining int64
+	// Reset is the time until the current bucket is closed
+	Reset time.Duration
+}
+
+// Reservation records the amount consumed from the rules, so that it can be corrected later
+type Reservation struct {
+	keys   []string
+	bucket int64
+	amount int64
+}
+
+func getBucket(now time.Time) (bucket int64, elapsed time.Duration) {
+	bucket = now.UnixNano() / int64(Window)
+	elapsed = time.Duration(now.UnixNano() % int64(Window))
+	return bucket, elapsed
+}
+
+// Reserve consumes the amount from all the rules if every rule allows it, nothing is consumed otherwise
+func Reserve(ctx context.Context, rules []Rule, amount int64) (*Reservation, Result, bool, error) {
+	bucket, elapsed := getBucket(time.Now())
+	// the weight of the previous bucket
+	weight := 1 - float64(elapsed)/float64(Window)
+	var used []int64
+	var err error
+	if common.RedisEnabled {
+		used, err = redisReserve(ctx, rules, bucket, weight, amount)
+	} else {
+		used = memoryReserve(rules, bucket, weight, amount)
+	}
+	if err != nil {
+		return nil, Result{}, false, err
+	}
+	allowed := true
+	for i, rule := range rules {
+		if used[i]+amount > rule.Limit {
+			allowed = false
+		}
+	}
+	result := Result{Reset: Window - elapsed}
+	for i, rule := range rules {
+		remaining := rule.Limit - used[i]
+		if allowed {
+			remaining -= amount
+		}
+		if remaining < 0 {
+			remaining = 0
+		}
+		if i == 0 || remaining < result.Remaining {
+			result.Limit = rule.Limit
+			result.Remaining = remaining
+		}
+	}
+	if !allowed {
+		return nil, result, false, nil
+	}
+	reservation := &Reservation{bucket: bucket, amount: amount}
+	for _, rule := range rules {
+		reservation.keys = append(reservation.keys, rule.Key)
+	}
+	return reservation, result, true, nil
+}
+
+// Reconcile corrects the amount consumed by the reservation to the actual amount
+func (r *Reservation) Reconcile(ctx context.Context, amount int64) {
+	if r == nil || amount == r.amount {
+		return
+	}
+	delta := amount - r.amount
+	r.amount = amount
+	if common.RedisEnabled {
+		if err := redisAdjust(ctx, r.keys, r.bucket, delta); err != nil {
+			logger.Errorf(ctx, "failed to adjust rate limit: %s", err.Error())
+		}
+		return
+	}
+	memoryAdjust(r.keys, r.bucket, delta)
+}
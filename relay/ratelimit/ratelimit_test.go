@@ -0,0 +1,34 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/relay/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserve(t *testing.T) {
+	common.RedisEnabled = false
+	ctx := context.Background()
+	rules := []ratelimit.Rule{{Key: "token:1:tokens", Limit: 100}, {Key: "user:1:tokens", Limit: 50}}
+
+	reservation, result, ok, err := ratelimit.Reserve(ctx, rules, 30)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(50), result.Limit)
+	assert.Equal(t, int64(20), result.Remaining)
+
+	// nothing is consumed from any rule when one of them rejects
+	_, result, ok, err = ratelimit.Reserve(ctx, rules, 30)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(20), result.Remaining)
+
+	reservation.Reconcile(ctx, 10)
+	_, result, ok, err = ratelimit.Reserve(ctx, rules[:1], 30)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(60), result.Remaining)
+}
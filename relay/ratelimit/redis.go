@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/songquanpeng/one-api/common"
+)
+
+// KEYS are the current and the previous buckets of every rule in turn,
+// ARGV are the weight of the previous buckets, the amount, the ttl and the limit of every rule
+var reserveScript = redis.NewScript(`
+local weight = tonumber(ARGV[1])
+local amount = tonumber(ARGV[2])
+local used = {}
+local allowed = true
+for i = 1, #KEYS / 2 do
+	local current = tonumber(redis.call('GET', KEYS[2 * i - 1]) or '0')
+	local previous = tonumber(redis.call('GET', KEYS[2 * i]) or '0')
+	used[i] = math.floor(previous * weight) + current
+	if used[i] + amount > tonumber(ARGV[3 + i]) then
+		allowed = false
+	end
+end
+if allowed then
+	for i = 1, #KEYS / 2 do
+		redis.call('INCRBY', KEYS[2 * i - 1], amount)
+		redis.call('EXPIRE', KEYS[2 * i - 1], ARGV[3])
+	end
+end
+return used
+`)
+
+func getRedisKey(key string, bucket int64) string {
+	return keyPrefix + key + ":" + strconv.FormatInt(bucket, 10)
+}
+
+func redisReserve(ctx context.Context, rules []Rule, bucket int64, weight float64, amount int64) ([]int64, error) {
+	keys := make([]string, 0, 2*len(rules))
+	args := []any{weight, amount, int64(2 * Window.Seconds())}
+	for _, rule := range rules {
+		keys = append(keys, getRedisKey(rule.Key, bucket), getRedisKey(rule.Key, bucket-1))
+		args = append(args, rule.Limit)
+	}
+	return reserveScript.Run(ctx, common.RDB, keys, args...).Int64Slice()
+}
+
+// the bucket may have expired, it is not brought back
+var adjustScript = redis.NewScript(`
+for i = 1, #KEYS do
+	if redis.call('EXISTS', KEYS[i]) == 1 then
+		local value = redis.call('INCRBY', KEYS[i], ARGV[1])
+		if value < 0 then
+			redis.call('INCRBY', KEYS[i], -value)
+		end
+	end
+end
+return 0
+`)
+
+func redisAdjust(ctx context.Context, keys []string, bucket int64, delta int64) error {
+	redisKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		redisKeys = append(redisKeys, getRedisKey(key, bucket))
+	}
+	return adjustScript.Run(ctx, common.RDB, redisKeys, delta).Err()
+}
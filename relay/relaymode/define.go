@@ -13,4 +13,11 @@ const (
 	AudioTranslation
 	// Proxy is a special relay mode for proxying requests to custom upstream
 	Proxy
+	Files
+	Batches
+	Responses
+	Realtime
+	ImagesEdits
+	ImagesVariations
+	Rerank
 )
@@ -30,6 +30,8 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), auth.WeChatBind)
 		apiRouter.GET("/oauth/email/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), controller.EmailBind)
 		apiRouter.POST("/topup", middleware.AdminAuth(), controller.AdminTopUp)
+		apiRouter.GET("/topup/order", middleware.AdminAuth(), controller.GetAllTopUpOrders)
+		apiRouter.POST("/payment/webhook/:provider", controller.PaymentWebhook)
 
 		userRoute := apiRouter.Group("/user")
 		{
@@ -47,6 +49,8 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/token", controller.GenerateAccessToken)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
+				selfRoute.GET("/topup/order", controller.GetUserTopUpOrders)
+				selfRoute.POST("/topup/order", controller.CreateTopUpOrder)
 				selfRoute.GET("/available_models", controller.GetUserAvailableModels)
 			}
 
@@ -79,6 +83,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/test/:id", controller.TestChannel)
 			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
 			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
+			channelRoute.GET("/breaker", controller.GetChannelBreakers)
+			channelRoute.DELETE("/breaker/:id", controller.ResetChannelBreaker)
 			channelRoute.POST("/", controller.AddChannel)
 			channelRoute.PUT("/", controller.UpdateChannel)
 			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
@@ -104,6 +110,57 @@ func SetApiRouter(router *gin.Engine) {
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
+		planRoute := apiRouter.Group("/plan")
+		planRoute.Use(middleware.AdminAuth())
+		{
+			planRoute.GET("/", controller.GetAllPlans)
+			planRoute.GET("/:id", controller.GetPlan)
+			planRoute.POST("/", controller.AddPlan)
+			planRoute.PUT("/", controller.UpdatePlan)
+			planRoute.DELETE("/:id", controller.DeletePlan)
+		}
+		subscriptionRoute := apiRouter.Group("/subscription")
+		subscriptionRoute.Use(middleware.AdminAuth())
+		{
+			subscriptionRoute.GET("/:id", controller.GetUserSubscription)
+			subscriptionRoute.POST("/", controller.SubscribeUser)
+			subscriptionRoute.DELETE("/:id", controller.UnsubscribeUser)
+		}
+		organizationRoute := apiRouter.Group("/organization")
+		{
+			organizationRoute.GET("/", middleware.AdminAuth(), controller.GetAllOrganizations)
+			organizationRoute.POST("/", middleware.AdminAuth(), controller.AddOrganization)
+			organizationRoute.PUT("/", middleware.AdminAuth(), controller.UpdateOrganization)
+			organizationRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeleteOrganization)
+			organizationRoute.GET("/self", middleware.UserAuth(), controller.GetSelfOrganizations)
+			organizationRoute.GET("/:id/member", middleware.UserAuth(), controller.GetOrganizationMembers)
+			organizationRoute.POST("/:id/member", middleware.UserAuth(), controller.AddOrganizationMember)
+			organizationRoute.PUT("/:id/member", middleware.UserAuth(), controller.UpdateOrganizationMember)
+			organizationRoute.DELETE("/:id/member/:user_id", middleware.UserAuth(), controller.DeleteOrganizationMember)
+			organizationRoute.GET("/:id/token", middleware.UserAuth(), controller.GetOrganizationTokens)
+			organizationRoute.GET("/:id/usage", middleware.UserAuth(), controller.GetOrganizationUsage)
+		}
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.AdminAuth())
+		{
+			webhookRoute.GET("/", controller.GetAllWebhooks)
+			webhookRoute.GET("/events", controller.GetWebhookEvents)
+			webhookRoute.GET("/delivery", controller.GetWebhookDeliveries)
+			webhookRoute.POST("/", controller.AddWebhook)
+			webhookRoute.PUT("/", controller.UpdateWebhook)
+			webhookRoute.DELETE("/:id", controller.DeleteWebhook)
+		}
+		budgetRoute := apiRouter.Group("/budget")
+		{
+			budgetRoute.GET("/", middleware.AdminAuth(), controller.GetAllBudgets)
+			budgetRoute.POST("/", middleware.AdminAuth(), controller.AddBudget)
+			budgetRoute.PUT("/", middleware.AdminAuth(), controller.UpdateBudget)
+			budgetRoute.DELETE("/:id", middleware.AdminAuth(), controller.DeleteBudget)
+			budgetRoute.GET("/self", middleware.UserAuth(), controller.GetSelfBudgets)
+			budgetRoute.POST("/self", middleware.UserAuth(), controller.AddSelfBudget)
+			budgetRoute.PUT("/self", middleware.UserAuth(), controller.UpdateSelfBudget)
+			budgetRoute.DELETE("/self/:id", middleware.UserAuth(), controller.DeleteSelfBudget)
+		}
 		logRoute := apiRouter.Group("/log")
 		logRoute.GET("/", middleware.AdminAuth(), controller.GetAllLogs)
 		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
@@ -112,6 +169,14 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.AdminAuth())
+		{
+			auditRoute.GET("/", controller.GetAuditLogs)
+			auditRoute.GET("/export", controller.ExportAuditLogs)
+			auditRoute.GET("/:id", controller.GetAuditLog)
+			auditRoute.DELETE("/", controller.DeleteHistoryAuditLogs)
+		}
 		groupRoute := apiRouter.Group("/group")
 		groupRoute.Use(middleware.AdminAuth())
 		{
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common/config"
 	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/controller"
 	"net/http"
 	"os"
 	"strings"
@@ -15,6 +16,13 @@ func SetRouter(router *gin.Engine, buildFS embed.FS) {
 	SetApiRouter(router)
 	SetDashboardRouter(router)
 	SetRelayRouter(router)
+	if config.MetricsEnabled {
+		if config.MetricsToken == "" {
+			logger.SysError("METRICS_TOKEN is not set, the metrics endpoint is disabled")
+		} else {
+			router.GET("/metrics", controller.GetMetrics)
+		}
+	}
 	frontendBaseUrl := os.Getenv("FRONTEND_BASE_URL")
 	if config.IsMasterNode && frontendBaseUrl != "" {
 		frontendBaseUrl = ""
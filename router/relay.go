@@ -16,26 +16,52 @@ func SetRelayRouter(router *gin.Engine) {
 		modelsRouter.GET("", controller.ListModels)
 		modelsRouter.GET("/:model", controller.RetrieveModel)
 	}
+	geminiRouter := router.Group("/v1beta/models")
+	geminiRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Distribute())
+	{
+		geminiRouter.POST("/:model", controller.RelayGemini)
+	}
+	filesRouter := router.Group("/v1/files")
+	filesRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth())
+	{
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.POST("", middleware.FileUploadChannel(), middleware.Distribute(), controller.Relay)
+		filesRouter.GET("/:id", middleware.FileAffinity(), middleware.Distribute(), controller.Relay)
+		filesRouter.DELETE("/:id", middleware.FileAffinity(), middleware.Distribute(), controller.Relay)
+		filesRouter.GET("/:id/content", middleware.FileAffinity(), middleware.Distribute(), controller.Relay)
+	}
+	batchesRouter := router.Group("/v1/batches")
+	batchesRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth())
+	{
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.POST("", middleware.BatchAffinity(), middleware.Distribute(), controller.Relay)
+		batchesRouter.GET("/:id", middleware.BatchAffinity(), middleware.Distribute(), controller.Relay)
+		batchesRouter.POST("/:id/cancel", middleware.BatchAffinity(), middleware.Distribute(), controller.Relay)
+	}
+	responsesRouter := router.Group("/v1/responses")
+	responsesRouter.Use(middleware.RelayPanicRecover(), middleware.TokenAuth())
+	{
+		responsesRouter.POST("", middleware.ResponseAffinity(), middleware.Distribute(), controller.RelayResponses)
+	}
 	relayV1Router := router.Group("/v1")
 	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.TokenAuth(), middleware.Distribute())
 	{
 		relayV1Router.Any("/oneapi/proxy/:channelid/*target", controller.Relay)
 		relayV1Router.POST("/completions", controller.Relay)
 		relayV1Router.POST("/chat/completions", controller.Relay)
+		relayV1Router.POST("/messages", controller.RelayAnthropicMessages)
+		relayV1Router.POST("/messages/count_tokens", controller.CountAnthropicTokens)
 		relayV1Router.POST("/edits", controller.Relay)
 		relayV1Router.POST("/images/generations", controller.Relay)
-		relayV1Router.POST("/images/edits", controller.RelayNotImplemented)
-		relayV1Router.POST("/images/variations", controller.RelayNotImplemented)
+		relayV1Router.POST("/images/edits", controller.Relay)
+		relayV1Router.POST("/images/variations", controller.Relay)
 		relayV1Router.POST("/embeddings", controller.Relay)
 		relayV1Router.POST("/engines/:model/embeddings", controller.Relay)
+		relayV1Router.POST("/rerank", controller.Relay)
 		relayV1Router.POST("/audio/transcriptions", controller.Relay)
 		relayV1Router.POST("/audio/translations", controller.Relay)
 		relayV1Router.POST("/audio/speech", controller.Relay)
-		relayV1Router.GET("/files", controller.RelayNotImplemented)
-		relayV1Router.POST("/files", controller.RelayNotImplemented)
-		relayV1Router.DELETE("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id", controller.RelayNotImplemented)
-		relayV1Router.GET("/files/:id/content", controller.RelayNotImplemented)
+		relayV1Router.GET("/realtime", controller.Relay)
 		relayV1Router.POST("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayV1Router.GET("/fine_tuning/jobs", controller.RelayNotImplemented)
 		relayV1Router.GET("/fine_tuning/jobs/:id", controller.RelayNotImplemented)
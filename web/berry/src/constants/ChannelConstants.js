@@ -179,6 +179,12 @@ export const CHANNEL_OPTIONS = {
     value: 44,
     color: 'primary'
   },
+  45: {
+    key: 45,
+    text: 'Jina',
+    value: 45,
+    color: 'primary'
+  },
   41: {
     key: 41,
     text: 'Novita',
@@ -54,6 +54,10 @@ function renderBalance(type, balance) {
       return <span>{renderNumber(balance)}</span>;
     case 44: // SiliconFlow
       return <span>¥{balance.toFixed(2)}</span>;
+    case 20: // OpenRouter
+    case 25: // Moonshot
+    case 36: // DeepSeek
+      return <span>${balance.toFixed(2)}</span>;
     default:
       return <span>不支持</span>;
   }